@@ -0,0 +1,83 @@
+package s3client
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// ReadArchiveMember reads the member with the given name of the specified tar archive from the
+// S3 bucket, sending its lines through a channel as ReadFile does for whole files. Archives
+// compressed as a whole, such as "logs.tar.gz" or "logs.tgz", are decompressed first, and the
+// member is decoded based on its own name, so "manifest.json.gz" is decompressed as well.
+// Names are compared once cleaned, "./manifest.json" naming "manifest.json". When the archive
+// holds no such member, an error wrapping ErrMemberNotFound is sent through the error channel.
+func (c *DefaultClient) ReadArchiveMember(ctx context.Context, bucket string, key string, memberName string, initialBufferSize int, maxBufferSize int) (<-chan string, <-chan error) {
+	out := make(chan string)
+	errChan := make(chan error)
+
+	go func() {
+		defer close(errChan)
+		defer close(out)
+
+		c.logger().Info("Started processing member: %s of archive: %s from bucket: %s", memberName, key, bucket)
+
+		resp, err := c.getObject(ctx, c.getObjectInput(bucket, key))
+		if err != nil {
+			errChan <- mapError(err)
+			return
+		}
+		defer resp.Body.Close()
+
+		archive, err := c.decompressReader(archiveInfo(newObjectInfoFromGetObject(key, resp)), resp.Body)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		defer archive.Close()
+
+		tr := tar.NewReader(archive)
+		want := cleanMemberName(memberName)
+		for {
+			hdr, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				errChan <- fmt.Errorf("archive %s has no member %s: %w", key, memberName, ErrMemberNotFound)
+				return
+			}
+			if err != nil {
+				errChan <- fmt.Errorf("error reading archive %s: %w", key, err)
+				return
+			}
+			if hdr.Typeflag != tar.TypeReg || cleanMemberName(hdr.Name) != want {
+				continue
+			}
+
+			c.scanLines(bucket, ObjectInfo{Key: hdr.Name, Size: hdr.Size}, io.NopCloser(tr), initialBufferSize, maxBufferSize, out, errChan)
+			return
+		}
+	}()
+
+	return observeStream(c, "ReadArchiveMember", out, errChan)
+}
+
+// archiveInfo returns the info of the given tar archive to decode it with, named so that only
+// the compression of the archive as a whole is decoded and the tar stream is left as is.
+func archiveInfo(info ObjectInfo) ObjectInfo {
+	name := strings.ToLower(info.Key)
+	switch {
+	case strings.HasSuffix(name, ".tgz"):
+		info.Key = info.Key[:len(info.Key)-len(".tgz")] + ".tar.gz"
+	case strings.HasSuffix(name, ".tar"):
+		info.Key = info.Key[:len(info.Key)-len(".tar")]
+	}
+	return info
+}
+
+// cleanMemberName returns the given tar member name without its leading "./" or "/".
+func cleanMemberName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
@@ -0,0 +1,94 @@
+package s3client
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestDefaultClient_ReadArchiveMember(t *testing.T) {
+	ctx := context.TODO()
+
+	gz := func(data []byte) []byte {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, _ = zw.Write(data)
+		_ = zw.Close()
+		return buf.Bytes()
+	}
+
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	for _, member := range []struct {
+		name string
+		data []byte
+	}{
+		{"./logs/", nil},
+		{"./logs/app.log", []byte("app 1\napp 2\n")},
+		{"./logs/other.log", []byte("other\n")},
+		{"./logs/old.log.gz", gz([]byte("old 1\nold 2\n"))},
+	} {
+		hdr := &tar.Header{Name: member.name, Mode: 0o644, Size: int64(len(member.data)), Typeflag: tar.TypeReg}
+		if member.data == nil {
+			hdr.Typeflag = tar.TypeDir
+		}
+		assert.NoError(t, tw.WriteHeader(hdr))
+		_, err := tw.Write(member.data)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tw.Close())
+
+	c, err := NewMemoryClient(NullLogger{}, map[string][]byte{
+		"logs.tar":    archive.Bytes(),
+		"logs.tar.gz": gz(archive.Bytes()),
+		"logs.tgz":    gz(archive.Bytes()),
+	})
+	assert.NoError(t, err)
+
+	read := func(key, member string) ([]string, error) {
+		outCh, errCh := c.ReadArchiveMember(ctx, "bucket", key, member, 64*1024, 1024*1024)
+		var lines []string
+		var errs []error
+		for outCh != nil || errCh != nil {
+			select {
+			case line, ok := <-outCh:
+				if !ok {
+					outCh = nil
+					continue
+				}
+				lines = append(lines, line)
+			case err, ok := <-errCh:
+				if !ok {
+					errCh = nil
+					continue
+				}
+				errs = append(errs, err)
+			}
+		}
+		return lines, errors.Join(errs...)
+	}
+
+	for _, key := range []string{"logs.tar", "logs.tar.gz", "logs.tgz"} {
+		t.Run(key, func(t *testing.T) {
+			lines, err := read(key, "logs/app.log")
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"app 1", "app 2"}, lines)
+
+			lines, err = read(key, "./logs/old.log.gz")
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"old 1", "old 2"}, lines)
+
+			_, err = read(key, "logs/missing.log")
+			assert.IsError(t, err, ErrMemberNotFound)
+
+			// directories aren't members to read.
+			_, err = read(key, "logs")
+			assert.IsError(t, err, ErrMemberNotFound)
+		})
+	}
+}
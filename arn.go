@@ -0,0 +1,40 @@
+package s3client
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+)
+
+// IsBucketARN reports whether bucket is an ARN rather than a bucket name, such as the ARN of an
+// S3 access point, arn:aws:s3:region:account:accesspoint/name, or of an S3 on Outposts access
+// point, arn:aws:s3-outposts:region:account:outpost/id/accesspoint/name.
+// ARNs can be passed instead of bucket names to every method of the client.
+func IsBucketARN(bucket string) bool {
+	return arn.IsARN(bucket)
+}
+
+// validateBucketARN checks that the given bucket ARN names an access point S3 can be
+// addressed through, so that a wrong ARN fails with an error saying so rather than with
+// the one of the endpoint rules it doesn't match.
+func validateBucketARN(bucket string) error {
+	parsed, err := arn.Parse(bucket)
+	if err != nil {
+		return fmt.Errorf("%w %q: %v", ErrInvalidBucketARN, bucket, err)
+	}
+
+	switch {
+	case parsed.Service == "s3" && strings.HasPrefix(parsed.Resource, "accesspoint/"),
+		parsed.Service == "s3-outposts" && strings.HasPrefix(parsed.Resource, "outpost/") && strings.Contains(parsed.Resource, "/accesspoint/"):
+	case parsed.Service == "s3" && parsed.AccountID == "":
+		return fmt.Errorf("%w %q: bucket ARNs are not supported, pass the bucket name instead", ErrInvalidBucketARN, bucket)
+	default:
+		return fmt.Errorf("%w %q: only S3 and S3 on Outposts access point ARNs are supported", ErrInvalidBucketARN, bucket)
+	}
+
+	if parsed.Region == "" || parsed.AccountID == "" {
+		return fmt.Errorf("%w %q: access point ARNs require a region and an account ID", ErrInvalidBucketARN, bucket)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package s3client
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// listBreaker is the circuit breaker of the listings of a client. It counts the consecutive
+// throttle errors listing requests get, attempts retried by the SDK included, and trips once
+// they reach the threshold set with WithListCircuitBreaker, failing the listing in progress
+// and then the ones started during the cooldown that follows.
+type listBreaker struct {
+	mu sync.Mutex
+	// throttles is the number of consecutive throttle errors since the last successful page.
+	throttles int
+	// tripped is set once throttles reached the threshold, until the next successful page.
+	tripped bool
+	// openUntil is the end of the cooldown of the last trip, during which listings fail fast.
+	openUntil time.Time
+}
+
+// isThrottle reports whether err is one the SDK treats as a throttle error, such as "SlowDown".
+func isThrottle(err error) bool {
+	return retry.IsErrorThrottles(retry.DefaultThrottles).IsErrorThrottle(err) == aws.TrueTernary
+}
+
+// allow returns an error wrapping ErrListThrottled while the breaker cools down after a trip.
+func (b *listBreaker) allow(now time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if remaining := b.openUntil.Sub(now); remaining > 0 {
+		return fmt.Errorf("%w: listings resume in %s", ErrListThrottled, remaining.Round(time.Millisecond))
+	}
+	return nil
+}
+
+// record counts the given throttle error, reporting whether it trips the breaker.
+func (b *listBreaker) record(now time.Time, threshold int, cooldown time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.throttles++; b.throttles >= threshold && !b.tripped {
+		b.tripped = true
+		b.openUntil = now.Add(cooldown)
+	}
+	return b.tripped
+}
+
+// reset closes the breaker after a successful page.
+func (b *listBreaker) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.throttles, b.tripped = 0, false
+}
+
+// breakerRetryer is a retryer counting the throttle errors of the attempts of a listing request,
+// and no longer retrying them once they trip the breaker, leaving other errors to the retryer it wraps.
+type breakerRetryer struct {
+	aws.Retryer
+	c *DefaultClient
+	// tripped is set once an attempt trips the breaker, counted is set once one is counted.
+	tripped, counted *bool
+}
+
+func (r breakerRetryer) IsErrorRetryable(err error) bool {
+	if isThrottle(err) {
+		*r.counted = true
+		if *r.tripped = r.c.listBreaker.record(time.Now(), r.c.opts.ListThrottleThreshold, r.c.opts.ListThrottleCooldown); *r.tripped {
+			return false
+		}
+	}
+	return r.Retryer.IsErrorRetryable(err)
+}
+
+// listPage runs call, a request listing a page of bucket, as inBucketRegion does, through the
+// circuit breaker of the client when one is set with WithListCircuitBreaker.
+func listPage[T any](c *DefaultClient, bucket string, call func(optFns ...func(*s3.Options)) (T, error)) (T, error) {
+	threshold := c.opts.ListThrottleThreshold
+	if threshold <= 0 {
+		return inBucketRegion(c, bucket, call)
+	}
+
+	if err := c.listBreaker.allow(time.Now()); err != nil {
+		var zero T
+		return zero, err
+	}
+
+	var tripped, counted bool
+	out, err := inBucketRegion(c, bucket, func(optFns ...func(*s3.Options)) (T, error) {
+		return call(append(optFns, func(o *s3.Options) {
+			o.Retryer = breakerRetryer{Retryer: o.Retryer, c: c, tripped: &tripped, counted: &counted}
+		})...)
+	})
+	if err == nil {
+		c.listBreaker.reset()
+		return out, nil
+	}
+	// requests sent without the SDK's retry middleware, such as to mocks, are counted here.
+	if !counted && isThrottle(err) {
+		tripped = c.listBreaker.record(time.Now(), threshold, c.opts.ListThrottleCooldown)
+	}
+	if tripped {
+		return out, fmt.Errorf("%w after %d consecutive throttle errors: %w", ErrListThrottled, threshold, err)
+	}
+	return out, err
+}
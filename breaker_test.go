@@ -0,0 +1,96 @@
+package s3client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/ratelimit"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+
+	"github.com/calyptia/go-s3-client/ifaces"
+)
+
+func TestWithListCircuitBreaker(t *testing.T) {
+	ctx := context.TODO()
+
+	t.Run("retried attempts", func(t *testing.T) {
+		var requests int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`<Error><Code>SlowDown</Code><Message>Please reduce your request rate.</Message></Error>`))
+		}))
+		defer srv.Close()
+
+		// the SDK would retry each page 10 times without the breaker.
+		svc := s3.New(s3.Options{
+			Region:       "us-east-1",
+			BaseEndpoint: aws.String(srv.URL),
+			UsePathStyle: true,
+			Credentials:  aws.AnonymousCredentials{},
+			Retryer: retry.NewStandard(func(o *retry.StandardOptions) {
+				o.MaxAttempts = 10
+				o.Backoff = retry.BackoffDelayerFunc(func(int, error) (time.Duration, error) { return 0, nil })
+				o.RateLimiter = ratelimit.None
+			}),
+		})
+		c := DefaultClient{Svc: svc, Logger: NullLogger{}}
+		assert.NoError(t, WithListCircuitBreaker(3, time.Hour)(&c.opts))
+
+		_, err := c.ListFiles(ctx, "bucket", "logs/*.log")
+		assert.IsError(t, err, ErrListThrottled)
+		assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+
+		// listings fail fast during the cooldown.
+		_, _, err = c.ListDir(ctx, "bucket", "logs/")
+		assert.IsError(t, err, ErrListThrottled)
+		assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+	})
+
+	t.Run("consecutive pages", func(t *testing.T) {
+		throttled := true
+		client := ifaces.ClientMock{
+			ListObjectsV2Func: func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+				if throttled {
+					return nil, &smithy.GenericAPIError{Code: "SlowDown", Message: "Please reduce your request rate."}
+				}
+				return &s3.ListObjectsV2Output{Contents: []types.Object{{Key: aws.String("logs/a.log")}}}, nil
+			},
+		}
+		c := DefaultClient{Svc: &client, Logger: NullLogger{}}
+		assert.NoError(t, WithListCircuitBreaker(2, 0)(&c.opts))
+
+		_, err := c.ListFiles(ctx, "bucket", "logs/*.log")
+		assert.Error(t, err)
+		assert.NotIsError(t, err, ErrListThrottled)
+
+		_, err = c.ListFiles(ctx, "bucket", "logs/*.log")
+		assert.IsError(t, err, ErrListThrottled)
+
+		// without a cooldown, the next listing is let through, and a successful page resets the count.
+		throttled = false
+		files, err := c.ListFiles(ctx, "bucket", "logs/*.log")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"logs/a.log"}, files)
+
+		throttled = true
+		_, err = c.ListFiles(ctx, "bucket", "logs/*.log")
+		assert.NotIsError(t, err, ErrListThrottled)
+		assert.Equal(t, 4, len(client.ListObjectsV2Calls()))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		var opts ClientOpts
+		assert.EqualError(t, WithListCircuitBreaker(0, time.Minute)(&opts), "list throttle threshold must be positive, got 0")
+		assert.EqualError(t, WithListCircuitBreaker(3, -time.Second)(&opts), "list throttle cooldown must not be negative, got -1s")
+	})
+}
@@ -0,0 +1,61 @@
+package s3client
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ReadFileOptions are the options of a single ReadFile call, set through the functions passed
+// to it, as the AWS SDK does with its per-operation options. Options left empty keep the
+// behavior configured on the client.
+type ReadFileOptions struct {
+	// VersionID is the version of the object to read, the latest one when empty.
+	VersionID string
+	// Range is the HTTP range of the object to read, e.g. "bytes=0-1023", the whole object when empty.
+	// Ranges of compressed objects can't be decoded, and ranged reads are never resumed.
+	Range string
+	// SSECustomerKey is the 32 bytes key the object is encrypted with, overriding the one
+	// set with WithSSECustomerKey.
+	SSECustomerKey []byte
+	// RequestPayer makes the requester pay for the request, even if the client isn't
+	// configured for requester-pays buckets.
+	RequestPayer bool
+}
+
+// apply sets the options on the GetObject parameters used to read the file.
+func (o *ReadFileOptions) apply(input *s3.GetObjectInput) {
+	if o.VersionID != "" {
+		input.VersionId = aws.String(o.VersionID)
+	}
+	if o.Range != "" {
+		input.Range = aws.String(o.Range)
+	}
+	if len(o.SSECustomerKey) > 0 {
+		input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5 = sseCustomerKeyHeaders(o.SSECustomerKey)
+	}
+	if o.RequestPayer {
+		input.RequestPayer = types.RequestPayerRequester
+	}
+}
+
+// ListFilesOptions are the options of a single ListFiles call, set through the functions
+// passed to it, as ReadFileOptions are for ReadFile.
+type ListFilesOptions struct {
+	// Delimiter groups the keys containing it after the listed prefix, which are then left out
+	// of the listing, e.g. "/" to only match the objects directly under the prefix.
+	Delimiter string
+	// RequestPayer makes the requester pay for the request, even if the client isn't
+	// configured for requester-pays buckets.
+	RequestPayer bool
+}
+
+// apply sets the options on the ListObjectsV2 parameters used to list the files.
+func (o *ListFilesOptions) apply(input *s3.ListObjectsV2Input) {
+	if o.Delimiter != "" {
+		input.Delimiter = aws.String(o.Delimiter)
+	}
+	if o.RequestPayer {
+		input.RequestPayer = types.RequestPayerRequester
+	}
+}
@@ -0,0 +1,111 @@
+package s3client
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go/aws"
+
+	"github.com/calyptia/go-s3-client/ifaces"
+)
+
+func TestDefaultClient_ReadFile_Options(t *testing.T) {
+	ctx := context.TODO()
+	key := []byte(strings.Repeat("k", 32))
+	digest := md5.Sum(key)
+
+	client := ifaces.ClientMock{
+		GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("a\nb"))}, nil
+		},
+	}
+
+	c := DefaultClient{
+		Svc:    &client,
+		Logger: NullLogger{},
+		opts:   ClientOpts{MaxReadResumes: 3},
+	}
+
+	outCh, errCh := c.ReadFile(ctx, "bucket", "file.log", 64*1024, 10*1024*1024, func(o *ReadFileOptions) {
+		o.VersionID = "v1"
+		o.Range = "bytes=0-2"
+	}, func(o *ReadFileOptions) {
+		o.SSECustomerKey = key
+		o.RequestPayer = true
+	})
+
+	var lines []string
+	for line := range outCh {
+		lines = append(lines, line)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assert.Equal(t, []string{"a", "b"}, lines)
+
+	params := client.GetObjectCalls()[0].Params
+	assert.Equal(t, "v1", aws.StringValue(params.VersionId))
+	assert.Equal(t, "bytes=0-2", aws.StringValue(params.Range))
+	assert.Equal(t, "AES256", aws.StringValue(params.SSECustomerAlgorithm))
+	assert.Equal(t, base64.StdEncoding.EncodeToString(key), aws.StringValue(params.SSECustomerKey))
+	assert.Equal(t, base64.StdEncoding.EncodeToString(digest[:]), aws.StringValue(params.SSECustomerKeyMD5))
+	assert.Equal(t, types.RequestPayerRequester, params.RequestPayer)
+
+	// options only apply to the call they are passed to.
+	outCh, errCh = c.ReadFile(ctx, "bucket", "file.log", 64*1024, 10*1024*1024)
+	for range outCh {
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	params = client.GetObjectCalls()[1].Params
+	assert.Zero(t, params.VersionId)
+	assert.Zero(t, params.Range)
+	assert.Zero(t, params.SSECustomerKey)
+	assert.Zero(t, params.RequestPayer)
+}
+
+func TestDefaultClient_ListFiles_Options(t *testing.T) {
+	ctx := context.TODO()
+
+	client := ifaces.ClientMock{
+		ListObjectsV2Func: func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+			out := &s3.ListObjectsV2Output{
+				Contents: []types.Object{{Key: aws.String("logs/one.log")}},
+			}
+			if params.Delimiter == nil {
+				out.Contents = append(out.Contents, types.Object{Key: aws.String("logs/nested/two.log")})
+			}
+			return out, nil
+		},
+	}
+
+	c := DefaultClient{
+		Svc:    &client,
+		Logger: NullLogger{},
+	}
+
+	files, err := c.ListFiles(ctx, "bucket", "logs/**/*.log", func(o *ListFilesOptions) {
+		o.Delimiter = "/"
+		o.RequestPayer = true
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"logs/one.log"}, files)
+
+	params := client.ListObjectsV2Calls()[0].Params
+	assert.Equal(t, "/", aws.StringValue(params.Delimiter))
+	assert.Equal(t, types.RequestPayerRequester, params.RequestPayer)
+
+	files, err = c.ListFiles(ctx, "bucket", "logs/**/*.log")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"logs/one.log", "logs/nested/two.log"}, files)
+	assert.Zero(t, client.ListObjectsV2Calls()[1].Params.Delimiter)
+}
@@ -0,0 +1,69 @@
+package s3client
+
+import (
+	"fmt"
+	"mime"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/ianaindex"
+)
+
+// charsets holds the registered charsets, keyed by file extension or media type.
+var charsets = struct {
+	sync.RWMutex
+	m map[string]encoding.Encoding
+}{m: map[string]encoding.Encoding{}}
+
+// RegisterCharset registers the charset used by ReadFile to decode objects matching match,
+// which is either a file extension such as ".csv" or a media type such as "text/csv".
+// The charset is an IANA name such as "ISO-8859-1" or "Shift_JIS".
+// Objects not matching any registered charset are read as UTF-8.
+func RegisterCharset(match, charset string) error {
+	enc, err := ianaindex.IANA.Encoding(charset)
+	if err != nil {
+		return fmt.Errorf("unknown charset %q: %w", charset, err)
+	}
+	if enc == nil {
+		return fmt.Errorf("unsupported charset %q", charset)
+	}
+
+	charsets.Lock()
+	defer charsets.Unlock()
+	charsets.m[strings.ToLower(match)] = enc
+	return nil
+}
+
+// charsetFor returns the registered charset for the given file and content type,
+// preferring the content type over the file extension. For files with the extension of a
+// registered file reader, such as compressed ones, the extension underneath it is also considered.
+// It returns nil if no charset is registered, meaning the content is UTF-8.
+func charsetFor(file, contentType string) encoding.Encoding {
+	charsets.RLock()
+	defer charsets.RUnlock()
+
+	if len(charsets.m) == 0 {
+		return nil
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		if enc, ok := charsets.m[mediaType]; ok {
+			return enc
+		}
+	}
+
+	name := strings.ToLower(file)
+	for ext := filepath.Ext(name); ext != ""; ext = filepath.Ext(name) {
+		if enc, ok := charsets.m[ext]; ok {
+			return enc
+		}
+		if _, ok := fileReaderFor(ext); !ok {
+			break
+		}
+		name = strings.TrimSuffix(name, ext)
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package s3client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go/aws"
+
+	"github.com/calyptia/go-s3-client/ifaces"
+)
+
+func TestRegisterCharset(t *testing.T) {
+	t.Cleanup(func() {
+		charsets.Lock()
+		defer charsets.Unlock()
+		delete(charsets.m, ".latin")
+		delete(charsets.m, "text/x-sjis")
+	})
+
+	assert.NoError(t, RegisterCharset(".latin", "ISO-8859-1"))
+	assert.NoError(t, RegisterCharset("text/x-sjis", "Shift_JIS"))
+	assert.Error(t, RegisterCharset(".bad", "not-a-charset"))
+
+	objects := map[string]struct {
+		body        []byte
+		contentType string
+	}{
+		// "café" in ISO-8859-1.
+		"file.latin": {body: []byte{'c', 'a', 'f', 0xe9}, contentType: "text/plain"},
+		// "日本" in Shift_JIS.
+		"file.txt": {body: []byte{0x93, 0xfa, 0x96, 0x7b}, contentType: "text/x-sjis; charset=shift_jis"},
+		// no registered charset, read as UTF-8.
+		"file.log": {body: []byte("café"), contentType: "text/plain"},
+	}
+
+	client := ifaces.ClientMock{
+		GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			obj := objects[*params.Key]
+			return &s3.GetObjectOutput{
+				Body:        io.NopCloser(bytes.NewReader(obj.body)),
+				ContentType: aws.String(obj.contentType),
+			}, nil
+		},
+	}
+
+	c := DefaultClient{
+		Svc:    &client,
+		Logger: NullLogger{},
+	}
+
+	expected := map[string]string{
+		"file.latin": "café",
+		"file.txt":   "日本",
+		"file.log":   "café",
+	}
+	for file, want := range expected {
+		outCh, _ := c.ReadFile(context.TODO(), "bucket", file, 64*1024, 10*1024*1024)
+		var lines []string
+		for line := range outCh {
+			lines = append(lines, line)
+		}
+		assert.Equal(t, []string{want}, lines, file)
+	}
+}
+
+func TestCharsetFor(t *testing.T) {
+	t.Cleanup(func() {
+		charsets.Lock()
+		defer charsets.Unlock()
+		delete(charsets.m, ".csv")
+	})
+
+	assert.Zero(t, charsetFor("file.csv", ""))
+	assert.NoError(t, RegisterCharset(".CSV", "ISO-8859-1"))
+	assert.NotZero(t, charsetFor("file.csv", ""))
+	assert.NotZero(t, charsetFor("dir/FILE.CSV.gz", ""))
+	// every registered reader is looked through, not only gzip.
+	assert.NotZero(t, charsetFor("file.csv.lz4", ""))
+	assert.NotZero(t, charsetFor("file.csv.tar.gz", ""))
+	assert.Zero(t, charsetFor("file.csv.bak", ""))
+}
@@ -2,18 +2,32 @@ package s3client
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/md5"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	smithyauth "github.com/aws/smithy-go/auth"
 	smithyendpoints "github.com/aws/smithy-go/endpoints"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 	"github.com/bmatcuk/doublestar"
+	"golang.org/x/text/transform"
 
 	"github.com/calyptia/go-s3-client/ifaces"
 )
@@ -28,19 +42,141 @@ type (
 	}
 
 	// Client is the interface for interacting with an S3 bucket.
+	// It gains a method with every operation added to DefaultClient and is not meant to be
+	// implemented outside this module; mocks should embed it.
+	//
+	// Methods that stream their results return an output channel and an error channel.
+	// Any error is sent on the error channel before the output channel is closed, and the
+	// output channel is always closed once the method is done. The error channel is closed
+	// after the output channel, so a receive from it that reports a closed channel means the
+	// stream completed successfully. ReadFiles also closes its events channel before the
+	// error channel, and its events must be received along with the lines for it to progress.
 	Client interface {
-		ListFiles(ctx context.Context, bucket, pattern string) ([]string, error)
-		ReadFile(ctx context.Context, bucket string, file string, initialBufferSize int, maxBufferSize int) (<-chan string, <-chan error)
+		ListFiles(ctx context.Context, bucket, pattern string, optFns ...func(*ListFilesOptions)) ([]string, error)
+		ListFilesMulti(ctx context.Context, bucket string, patterns []string) ([]string, error)
+		ListFilesPage(ctx context.Context, bucket, pattern string, continuationToken string, pageSize int) ([]string, string, error)
+		ListFilesStream(ctx context.Context, bucket, pattern string) (<-chan string, <-chan error)
+		CountFiles(ctx context.Context, bucket, pattern string) (int, int64, error)
+		LatestFile(ctx context.Context, bucket, pattern string) (ObjectInfo, error)
+		ListFilesWithMetadata(ctx context.Context, bucket, pattern string) ([]ObjectInfo, error)
+		ListDir(ctx context.Context, bucket, prefix string) ([]string, []ObjectInfo, error)
+		ListDirs(ctx context.Context, bucket, prefix string) ([]string, error)
+		ListFileVersions(ctx context.Context, bucket, prefix string) ([]ObjectVersion, error)
+		ReadFile(ctx context.Context, bucket string, file string, initialBufferSize int, maxBufferSize int, optFns ...func(*ReadFileOptions)) (<-chan string, <-chan error)
+		ScanReader(ctx context.Context, filename string, body io.ReadCloser, initialBufferSize int, maxBufferSize int) (<-chan string, <-chan error)
+		ReadFileLines(ctx context.Context, bucket string, file string, initialBufferSize int, maxBufferSize int, optFns ...func(*ReadFileOptions)) (<-chan Line, <-chan error)
+		ReadFileVersion(ctx context.Context, bucket string, file string, versionID string, initialBufferSize int, maxBufferSize int) (<-chan string, <-chan error)
+		ReadFileIfChanged(ctx context.Context, bucket string, file string, cond ReadConditions, initialBufferSize int, maxBufferSize int) (<-chan string, <-chan error)
+		ReadArchiveMember(ctx context.Context, bucket string, key string, memberName string, initialBufferSize int, maxBufferSize int) (<-chan string, <-chan error)
+		ReadFileChunks(ctx context.Context, bucket string, file string, chunkSize int) (<-chan []byte, <-chan error)
+		ReadFileTail(ctx context.Context, bucket string, file string, n int, initialBufferSize int, maxBufferSize int) ([]string, error)
+		ReadFileParallel(ctx context.Context, bucket string, file string, partSize int64, concurrency int, initialBufferSize int, maxBufferSize int) (<-chan string, <-chan error)
+		ReadFiles(ctx context.Context, bucket string, files []string, initialBufferSize int, maxBufferSize int) (<-chan string, <-chan FileEvent, <-chan error)
+		ReadFilesMerged(ctx context.Context, bucket string, files []string, initialBufferSize int, maxBufferSize int, extract func(line []byte) (int64, bool)) (<-chan string, <-chan error)
+		OpenFile(ctx context.Context, bucket string, file string, initialBufferSize int, maxBufferSize int) (ObjectInfo, <-chan string, <-chan error)
+		ReadCSV(ctx context.Context, bucket string, file string, delimiter rune) (<-chan map[string]string, <-chan error)
+		OpenReader(ctx context.Context, bucket string, file string) (io.ReadCloser, error)
+		OpenGzipFile(ctx context.Context, bucket string, file string) (gzip.Header, io.ReadCloser, error)
+		OpenReaderAt(ctx context.Context, bucket string, file string) (*io.SectionReader, error)
+		ReadAll(ctx context.Context, bucket string, file string, maxBytes int64) ([]byte, error)
+		DownloadToFile(ctx context.Context, bucket string, file string, localPath string) (int64, error)
+		WaitForObject(ctx context.Context, bucket string, file string, pollInterval time.Duration, timeout time.Duration) error
+		StreamNewObjects(ctx context.Context, bucket, prefix string, pollInterval time.Duration) (<-chan ObjectInfo, <-chan error)
+		WriteFile(ctx context.Context, bucket string, file string, body io.Reader) error
+		WriteFileMultipart(ctx context.Context, bucket string, file string, body io.Reader, partSize int64) error
+		DeleteFileIfMatch(ctx context.Context, bucket string, file string, etag string) error
+		CopyFile(ctx context.Context, srcBucket string, srcFile string, dstBucket string, dstFile string) error
+		MoveFile(ctx context.Context, srcBucket string, srcFile string, dstBucket string, dstFile string) error
+		GetObjectTags(ctx context.Context, bucket string, file string) (map[string]string, error)
+		PutObjectTags(ctx context.Context, bucket string, file string, tags map[string]string) error
+		GetObjectRetention(ctx context.Context, bucket string, file string) (RetentionInfo, error)
+		GetObjectLegalHold(ctx context.Context, bucket string, file string) (bool, error)
+		BucketExists(ctx context.Context, bucket string) (bool, error)
+		CreateBucket(ctx context.Context, bucket string, region string) error
 	}
 	// DefaultClient is a concrete implementation of the Client interface that uses the AWS SDK for Go to interact with S3.
+	// It is safe for concurrent use, and a single client should be shared so that its connection pool,
+	// bounded by WithMaxIdleConns and WithMaxConnsPerHost, is reused across goroutines.
 	DefaultClient struct {
 		Client
 		Svc    ifaces.Client
 		Logger Logger
+
+		opts      ClientOpts
+		transport *http.Transport
+		// regions caches the region of buckets that live outside the configured one.
+		regions sync.Map
+		// listBreaker is the circuit breaker of the listings, set with WithListCircuitBreaker.
+		listBreaker listBreaker
+		// releaseCredentials releases the shared credentials of the client, if any, on Close.
+		releaseCredentials func()
+	}
+	// ObjectInfo holds the metadata S3 returns for an object.
+	ObjectInfo struct {
+		// Key is the object key within the bucket.
+		Key string
+		// Size is the object's Content-Length in bytes.
+		Size int64
+		// ContentType is the object's Content-Type header.
+		ContentType string
+		// ContentEncoding is the object's Content-Encoding header.
+		ContentEncoding string
+		// ETag is the object's entity tag.
+		ETag string
+		// LastModified is the time the object was last modified.
+		LastModified time.Time
+		// Metadata is the user-defined metadata stored with the object.
+		Metadata map[string]string
+	}
+	// RetentionInfo describes the object lock retention of an object.
+	// Objects without retention have an empty Mode and a zero RetainUntil.
+	RetentionInfo struct {
+		// Mode is the retention mode, either "GOVERNANCE" or "COMPLIANCE".
+		Mode string
+		// RetainUntil is the time until which the object can't be deleted nor overwritten.
+		RetainUntil time.Time
+	}
+	// ReadConditions holds the conditions under which ReadFileIfChanged reads an object.
+	// Conditions left empty are not checked.
+	ReadConditions struct {
+		// IfNoneMatch is the ETag of the object as last read, it is only read again if its ETag differs.
+		IfNoneMatch string
+		// IfModifiedSince is the time the object was last read, it is only read again if modified since.
+		IfModifiedSince time.Time
+	}
+	// ObjectVersion describes a version of an object in a versioned bucket.
+	ObjectVersion struct {
+		ObjectInfo
+		// VersionID is the version's ID, to be passed to ReadFileVersion.
+		VersionID string
+		// IsLatest is true for the current version of the object.
+		IsLatest bool
+		// IsDeleteMarker is true if the version marks the object as deleted, it has no content.
+		IsDeleteMarker bool
+	}
+	// FileEvent is emitted by ReadFiles when it starts and finishes reading each file.
+	FileEvent struct {
+		// Kind is the kind of event.
+		Kind FileEventKind
+		// Key is the file the event refers to.
+		Key string
+		// Lines is the number of lines read from the file, set on FileCompleted events.
+		Lines int
+		// Err is the error that stopped reading the file, if any, set on FileCompleted events.
+		Err error
+	}
+	// Line is a line read by ReadFileLines.
+	Line struct {
+		// LineNum is the number of the line in the file, starting at 1.
+		LineNum int
+		// Text is the content of the line, without its line ending.
+		Text string
 	}
 	resolverV2 struct {
 		BaseEndpoint string
 		Region       string
+		// SigningRegion overrides the region requests are signed for, if set.
+		SigningRegion string
 	}
 )
 
@@ -53,180 +189,1830 @@ func (r *resolverV2) ResolveEndpoint(ctx context.Context, params s3.EndpointPara
 	} else {
 		params.Endpoint = aws.String(r.BaseEndpoint)
 	}
-	return s3.NewDefaultEndpointResolverV2().ResolveEndpoint(ctx, params)
+	// access points can only be addressed virtual-hosted-style, whereas path-style is
+	// otherwise forced for compatibility with other S3 implementations.
+	if bucket := aws.ToString(params.Bucket); IsBucketARN(bucket) {
+		if err := validateBucketARN(bucket); err != nil {
+			return smithyendpoints.Endpoint{}, err
+		}
+		params.ForcePathStyle = aws.Bool(false)
+	}
+	endpoint, err := s3.NewDefaultEndpointResolverV2().ResolveEndpoint(ctx, params)
+	if err != nil || r.SigningRegion == "" {
+		return endpoint, err
+	}
+
+	// the signer takes the region from the auth schemes of the endpoint.
+	options, _ := smithyauth.GetAuthOptions(&endpoint.Properties)
+	for _, option := range options {
+		if option.SchemeID == smithyauth.SchemeIDSigV4 {
+			smithyhttp.SetSigV4SigningRegion(&option.SignerProperties, r.SigningRegion)
+		}
+	}
+	return endpoint, nil
+}
+
+// FileEventKind represents the kind of FileEvent.
+type FileEventKind int
+
+const (
+	// FileStarted is emitted before the first line of a file is read.
+	FileStarted FileEventKind = iota
+	// FileCompleted is emitted once a file has been fully read or failed.
+	FileCompleted
+)
+
+// String returns the name of the file event kind.
+func (k FileEventKind) String() string {
+	switch k {
+	case FileStarted:
+		return "started"
+	case FileCompleted:
+		return "completed"
+	default:
+		return fmt.Sprintf("FileEventKind(%d)", int(k))
+	}
+}
+
+func newObjectInfoFromGetObject(key string, resp *s3.GetObjectOutput) ObjectInfo {
+	return ObjectInfo{
+		Key:             key,
+		Size:            aws.ToInt64(resp.ContentLength),
+		ContentType:     aws.ToString(resp.ContentType),
+		ContentEncoding: aws.ToString(resp.ContentEncoding),
+		ETag:            aws.ToString(resp.ETag),
+		LastModified:    aws.ToTime(resp.LastModified),
+		Metadata:        resp.Metadata,
+	}
+}
+
+func newObjectInfoFromHeadObject(key string, resp *s3.HeadObjectOutput) ObjectInfo {
+	return ObjectInfo{
+		Key:             key,
+		Size:            aws.ToInt64(resp.ContentLength),
+		ContentType:     aws.ToString(resp.ContentType),
+		ContentEncoding: aws.ToString(resp.ContentEncoding),
+		ETag:            aws.ToString(resp.ETag),
+		LastModified:    aws.ToTime(resp.LastModified),
+		Metadata:        resp.Metadata,
+	}
+}
+
+func newObjectInfoFromObject(obj types.Object) ObjectInfo {
+	return ObjectInfo{
+		Key:          aws.ToString(obj.Key),
+		Size:         aws.ToInt64(obj.Size),
+		ETag:         aws.ToString(obj.ETag),
+		LastModified: aws.ToTime(obj.LastModified),
+	}
 }
 
-// New returns a new DefaultClient configured with the given options and using the provided logger.
+// New returns a new DefaultClient configured with the given options and using the provided logger,
+// which may be nil to log nothing. A logger set with WithLogger takes precedence over it.
 func New(ctx context.Context, logger Logger, optsFns ...ClientOptsFunc) (*DefaultClient, error) {
-	var opts ClientOpts
+	opts := ClientOpts{Logger: logger}
 	for _, optFn := range optsFns {
 		err := optFn(&opts)
 		if err != nil {
 			return nil, err
 		}
 	}
+	logger = opts.logger()
+
+	if err := opts.validate(); err != nil {
+		return nil, fmt.Errorf("invalid client options: %w", err)
+	}
+
+	if opts.Endpoint != "" && (opts.UseFIPSEndpoint || opts.UseDualStackEndpoint) {
+		logger.Warn("ignoring FIPS and dualstack endpoint options as the custom endpoint: %s is used", opts.Endpoint)
+	}
 
 	cfg, err := config.LoadDefaultConfig(ctx, opts.LoadOptions()...)
 	if err != nil {
 		return nil, err
 	}
 
+	opts.applyCredentials(&cfg)
+	releaseCredentials := opts.shareCredentials(&cfg)
+
+	var transport *http.Transport
+	httpClient := opts.HTTPClient
+	if httpClient == nil {
+		transport = &http.Transport{
+			DisableCompression:  true,
+			MaxIdleConns:        opts.maxIdleConns(),
+			MaxIdleConnsPerHost: opts.maxIdleConns(),
+			MaxConnsPerHost:     opts.maxConnsPerHost(),
+			IdleConnTimeout:     defaultIdleConnTimeout,
+			TLSClientConfig:     opts.tlsConfig(),
+		}
+		httpClient = &http.Client{Transport: transport}
+	}
+	if opts.InsecureSkipVerify {
+		logger.Warn("TLS certificate verification is disabled, connections to S3 can be intercepted")
+	}
+
 	client := s3.NewFromConfig(cfg, func(options *s3.Options) {
 		//	https://github.com/minio/minio/discussions/12030#discussioncomment-590564
 		//	this is backwards compatible flag to make it work with minio.
-		options.UsePathStyle = true
-		options.HTTPClient = &http.Client{
-			Transport: &http.Transport{
-				DisableCompression: true,
-			},
-		}
+		// Transfer Acceleration only works with virtual-hosted-style addressing.
+		options.UsePathStyle = !opts.Accelerate
+		options.UseAccelerate = opts.Accelerate
+		options.UseARNRegion = opts.UseARNRegion
+		options.HTTPClient = httpClient
 		if opts.Endpoint != "" {
 			options.BaseEndpoint = aws.String(opts.Endpoint)
 		}
 		options.Region = opts.Region
+		// added here rather than to the loaded config, so the STS clients fetching credentials don't get them.
+		options.APIOptions = append(options.APIOptions, opts.APIOptions...)
+
+		if opts.EndpointResolver != nil {
+			options.EndpointResolverV2 = opts.EndpointResolver
+			return
+		}
 
 		resolver := &resolverV2{
-			BaseEndpoint: opts.Endpoint,
-			Region:       opts.Region,
+			BaseEndpoint:  opts.Endpoint,
+			Region:        opts.Region,
+			SigningRegion: opts.SigningRegion,
 		}
 
 		options.EndpointResolverV2 = resolver
 	})
 
-	return &DefaultClient{Svc: client, Logger: logger}, nil
+	return &DefaultClient{Svc: client, Logger: logger, opts: opts, transport: transport, releaseCredentials: releaseCredentials}, nil
+}
+
+// Close releases the idle connections held by the client's HTTP transport, and its hold on the
+// credentials shared through WithSharedCredentialsCache.
+// The client must not be used after calling Close.
+func (c *DefaultClient) Close() error {
+	if c.transport != nil {
+		c.transport.CloseIdleConnections()
+	}
+	if c.releaseCredentials != nil {
+		c.releaseCredentials()
+	}
+	return nil
 }
 
 // ListFiles returns a list of file names in the specified bucket that match the given pattern.
-func (c *DefaultClient) ListFiles(ctx context.Context, bucket, pattern string) ([]string, error) {
-	var files []string
-	// listAndMatch is a helper function that lists objects in the bucket with the given prefix and file name,
-	// and applies the given match function to each object name. If the match function returns true,
-	// the object name is added to the files slice.
-	listAndMatch := func(bucket, pattern string, match func(objectName string) bool) ([]string, error) {
-		// List objects in the S3 bucket with the given prefix and file name
-		params := &s3.ListObjectsV2Input{
-			Bucket: aws.String(bucket),
-		}
+// Glob patterns are matched against the full key, and patterns without any wildcard only
+// match the key equal to them.
+// If listing fails after some pages have been listed, the files matched on those pages are
+// returned along with an error wrapping a *PartialListError, so they're never silently dropped
+// by callers that want them, and callers that don't can use errors.As to tell them apart.
+// The functions passed in optFns set the ListFilesOptions of this call only.
+func (c *DefaultClient) ListFiles(ctx context.Context, bucket, pattern string, optFns ...func(*ListFilesOptions)) (files []string, err error) {
+	defer c.observe("ListFiles", time.Now(), &err)
 
-		prefix := GetDirPrefix(pattern)
-		if prefix != "" {
-			params.Prefix = &prefix
-		}
+	var opts ListFilesOptions
+	for _, fn := range optFns {
+		fn(&opts)
+	}
 
-		c.Logger.Debug("listing files on bucket: %q with prefix: %q that follows pattern: %q", bucket, prefix, pattern)
-		p := s3.NewListObjectsV2Paginator(c.Svc, params)
+	err = c.walkFilesWith(ctx, bucket, pattern, opts, func(obj types.Object) {
+		files = append(files, *obj.Key)
+	})
+	if err != nil {
+		return files, fmt.Errorf("error listing files from s3: %w", mapError(err))
+	}
 
-		for p.HasMorePages() {
-			page, err := p.NextPage(ctx)
-			if err != nil {
-				return files, err
-			}
-			for _, obj := range page.Contents {
-				matches := match(*obj.Key)
-				c.Logger.Debug("object key: %q matches with pattern: %q result: %q", *obj.Key, pattern, matches)
-				if matches {
-					files = append(files, *obj.Key)
-				}
-			}
-		}
-		c.Logger.Debug("found: %d file(s) on bucket: %q that follows pattern: %q", len(files), bucket, pattern)
-		return files, nil
+	c.logger().Debug("found: %d file(s) on bucket: %q that follows pattern: %q", len(files), bucket, pattern)
+	return files, nil
+}
+
+// ListFilesWithMetadata returns the metadata of the objects in the specified bucket that match
+// the given pattern, matching them as ListFiles does, along with their size, ETag and last
+// modification time as listed, without a HeadObject request per object.
+// As with ListFiles, a failure after some pages have been listed returns the objects matched on
+// them along with an error wrapping a *PartialListError.
+func (c *DefaultClient) ListFilesWithMetadata(ctx context.Context, bucket, pattern string) (objects []ObjectInfo, err error) {
+	defer c.observe("ListFilesWithMetadata", time.Now(), &err)
+
+	err = c.walkFiles(ctx, bucket, pattern, func(obj types.Object) {
+		objects = append(objects, newObjectInfoFromObject(obj))
+	})
+	if err != nil {
+		return objects, fmt.Errorf("error listing files from s3: %w", mapError(err))
 	}
 
-	files, err := listAndMatch(bucket, pattern, func(objectName string) bool {
-		if IsGlobPattern(pattern) {
-			matches, err := doublestar.PathMatch(pattern, objectName)
-			return err == nil && matches
-		}
-		return filepath.Base(pattern) == filepath.Base(objectName)
+	c.logger().Debug("found: %d file(s) on bucket: %q that follows pattern: %q", len(objects), bucket, pattern)
+	return objects, nil
+}
+
+// ListFilesMulti returns a list of file names in the specified bucket that match any of the
+// given patterns, matching them as ListFiles does. Rather than listing the bucket once per
+// pattern, it lists the smallest set of prefixes covering the literal prefixes of all of them,
+// so each object is listed at most once and keys matching several patterns are only returned once.
+// The prefixes are listed concurrently, as set with WithListConcurrency, the files still being
+// returned in the order of a sequential listing.
+// As with ListFiles, a failure after some pages have been listed returns the files matched on
+// them along with an error wrapping a *PartialListError.
+func (c *DefaultClient) ListFilesMulti(ctx context.Context, bucket string, patterns []string) (files []string, err error) {
+	defer c.observe("ListFilesMulti", time.Now(), &err)
+
+	err = c.walkPatterns(ctx, bucket, patterns, ListFilesOptions{}, func(obj types.Object) {
+		files = append(files, *obj.Key)
 	})
 	if err != nil {
-		return files, fmt.Errorf("error listing files from s3: %w", err)
+		return files, fmt.Errorf("error listing files from s3: %w", mapError(err))
 	}
 
+	c.logger().Debug("found: %d file(s) on bucket: %q that follows patterns: %q", len(files), bucket, patterns)
 	return files, nil
 }
 
-// ReadFile reads the specified file from the given S3 bucket and sends its contents
-// line by line through a channel. It uses an adaptive buffering mechanism to handle
-// large lines of text up to a specified maximum size.
-func (c *DefaultClient) ReadFile(ctx context.Context, bucket string, file string, initialBufferSize int, maxBufferSize int) (<-chan string, <-chan error) {
-	// Channels to return the file contents and any potential errors.
+// ListFilesPage lists a single page of at most pageSize objects in the specified bucket, up to
+// 1000, the page size configured with WithListPageSize when zero, and returns the names of the files among them that match the
+// given pattern, as ListFiles does, along with the token to pass to list the next page, empty
+// after the last one. Pass an empty token to list the first page.
+// Matching applies to the listed objects, so a page may hold fewer files than pageSize, or none,
+// while more remain to be listed. Patterns are listed from their literal prefix up to the first
+// wildcard, brace alternatives included.
+func (c *DefaultClient) ListFilesPage(ctx context.Context, bucket, pattern string, continuationToken string, pageSize int) (files []string, nextToken string, err error) {
+	defer c.observe("ListFilesPage", time.Now(), &err)
+
+	if pageSize < 0 || pageSize > maxListPageSize {
+		return nil, "", fmt.Errorf("page size must be between 0 and %d, got %d", maxListPageSize, pageSize)
+	}
+
+	params := &s3.ListObjectsV2Input{
+		Bucket:       aws.String(bucket),
+		RequestPayer: c.requestPayer(),
+	}
+	if prefix := c.listPrefix(pattern); prefix != "" {
+		params.Prefix = aws.String(prefix)
+	}
+	if continuationToken != "" {
+		params.ContinuationToken = aws.String(continuationToken)
+	}
+	if pageSize > 0 {
+		params.MaxKeys = aws.Int32(int32(pageSize))
+	} else {
+		params.MaxKeys = c.opts.listMaxKeys()
+	}
+
+	page, err := listPage(c, bucket, func(optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+		return c.Svc.ListObjectsV2(ctx, params, optFns...)
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("error listing files from s3: %w", mapError(err))
+	}
+
+	match := c.matchFunc(pattern)
+	for _, obj := range page.Contents {
+		if match(*obj.Key) {
+			files = append(files, *obj.Key)
+		}
+	}
+	if aws.ToBool(page.IsTruncated) {
+		nextToken = aws.ToString(page.NextContinuationToken)
+	}
+
+	c.logger().Debug("found: %d file(s) in page of: %d object(s) on bucket: %q that follows pattern: %q", len(files), len(page.Contents), bucket, pattern)
+	return files, nextToken, nil
+}
+
+// ListFilesStream lists the file names in the specified bucket that match the given pattern,
+// as ListFiles does, but sends them through the returned channel page by page as they are
+// listed, so that they are never all held in memory.
+// Once ctx is done, listing stops and its error is sent through the error channel, which must
+// still be drained then, as with every stream.
+func (c *DefaultClient) ListFilesStream(ctx context.Context, bucket, pattern string) (<-chan string, <-chan error) {
 	out := make(chan string)
 	errChan := make(chan error)
 
 	go func() {
-		// Always close the output channel when done.
+		defer close(errChan)
 		defer close(out)
 
-		// Log start of file processing.
-		c.Logger.Info("Started processing file: %s from bucket: %s", file, bucket)
-
-		// Get the specified file from the S3 bucket.
-		resp, err := c.Svc.GetObject(ctx, &s3.GetObjectInput{
-			Bucket: &bucket,
-			Key:    &file,
+		var files int
+		err := c.walkFiles(ctx, bucket, pattern, func(obj types.Object) {
+			select {
+			case out <- *obj.Key:
+				files++
+			case <-ctx.Done():
+			}
 		})
+		if err == nil {
+			// the rest of the last page may have been skipped.
+			err = ctx.Err()
+		}
 		if err != nil {
-			// On error, send to error channel and exit.
-			errChan <- err
+			errChan <- fmt.Errorf("error listing files from s3: %w", mapError(err))
 			return
 		}
-		// Ensure the file's body stream is closed when done.
-		// Close the filename body when the function exits
-		defer func(Body io.ReadCloser) {
-			err = Body.Close()
-			if err != nil {
-				// Send the error to the error channel
-				errChan <- err
-				return
+
+		c.logger().Debug("found: %d file(s) on bucket: %q that follows pattern: %q", files, bucket, pattern)
+	}()
+
+	return observeStream(c, "ListFilesStream", out, errChan)
+}
+
+// CountFiles returns the number of objects in the specified bucket that match the given
+// pattern and their total size in bytes, matching objects as ListFiles does but without
+// holding on to the list of matches.
+func (c *DefaultClient) CountFiles(ctx context.Context, bucket, pattern string) (count int, totalBytes int64, err error) {
+	defer c.observe("CountFiles", time.Now(), &err)
+
+	err = c.walkFiles(ctx, bucket, pattern, func(obj types.Object) {
+		count++
+		totalBytes += aws.ToInt64(obj.Size)
+	})
+	if err != nil {
+		return count, totalBytes, fmt.Errorf("error counting files from s3: %w", mapError(err))
+	}
+
+	c.logger().Debug("counted: %d file(s) totalling %d byte(s) on bucket: %q that follows pattern: %q", count, totalBytes, bucket, pattern)
+	return count, totalBytes, nil
+}
+
+// LatestFile returns the most recently modified object in the specified bucket that matches the
+// given pattern, matching objects as ListFiles does but only holding on to the latest match.
+// Objects modified at the same time are told apart by their key, the greatest one being returned.
+// It fails with ErrNoMatchingFiles when no object matches.
+func (c *DefaultClient) LatestFile(ctx context.Context, bucket, pattern string) (_ ObjectInfo, err error) {
+	defer c.observe("LatestFile", time.Now(), &err)
+
+	var latest types.Object
+	err = c.walkFiles(ctx, bucket, pattern, func(obj types.Object) {
+		// keys are listed in ascending order, so a later key wins a tie.
+		if latest.Key == nil || !aws.ToTime(obj.LastModified).Before(aws.ToTime(latest.LastModified)) {
+			latest = obj
+		}
+	})
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("error listing files from s3: %w", mapError(err))
+	}
+	if latest.Key == nil {
+		return ObjectInfo{}, fmt.Errorf("no file on bucket %q follows pattern %q: %w", bucket, pattern, ErrNoMatchingFiles)
+	}
+
+	c.logger().Debug("latest file on bucket: %q that follows pattern: %q is: %q", bucket, pattern, *latest.Key)
+	return newObjectInfoFromObject(latest), nil
+}
+
+// walkFiles lists the objects in the bucket with the literal prefix of the given pattern
+// and calls fn, page by page, for each object whose key matches the pattern.
+func (c *DefaultClient) walkFiles(ctx context.Context, bucket, pattern string, fn func(obj types.Object)) error {
+	return c.walkFilesWith(ctx, bucket, pattern, ListFilesOptions{}, fn)
+}
+
+// walkFilesWith is walkFiles, listing the objects with the given options.
+func (c *DefaultClient) walkFilesWith(ctx context.Context, bucket, pattern string, opts ListFilesOptions, fn func(obj types.Object)) error {
+	return c.walkPatterns(ctx, bucket, []string{pattern}, opts, fn)
+}
+
+// walkPatterns lists the covering prefixes of those given by patternPrefixes for the patterns,
+// and calls fn for each object whose key matches any of them. Prefixes are listed concurrently,
+// up to the list concurrency of the client at once, but fn is only called from the calling
+// goroutine, with the objects of each prefix in turn, so they come in the order of a sequential
+// listing. Objects are passed on page by page as they are listed, the prefixes after the one
+// being passed on holding at most a page of matches each until their turn. As no prefix covers
+// another, objects are never passed twice.
+// A failure after some pages have been listed is returned as a *PartialListError, fn having been
+// called with the objects of the pages listed up to it.
+func (c *DefaultClient) walkPatterns(ctx context.Context, bucket string, patterns []string, opts ListFilesOptions, fn func(obj types.Object)) error {
+	var prefixes []string
+	for _, pattern := range patterns {
+		prefixes = append(prefixes, c.patternPrefixes(pattern)...)
+	}
+	prefixes = coveringPrefixes(prefixes)
+	covered := func(prefix string) []string {
+		var out []string
+		for _, pattern := range patterns {
+			for _, p := range c.patternPrefixes(pattern) {
+				if strings.HasPrefix(p, prefix) {
+					out = append(out, pattern)
+					break
+				}
 			}
-		}(resp.Body)
+		}
+		return out
+	}
+
+	if len(prefixes) == 1 {
+		pages, err := c.walkPrefix(ctx, bucket, prefixes[0], covered(prefixes[0]), opts, func(objs []types.Object) {
+			for _, obj := range objs {
+				fn(obj)
+			}
+		})
+		if err != nil && pages > 0 {
+			return &PartialListError{Pages: pages, Err: err}
+		}
+		return err
+	}
+
+	// every prefix sends the matches of its pages through a channel holding at most one of
+	// them, so that only a page per prefix waits in memory while earlier prefixes are passed on.
+	type listing struct {
+		matches chan []types.Object
+		pages   int
+		err     error
+	}
+	listings := make([]*listing, len(prefixes))
+	for i := range listings {
+		listings[i] = &listing{matches: make(chan []types.Object, 1)}
+	}
+
+	// prefixes still being listed on return are cancelled and waited for.
+	ctx, cancel := context.WithCancel(ctx)
+	defer func() {
+		cancel()
+		for _, l := range listings {
+			for range l.matches {
+			}
+		}
+	}()
+
+	sem := make(chan struct{}, c.opts.listConcurrency())
+	go func() {
+		for i, prefix := range prefixes {
+			l := listings[i]
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				l.err = ctx.Err()
+				close(l.matches)
+				continue
+			}
+			go func() {
+				defer func() { <-sem }()
+				defer close(l.matches)
+				l.pages, l.err = c.walkPrefix(ctx, bucket, prefix, covered(prefix), opts, func(objs []types.Object) {
+					if len(objs) == 0 {
+						return
+					}
+					select {
+					case l.matches <- objs:
+					case <-ctx.Done():
+					}
+				})
+				if l.err == nil {
+					// the matches of the last pages may not have been sent.
+					l.err = ctx.Err()
+				}
+			}()
+		}
+	}()
+
+	pages := 0
+	for _, l := range listings {
+		for objs := range l.matches {
+			for _, obj := range objs {
+				fn(obj)
+			}
+		}
+		pages += l.pages
+		if l.err != nil {
+			if pages > 0 {
+				return &PartialListError{Pages: pages, Err: l.err}
+			}
+			return l.err
+		}
+	}
+	return nil
+}
+
+// walkPrefix lists the objects in the bucket with the given prefix and calls fn once per page
+// with the objects of the page whose key matches any of the patterns, none at times. It returns
+// the number of pages listed before any error.
+func (c *DefaultClient) walkPrefix(ctx context.Context, bucket, prefix string, patterns []string, opts ListFilesOptions, fn func(objs []types.Object)) (int, error) {
+	// List objects in the S3 bucket with the given prefix and file name
+	params := &s3.ListObjectsV2Input{
+		Bucket:       aws.String(bucket),
+		MaxKeys:      c.opts.listMaxKeys(),
+		RequestPayer: c.requestPayer(),
+	}
+	if prefix != "" {
+		params.Prefix = &prefix
+	}
+	opts.apply(params)
 
-		// Get a reader for the file based on its format/type.
-		reader, err := GetFileReader(file)(resp.Body)
+	matchers := make([]func(objectName string) bool, len(patterns))
+	for i, pattern := range patterns {
+		matchers[i] = c.matchFunc(pattern)
+	}
+	desc := strings.Join(patterns, ", ")
+
+	c.logger().Debug("listing files on bucket: %q with prefix: %q that follows pattern: %q", bucket, prefix, desc)
+	p := s3.NewListObjectsV2Paginator(c.Svc, params)
+
+	pages := 0
+	for ; p.HasMorePages(); pages++ {
+		page, err := listPage(c, bucket, func(optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+			return p.NextPage(ctx, optFns...)
+		})
 		if err != nil {
-			// On error, send to error channel and exit.
-			errChan <- err
-			return
+			return pages, err
 		}
-		// Ensure the reader is closed when done.
-		defer func(reader io.ReadCloser) {
-			err := reader.Close()
-			if err != nil {
-				errChan <- err
-				return
+		var matched []types.Object
+		for _, obj := range page.Contents {
+			matches := false
+			for _, match := range matchers {
+				if matches = match(*obj.Key); matches {
+					break
+				}
 			}
-		}(reader)
+			c.logger().Debug("object key: %q matches with pattern: %q result: %t", *obj.Key, desc, matches)
+			if matches {
+				matched = append(matched, obj)
+			}
+		}
+		fn(matched)
+	}
+
+	return pages, nil
+}
+
+// matchFunc returns the function used to match object keys against the given pattern,
+// ignoring case when set with WithCaseInsensitiveMatch.
+func (c *DefaultClient) matchFunc(pattern string) func(objectName string) bool {
+	if !c.opts.CaseInsensitiveMatch {
+		return matchFunc(pattern)
+	}
+	match := matchFunc(strings.ToLower(pattern))
+	return func(objectName string) bool {
+		return match(strings.ToLower(objectName))
+	}
+}
 
-		// Create a scanner to read the file contents.
-		scanner := bufio.NewScanner(reader)
+// listPrefix returns the prefix to list to match pattern, as listPrefix does, cut before its
+// first cased character when matching ignores case.
+func (c *DefaultClient) listPrefix(pattern string) string {
+	if c.opts.CaseInsensitiveMatch {
+		return caseFoldPrefix(listPrefix(pattern))
+	}
+	return listPrefix(pattern)
+}
 
-		// Initialize a buffer for the scanner, setting its initial and maximum sizes.
-		buf := make([]byte, 0, initialBufferSize)
-		scanner.Buffer(buf, maxBufferSize)
+// patternPrefixes returns the prefixes to list to match pattern, as patternPrefixes does, cut
+// before their first cased character when matching ignores case.
+func (c *DefaultClient) patternPrefixes(pattern string) []string {
+	prefixes := patternPrefixes(pattern)
+	if c.opts.CaseInsensitiveMatch {
+		for i, prefix := range prefixes {
+			prefixes[i] = caseFoldPrefix(prefix)
+		}
+	}
+	return prefixes
+}
 
-		// Read the file line by line.
-		for scanner.Scan() {
-			out <- scanner.Text()
+// matchFunc returns the function used to match object keys against the given pattern.
+func matchFunc(pattern string) func(objectName string) bool {
+	return func(objectName string) bool {
+		// S3 keys are not filesystem paths, they always use "/" as separator.
+		if IsGlobPattern(pattern) {
+			matches, err := doublestar.Match(pattern, objectName)
+			return err == nil && matches
 		}
+		// Patterns without wildcards name a single key.
+		return objectName == pattern
+	}
+}
 
-		// Check for any scanning errors.
-		if err := scanner.Err(); err != nil {
-			// If the error is due to a line being too long, log a specific message.
-			if errors.Is(err, bufio.ErrTooLong) {
-				c.Logger.Error("Encountered a line that was too long to read in file: %s from bucket: %s, exceeds > %d", file, bucket, maxBufferSize)
+// ListDir lists the immediate contents of the given prefix in the bucket as if it were
+// a directory, returning the sub-directories (common prefixes, ending in "/") and the
+// objects directly under it separately. A prefix not ending in "/" is treated as a
+// directory name, and an empty prefix lists the bucket root.
+// As with ListFiles, a failure after some pages have been listed returns their entries along
+// with an error wrapping a *PartialListError.
+func (c *DefaultClient) ListDir(ctx context.Context, bucket, prefix string) (_ []string, _ []ObjectInfo, err error) {
+	defer c.observe("ListDir", time.Now(), &err)
+
+	var dirs []string
+	var files []ObjectInfo
+
+	prefix = dirPrefix(prefix)
+	params := &s3.ListObjectsV2Input{
+		Bucket:       aws.String(bucket),
+		Delimiter:    aws.String("/"),
+		MaxKeys:      c.opts.listMaxKeys(),
+		RequestPayer: c.requestPayer(),
+	}
+	if prefix != "" {
+		params.Prefix = aws.String(prefix)
+	}
+
+	c.logger().Debug("listing directory on bucket: %q with prefix: %q", bucket, prefix)
+	p := s3.NewListObjectsV2Paginator(c.Svc, params)
+	for pages := 0; p.HasMorePages(); pages++ {
+		page, err := listPage(c, bucket, func(optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+			return p.NextPage(ctx, optFns...)
+		})
+		if err != nil {
+			if pages > 0 {
+				err = &PartialListError{Pages: pages, Err: err}
 			}
+			return dirs, files, fmt.Errorf("error listing directory from s3: %w", mapError(err))
+		}
+		for _, commonPrefix := range page.CommonPrefixes {
+			dirs = append(dirs, aws.ToString(commonPrefix.Prefix))
+		}
+		for _, obj := range page.Contents {
+			files = append(files, newObjectInfoFromObject(obj))
+		}
+	}
+	c.logger().Debug("found: %d dir(s) and %d file(s) on bucket: %q with prefix: %q", len(dirs), len(files), bucket, prefix)
 
-			// Send the error to the error channel and exit.
-			errChan <- err
-			return
+	return dirs, files, nil
+}
+
+// ListDirs lists the immediate sub-directories of the given prefix in the bucket, the common
+// prefixes ending in "/" that ListDir returns, leaving out the objects directly under it.
+// Prefixes are handled as ListDir does: an empty prefix lists the bucket root.
+func (c *DefaultClient) ListDirs(ctx context.Context, bucket, prefix string) (_ []string, err error) {
+	defer c.observe("ListDirs", time.Now(), &err)
+
+	dirs, _, err := c.ListDir(ctx, bucket, prefix)
+	return dirs, err
+}
+
+// ListFileVersions lists every version of the objects whose key starts with the given prefix,
+// including delete markers, ordered by key and newest version first.
+func (c *DefaultClient) ListFileVersions(ctx context.Context, bucket, prefix string) (_ []ObjectVersion, err error) {
+	defer c.observe("ListFileVersions", time.Now(), &err)
+
+	params := &s3.ListObjectVersionsInput{
+		Bucket:       aws.String(bucket),
+		MaxKeys:      c.opts.listMaxKeys(),
+		RequestPayer: c.requestPayer(),
+	}
+	if prefix != "" {
+		params.Prefix = &prefix
+	}
+
+	c.logger().Debug("listing file versions on bucket: %q with prefix: %q", bucket, prefix)
+	p := s3.NewListObjectVersionsPaginator(c.Svc, params)
+
+	var versions []ObjectVersion
+	for p.HasMorePages() {
+		page, err := listPage(c, bucket, func(optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+			return p.NextPage(ctx, optFns...)
+		})
+		if err != nil {
+			return versions, fmt.Errorf("error listing file versions from s3: %w", mapError(err))
+		}
+
+		for _, v := range page.Versions {
+			versions = append(versions, ObjectVersion{
+				ObjectInfo: ObjectInfo{
+					Key:          aws.ToString(v.Key),
+					Size:         aws.ToInt64(v.Size),
+					ETag:         aws.ToString(v.ETag),
+					LastModified: aws.ToTime(v.LastModified),
+				},
+				VersionID: aws.ToString(v.VersionId),
+				IsLatest:  aws.ToBool(v.IsLatest),
+			})
+		}
+		for _, m := range page.DeleteMarkers {
+			versions = append(versions, ObjectVersion{
+				ObjectInfo: ObjectInfo{
+					Key:          aws.ToString(m.Key),
+					LastModified: aws.ToTime(m.LastModified),
+				},
+				VersionID:      aws.ToString(m.VersionId),
+				IsLatest:       aws.ToBool(m.IsLatest),
+				IsDeleteMarker: true,
+			})
 		}
+	}
+
+	// S3 returns versions and delete markers apart, interleave them again.
+	sort.SliceStable(versions, func(i, j int) bool {
+		if versions[i].Key != versions[j].Key {
+			return versions[i].Key < versions[j].Key
+		}
+		return versions[i].LastModified.After(versions[j].LastModified)
+	})
 
-		// Log completion of file processing.
-		c.Logger.Info("Completed processing of file: %s on bucket: %s", file, bucket)
+	c.logger().Debug("found: %d file version(s) on bucket: %q with prefix: %q", len(versions), bucket, prefix)
+	return versions, nil
+}
+
+// ReadFile reads the specified file from the given S3 bucket and sends its contents
+// line by line through a channel. It uses an adaptive buffering mechanism to handle
+// large lines of text up to a specified maximum size.
+// The functions passed in optFns set the ReadFileOptions of this call only.
+func (c *DefaultClient) ReadFile(ctx context.Context, bucket string, file string, initialBufferSize int, maxBufferSize int, optFns ...func(*ReadFileOptions)) (<-chan string, <-chan error) {
+	var opts ReadFileOptions
+	for _, fn := range optFns {
+		fn(&opts)
+	}
+
+	input := c.getObjectInput(bucket, file)
+	opts.apply(input)
+	return c.readObject(ctx, "ReadFile", input, initialBufferSize, maxBufferSize)
+}
+
+// ScanReader reads the given object body line by line as ReadFile does once it has fetched the
+// object, decoding it based on filename, as with GetFileReader, and applying the options of the
+// client, such as WithSkipLongLines. It lets bodies obtained otherwise, e.g. from a local
+// file or in tests, go through the same pipeline. The body is closed once read.
+// Once ctx is done, reading stops and its error is sent through the error channel.
+func (c *DefaultClient) ScanReader(ctx context.Context, filename string, body io.ReadCloser, initialBufferSize int, maxBufferSize int) (<-chan string, <-chan error) {
+	out := make(chan string)
+	errChan := make(chan error)
+
+	go func() {
+		defer close(errChan)
+		defer close(out)
+
+		body := &contextReader{ctx: ctx, ReadCloser: body}
+		c.scanObject("", ObjectInfo{Key: filename}, body, initialBufferSize, maxBufferSize, func(line Line) {
+			select {
+			case out <- line.Text:
+			case <-ctx.Done():
+			}
+		}, errChan)
 	}()
 
-	// Return channels to the caller.
 	return out, errChan
 }
+
+// contextReader is a reader returning the error of its context once it is done.
+type contextReader struct {
+	ctx context.Context
+	io.ReadCloser
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.ReadCloser.Read(p)
+}
+
+// ReadFileLines reads the specified file from the given S3 bucket line by line, as ReadFile
+// does, sending every line along with its number, starting at 1, so that errors about a line
+// can point to it. Lines skipped with WithSkipLongLines still count towards the numbers of
+// the lines after them.
+func (c *DefaultClient) ReadFileLines(ctx context.Context, bucket string, file string, initialBufferSize int, maxBufferSize int, optFns ...func(*ReadFileOptions)) (<-chan Line, <-chan error) {
+	var opts ReadFileOptions
+	for _, fn := range optFns {
+		fn(&opts)
+	}
+
+	input := c.getObjectInput(bucket, file)
+	opts.apply(input)
+
+	out := make(chan Line)
+	errChan := make(chan error)
+
+	go func() {
+		// Always close the output channel when done, and the error channel after it.
+		defer close(errChan)
+		defer close(out)
+
+		c.logger().Info("Started processing file: %s from bucket: %s", file, bucket)
+
+		resp, err := c.getObject(ctx, input)
+		if err != nil {
+			errChan <- mapError(err)
+			return
+		}
+
+		c.scanObject(bucket, newObjectInfoFromGetObject(file, resp), resp.Body, initialBufferSize, maxBufferSize, func(line Line) {
+			out <- line
+		}, errChan)
+	}()
+
+	return observeStream(c, "ReadFileLines", out, errChan)
+}
+
+// ReadFileVersion reads the given version of the specified file from the S3 bucket line by line,
+// as ReadFile does for the latest one.
+func (c *DefaultClient) ReadFileVersion(ctx context.Context, bucket string, file string, versionID string, initialBufferSize int, maxBufferSize int) (<-chan string, <-chan error) {
+	input := c.getObjectInput(bucket, file)
+	input.VersionId = &versionID
+	return c.readObject(ctx, "ReadFileVersion", input, initialBufferSize, maxBufferSize)
+}
+
+// ReadFileIfChanged reads the specified file from the S3 bucket line by line, as ReadFile does,
+// only if it doesn't match the given conditions. Otherwise, an error wrapping ErrNotModified is
+// sent to the error channel and no lines are read.
+func (c *DefaultClient) ReadFileIfChanged(ctx context.Context, bucket string, file string, cond ReadConditions, initialBufferSize int, maxBufferSize int) (<-chan string, <-chan error) {
+	input := c.getObjectInput(bucket, file)
+	if cond.IfNoneMatch != "" {
+		input.IfNoneMatch = &cond.IfNoneMatch
+	}
+	if !cond.IfModifiedSince.IsZero() {
+		input.IfModifiedSince = &cond.IfModifiedSince
+	}
+	return c.readObject(ctx, "ReadFileIfChanged", input, initialBufferSize, maxBufferSize)
+}
+
+// readObject gets the object described by input and sends its lines to the returned channel,
+// reporting the stream as op to the metrics recorder.
+func (c *DefaultClient) readObject(ctx context.Context, op string, input *s3.GetObjectInput, initialBufferSize int, maxBufferSize int) (<-chan string, <-chan error) {
+	bucket, file := aws.ToString(input.Bucket), aws.ToString(input.Key)
+
+	// Channels to return the file contents and any potential errors.
+	out := make(chan string)
+	errChan := make(chan error)
+
+	go func() {
+		// Always close the output channel when done, and the error channel after it.
+		defer close(errChan)
+		defer close(out)
+
+		// Log start of file processing.
+		c.logger().Info("Started processing file: %s from bucket: %s", file, bucket)
+
+		// Get the specified file from the S3 bucket.
+		resp, err := c.getObject(ctx, input)
+		if err != nil {
+			// On error, send to error channel and exit.
+			errChan <- mapError(err)
+			return
+		}
+
+		c.scanLines(bucket, newObjectInfoFromGetObject(file, resp), resp.Body, initialBufferSize, maxBufferSize, out, errChan)
+	}()
+
+	// Return channels to the caller.
+	return observeStream(c, op, out, errChan)
+}
+
+// ReadFileChunks reads the specified file from the given S3 bucket and sends its
+// decompressed contents through a channel in chunks of chunkSize bytes, for objects
+// that are not line oriented. Every chunk is a fresh copy owned by the receiver, and
+// the last one may be shorter than chunkSize.
+func (c *DefaultClient) ReadFileChunks(ctx context.Context, bucket string, file string, chunkSize int) (<-chan []byte, <-chan error) {
+	out := make(chan []byte)
+	errChan := make(chan error)
+
+	go func() {
+		defer close(errChan)
+		defer close(out)
+
+		if chunkSize <= 0 {
+			errChan <- fmt.Errorf("invalid chunk size: %d", chunkSize)
+			return
+		}
+
+		c.logger().Info("Started processing file: %s from bucket: %s", file, bucket)
+
+		resp, err := c.getObject(ctx, c.getObjectInput(bucket, file))
+		if err != nil {
+			errChan <- mapError(err)
+			return
+		}
+		defer func(Body io.ReadCloser) {
+			err := Body.Close()
+			if err != nil {
+				errChan <- err
+			}
+		}(resp.Body)
+
+		reader, err := c.newFileReader(newObjectInfoFromGetObject(file, resp), resp.Body)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		defer func(reader io.ReadCloser) {
+			err := reader.Close()
+			if err != nil {
+				errChan <- err
+			}
+		}(reader)
+
+		src := &sourceErrReader{Reader: reader}
+		buf := make([]byte, chunkSize)
+		for {
+			n, err := io.ReadFull(src, buf)
+			if n > 0 {
+				chunk := make([]byte, n)
+				copy(chunk, buf[:n])
+				out <- chunk
+			}
+			// a short last chunk is only the end of the object if the object itself ended.
+			if errors.Is(err, io.ErrUnexpectedEOF) && src.err == io.EOF {
+				break
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				errChan <- err
+				return
+			}
+		}
+
+		c.logger().Info("Completed processing of file: %s on bucket: %s", file, bucket)
+	}()
+
+	return observeStream(c, "ReadFileChunks", out, errChan)
+}
+
+// sourceErrReader records the last error returned by the reader it wraps, to tell the
+// io.ErrUnexpectedEOF io.ReadFull reports for a short read at the end of the stream apart
+// from the same error returned by a truncated stream.
+type sourceErrReader struct {
+	io.Reader
+	err error
+}
+
+func (r *sourceErrReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.err = err
+	return n, err
+}
+
+// ReadFiles reads the specified files from the given S3 bucket one after another,
+// sending their contents line by line through a single channel, as ReadFile does.
+// A FileStarted event is sent through the events channel before each file is read and a
+// FileCompleted event, carrying the number of lines read, once it is done.
+// Reading stops at the first file that fails, whose error is set on its FileCompleted
+// event and also sent through the error channel.
+// The lines and events channels are closed once all files have been processed.
+// The events channel is unbuffered and sending an event blocks until it is received, so
+// callers must receive from the events channel alongside the lines and error channels,
+// e.g. in the same select, until it is closed; reading lines alone deadlocks.
+func (c *DefaultClient) ReadFiles(ctx context.Context, bucket string, files []string, initialBufferSize int, maxBufferSize int) (<-chan string, <-chan FileEvent, <-chan error) {
+	out := make(chan string)
+	events := make(chan FileEvent)
+	errChan := make(chan error)
+
+	go func() {
+		defer close(errChan)
+		defer close(out)
+		defer close(events)
+
+		var readErr error
+		defer c.observe("ReadFiles", time.Now(), &readErr)
+
+		for _, file := range files {
+			events <- FileEvent{Kind: FileStarted, Key: file}
+
+			var lines int
+			var fileErr error
+			fileOut, fileErrChan := c.ReadFile(ctx, bucket, file, initialBufferSize, maxBufferSize)
+			for fileOut != nil {
+				select {
+				case line, ok := <-fileOut:
+					if !ok {
+						// ReadFile sends every error before closing its output channel.
+						fileOut = nil
+						continue
+					}
+					lines++
+					out <- line
+				case err, ok := <-fileErrChan:
+					if !ok {
+						fileErrChan = nil
+						continue
+					}
+					if fileErr == nil {
+						fileErr = err
+					}
+				}
+			}
+
+			events <- FileEvent{Kind: FileCompleted, Key: file, Lines: lines, Err: fileErr}
+			if fileErr != nil {
+				readErr = fileErr
+				errChan <- fileErr
+				return
+			}
+		}
+	}()
+
+	return out, events, errChan
+}
+
+// sniffLen returns the number of bytes peeked to detect the format of an object.
+func (c *DefaultClient) sniffLen() int {
+	if c.opts.SniffLen > 0 {
+		return c.opts.SniffLen
+	}
+	return DefaultSniffLen
+}
+
+// requestPayer returns the request payer to set on S3 requests, which is empty
+// unless the client has been configured for requester-pays buckets.
+func (c *DefaultClient) requestPayer() types.RequestPayer {
+	if c.opts.RequestPayer {
+		return types.RequestPayerRequester
+	}
+	return ""
+}
+
+// getObjectInput returns the GetObject parameters used to read the specified file.
+func (c *DefaultClient) getObjectInput(bucket, file string) *s3.GetObjectInput {
+	input := &s3.GetObjectInput{
+		Bucket:       &bucket,
+		Key:          &file,
+		RequestPayer: c.requestPayer(),
+	}
+	input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5 = c.sseCustomerKey()
+	return input
+}
+
+// headObjectInput returns the input of a HeadObject request for the given file, as
+// getObjectInput does for GetObject requests.
+func (c *DefaultClient) headObjectInput(bucket, file string) *s3.HeadObjectInput {
+	input := &s3.HeadObjectInput{
+		Bucket:       &bucket,
+		Key:          &file,
+		RequestPayer: c.requestPayer(),
+	}
+	input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5 = c.sseCustomerKey()
+	return input
+}
+
+// sseCustomerKey returns the algorithm, the base64 encoded key and the base64 encoded MD5
+// digest of the key sent to read objects encrypted with the key set through WithSSECustomerKey,
+// or nil values when none is set.
+func (c *DefaultClient) sseCustomerKey() (algorithm, key, keyMD5 *string) {
+	if len(c.opts.SSECustomerKey) == 0 {
+		return nil, nil, nil
+	}
+	return sseCustomerKeyHeaders(c.opts.SSECustomerKey)
+}
+
+// sseCustomerKeyHeaders returns the algorithm, the base64 encoded key and the base64 encoded
+// MD5 digest of the key sent to read objects encrypted with the given customer-provided key.
+func sseCustomerKeyHeaders(customerKey []byte) (algorithm, key, keyMD5 *string) {
+	digest := md5.Sum(customerKey)
+	return aws.String(sseCustomerAlgorithm),
+		aws.String(base64.StdEncoding.EncodeToString(customerKey)),
+		aws.String(base64.StdEncoding.EncodeToString(digest[:]))
+}
+
+// headObject fetches the metadata of the given file, following the bucket to its region when
+// auto region is enabled.
+func (c *DefaultClient) headObject(ctx context.Context, bucket, file string) (*s3.HeadObjectOutput, error) {
+	return inBucketRegion(c, bucket, func(optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+		return c.Svc.HeadObject(ctx, c.headObjectInput(bucket, file), optFns...)
+	})
+}
+
+// getObject fetches the object described by input, following the bucket to its region when
+// auto region is enabled, and making its body resume after transient errors when enabled
+// and the whole object is requested.
+func (c *DefaultClient) getObject(ctx context.Context, input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	resp, err := inBucketRegion(c, aws.ToString(input.Bucket), func(optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+		return c.Svc.GetObject(ctx, input, optFns...)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if c.opts.MaxReadResumes > 0 && input.Range == nil {
+		resp.Body = &resumableBody{
+			ctx:   ctx,
+			c:     c,
+			input: input,
+			etag:  aws.ToString(resp.ETag),
+			body:  resp.Body,
+		}
+	}
+	return resp, nil
+}
+
+// OpenFile behaves like ReadFile but fetches the object synchronously so that its
+// metadata can be returned to the caller before the contents are streamed.
+// If the object cannot be fetched, the returned ObjectInfo only holds the key and
+// the error is sent through the error channel.
+func (c *DefaultClient) OpenFile(ctx context.Context, bucket string, file string, initialBufferSize int, maxBufferSize int) (ObjectInfo, <-chan string, <-chan error) {
+	out := make(chan string)
+	errChan := make(chan error)
+	// the metrics recorder times the stream from the request of the object.
+	observedOut, observedErrChan := observeStream(c, "OpenFile", out, errChan)
+
+	c.logger().Info("Started processing file: %s from bucket: %s", file, bucket)
+
+	resp, err := c.getObject(ctx, c.getObjectInput(bucket, file))
+	if err != nil {
+		go func() {
+			defer close(errChan)
+			defer close(out)
+			errChan <- mapError(err)
+		}()
+		return ObjectInfo{Key: file}, observedOut, observedErrChan
+	}
+
+	info := newObjectInfoFromGetObject(file, resp)
+	go func() {
+		defer close(errChan)
+		defer close(out)
+		c.scanLines(bucket, info, resp.Body, initialBufferSize, maxBufferSize, out, errChan)
+	}()
+
+	return info, observedOut, observedErrChan
+}
+
+// OpenReader gets the specified file from the S3 bucket and returns its contents, decompressed
+// as ReadFile does but without splitting them into lines, for callers that parse them on their own.
+// Closing the returned reader closes the underlying object body as well.
+func (c *DefaultClient) OpenReader(ctx context.Context, bucket string, file string) (_ io.ReadCloser, err error) {
+	defer c.observe("OpenReader", time.Now(), &err)
+
+	_, reader, err := c.openReader(ctx, bucket, file)
+	return reader, err
+}
+
+// ReadAll gets the specified file from the S3 bucket and returns its contents, decompressed as
+// ReadFile does, for small objects such as configuration files or manifests. It fails with
+// ErrTooLarge instead of reading past maxBytes of decompressed content.
+func (c *DefaultClient) ReadAll(ctx context.Context, bucket string, file string, maxBytes int64) (_ []byte, err error) {
+	defer c.observe("ReadAll", time.Now(), &err)
+
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("invalid max bytes: %d", maxBytes)
+	}
+
+	info, reader, err := c.openReader(ctx, bucket, file)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	// S3 reports the size of the stored object, which is already too large if not compressed.
+	if !isCompressed(info) && info.Size > maxBytes {
+		return nil, fmt.Errorf("file %s of %d bytes exceeds %d bytes: %w", file, info.Size, maxBytes, ErrTooLarge)
+	}
+
+	// read one byte more than allowed to tell an object of exactly maxBytes from a larger one.
+	data, err := io.ReadAll(io.LimitReader(reader, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("error reading file from s3: %w", err)
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, fmt.Errorf("file %s exceeds %d bytes: %w", file, maxBytes, ErrTooLarge)
+	}
+
+	return data, nil
+}
+
+// DownloadToFile gets the specified file from the S3 bucket and writes its contents, decompressed
+// as ReadFile does, to localPath, creating its parent directories as needed. It returns the number
+// of bytes written. The contents are written to a temporary file in the same directory, renamed to
+// localPath once complete, so that a failed download leaves any existing file untouched. A file
+// replaced keeps its permissions, new ones are created with 0644.
+func (c *DefaultClient) DownloadToFile(ctx context.Context, bucket string, file string, localPath string) (_ int64, err error) {
+	defer c.observe("DownloadToFile", time.Now(), &err)
+
+	_, reader, err := c.openReader(ctx, bucket, file)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	dir := filepath.Dir(localPath)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, fmt.Errorf("error creating directory for %s: %w", localPath, err)
+	}
+
+	mode := os.FileMode(0o644)
+	if fi, err := os.Stat(localPath); err == nil {
+		mode = fi.Mode().Perm()
+	}
+
+	f, err := os.CreateTemp(dir, "."+filepath.Base(localPath)+".*.tmp")
+	if err != nil {
+		return 0, fmt.Errorf("error creating %s: %w", localPath, err)
+	}
+
+	n, err := io.Copy(f, reader)
+	if err == nil {
+		err = f.Chmod(mode)
+	}
+	if err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), localPath)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return 0, fmt.Errorf("error downloading file from s3 to %s: %w", localPath, err)
+	}
+
+	return n, nil
+}
+
+// openReader is OpenReader, also returning the metadata of the object.
+func (c *DefaultClient) openReader(ctx context.Context, bucket string, file string) (ObjectInfo, io.ReadCloser, error) {
+	resp, err := c.getObject(ctx, c.getObjectInput(bucket, file))
+	if err != nil {
+		return ObjectInfo{Key: file}, nil, fmt.Errorf("error reading file from s3: %w", mapError(err))
+	}
+
+	info := newObjectInfoFromGetObject(file, resp)
+	reader, err := c.newFileReader(info, resp.Body)
+	if err != nil {
+		resp.Body.Close()
+		return info, nil, err
+	}
+
+	return info, &layeredReader{ReadCloser: reader, inner: resp.Body}, nil
+}
+
+// logger returns the logger of the client, falling back to the one of its options and then to
+// one discarding everything, so that a DefaultClient built without a Logger doesn't panic on its
+// first log.
+func (c *DefaultClient) logger() Logger {
+	if c.Logger != nil {
+		return c.Logger
+	}
+	return c.opts.logger()
+}
+
+// nopLogger is a Logger discarding everything logged to it.
+type nopLogger struct{}
+
+func (nopLogger) Error(string, ...any) {}
+func (nopLogger) Warn(string, ...any)  {}
+func (nopLogger) Info(string, ...any)  {}
+func (nopLogger) Debug(string, ...any) {}
+
+// newFileReader returns a reader over the decompressed contents of the given object body,
+// selected based on the object's key and detected through a window of at most sniffLen bytes.
+// Closing the returned reader does not close the body.
+func (c *DefaultClient) newFileReader(info ObjectInfo, body io.Reader) (io.ReadCloser, error) {
+	reader, err := c.decompressReader(info, body)
+	if err != nil || !c.opts.DetectGzippedTar || !isGzipped(info) {
+		return reader, err
+	}
+	return newDetectedTarReader(reader), nil
+}
+
+// decompressReader returns a reader over the decompressed contents of the given object body,
+// as newFileReader does, but reading tar archives detected in gzipped objects as they are.
+func (c *DefaultClient) decompressReader(info ObjectInfo, body io.Reader) (io.ReadCloser, error) {
+	if c.opts.ProgressCallback != nil {
+		body = &progressReader{r: body, interval: progressInterval, fn: c.opts.ProgressCallback}
+	}
+	// The transport doesn't decompress responses, so a gzip Content-Encoding is decoded here,
+	// below any decoding based on the file's extension.
+	return GetReaderForContentType("", info.ContentEncoding, info.Key)(bufio.NewReaderSize(body, c.sniffLen()))
+}
+
+// scanLines decodes the given object body based on the object's key and metadata
+// and sends its contents line by line through out. Any error is sent through errChan.
+// The body is always closed before returning.
+func (c *DefaultClient) scanLines(bucket string, info ObjectInfo, body io.ReadCloser, initialBufferSize, maxBufferSize int, out chan<- string, errChan chan<- error) {
+	c.scanObject(bucket, info, body, initialBufferSize, maxBufferSize, func(line Line) {
+		out <- line.Text
+	}, errChan)
+}
+
+// scanObject reads the given object body as scanLines does, passing every line to emit
+// along with its number. Lines skipped for being too long are counted all the same.
+func (c *DefaultClient) scanObject(bucket string, info ObjectInfo, body io.ReadCloser, initialBufferSize, maxBufferSize int, emit func(Line), errChan chan<- error) {
+	file := info.Key
+	lineNum := 0
+	maxBufferSize = c.opts.maxBufferSize(file, maxBufferSize)
+
+	// Ensure the file's body stream is closed when done.
+	// Close the filename body when the function exits
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			// Send the error to the error channel
+			errChan <- err
+			return
+		}
+	}(body)
+
+	// Get a reader for the file based on its format/type.
+	reader, err := c.newFileReader(info, body)
+	if err != nil {
+		// On error, send to error channel and exit.
+		errChan <- err
+		return
+	}
+
+	// Keep what was decompressed before the end of a truncated object, if asked to.
+	var truncation *truncationReader
+	if c.opts.SalvageTruncated && isCompressed(info) {
+		truncation = &truncationReader{ReadCloser: reader}
+		reader = truncation
+	}
+
+	// Ensure the reader is closed when done.
+	defer func(reader io.ReadCloser) {
+		err := reader.Close()
+		if err != nil {
+			errChan <- err
+			return
+		}
+	}(reader)
+
+	// Decode the contents into UTF-8 if a charset has been registered for the file.
+	var decoded io.Reader = reader
+	if enc := charsetFor(file, info.ContentType); enc != nil {
+		decoded = transform.NewReader(decoded, enc.NewDecoder())
+	}
+
+	// Tell text from binary content by its first bytes when the object isn't labeled.
+	if c.opts.DetectContentType && isUnlabeledContentType(info.ContentType) {
+		var detected string
+		decoded, detected = sniffContentType(decoded)
+		c.logger().Debug("detected content type: %s for file: %s from bucket: %s", detected, file, bucket)
+		if !strings.HasPrefix(detected, "text/") {
+			errChan <- fmt.Errorf("file %s detected as %s: %w", file, detected, ErrBinaryContent)
+			return
+		}
+	}
+
+	// Create a scanner to read the file contents.
+	scanner := bufio.NewScanner(decoded)
+
+	// Initialize a buffer for the scanner, setting its initial and maximum sizes.
+	buf := make([]byte, 0, scanBufferSize(info, initialBufferSize, maxBufferSize))
+	scanner.Buffer(buf, maxBufferSize)
+
+	split := bufio.ScanLines
+	if c.opts.SkipLongLines {
+		split = skipLongLines(max(initialBufferSize, maxBufferSize), func() {
+			lineNum++
+			c.logger().Warn("Skipped a line that was too long to read in file: %s from bucket: %s, exceeds > %d", file, bucket, maxBufferSize)
+		})
+	}
+	if truncation != nil {
+		split = truncation.dropIncompleteLine(split)
+	}
+	scanner.Split(split)
+
+	// Read the file line by line.
+	for scanner.Scan() {
+		lineNum++
+		emit(Line{LineNum: lineNum, Text: scanner.Text()})
+	}
+
+	// Check for any scanning errors.
+	if err := scanner.Err(); err != nil {
+		// If the error is due to a line being too long, log a specific message.
+		if errors.Is(err, bufio.ErrTooLong) {
+			c.logger().Error("Encountered a line that was too long to read in file: %s from bucket: %s, exceeds > %d", file, bucket, maxBufferSize)
+		}
+
+		// Send the error to the error channel and exit.
+		errChan <- err
+		return
+	}
+
+	if truncation != nil && truncation.truncated {
+		c.logger().Warn("file: %s from bucket: %s is truncated, only the lines decompressed before its end were read", file, bucket)
+	}
+
+	// Log completion of file processing.
+	c.logger().Info("Completed processing of file: %s on bucket: %s", file, bucket)
+}
+
+// truncationReader reads a decompressed stream, ending it with io.EOF instead of the
+// io.ErrUnexpectedEOF a truncated compressed object ends with, so that the content
+// decompressed up to the truncation is still scanned.
+type truncationReader struct {
+	io.ReadCloser
+	truncated bool
+}
+
+func (r *truncationReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		r.truncated = true
+		err = io.EOF
+	}
+	return n, err
+}
+
+// Close closes the decompressed stream, which reports the truncation again once it is known.
+func (r *truncationReader) Close() error {
+	err := r.ReadCloser.Close()
+	if r.truncated && errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil
+	}
+	return err
+}
+
+// dropIncompleteLine wraps split to drop the data left after the last line break of the
+// stream once it turns out to be truncated, as it is the start of a line that was cut short.
+func (r *truncationReader) dropIncompleteLine(split bufio.SplitFunc) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if atEOF && r.truncated && bytes.IndexByte(data, '\n') < 0 {
+			return len(data), nil, nil
+		}
+		return split(data, atEOF)
+	}
+}
+
+// scanBufferSize returns the initial size of the buffer lines of the object are scanned with.
+// The size of uncompressed objects is known, so their buffer is sized to hold them whole, up
+// to maxBufferSize: it never grows then, however long their lines are, and small objects take
+// less than initialBufferSize. Compressed objects, or those of unknown size, start with
+// initialBufferSize.
+// As measured by BenchmarkDefaultClient_ReadFile, with a 64 KiB initial buffer, reading a 4 MiB
+// single line object allocates 8 MiB instead of 20 MiB, half of it being the line itself, and a
+// 1 KiB object 4 KiB instead of 67 KiB. Objects of many short lines instead hold a buffer of
+// their size, bounded by maxBufferSize, rather than initialBufferSize while they are read.
+func scanBufferSize(info ObjectInfo, initialBufferSize, maxBufferSize int) int {
+	if info.Size <= 0 || isCompressed(info) {
+		return initialBufferSize
+	}
+	// one more byte lets the scanner detect the end of the object without growing the buffer.
+	return int(min(info.Size+1, int64(max(maxBufferSize, initialBufferSize))))
+}
+
+// WriteFile uploads the contents of body to the specified file in the given S3 bucket
+// using a single PutObject call. Server-side encryption is applied when configured
+// through WithServerSideEncryption.
+// When talking to a plain HTTP endpoint the body must implement io.Seeker, as the
+// SDK needs to compute the payload hash before sending the request.
+func (c *DefaultClient) WriteFile(ctx context.Context, bucket string, file string, body io.Reader) (err error) {
+	defer c.observe("WriteFile", time.Now(), &err)
+
+	c.logger().Debug("writing file: %s to bucket: %s", file, bucket)
+
+	input := &s3.PutObjectInput{
+		Bucket:       &bucket,
+		Key:          &file,
+		Body:         body,
+		RequestPayer: c.requestPayer(),
+	}
+	if c.opts.ServerSideEncryption != "" {
+		input.ServerSideEncryption = types.ServerSideEncryption(c.opts.ServerSideEncryption)
+	}
+	if c.opts.SSEKMSKeyID != "" {
+		input.SSEKMSKeyId = aws.String(c.opts.SSEKMSKeyID)
+	}
+	input.ChecksumAlgorithm = types.ChecksumAlgorithm(c.opts.ChecksumAlgorithm)
+
+	_, err = c.Svc.PutObject(ctx, input)
+	if err != nil {
+		return fmt.Errorf("error writing file to s3: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// WriteFileMultipart writes the contents of body to the specified file in the S3 bucket with
+// a multipart upload, reading and sending it partSize bytes at a time so that only one part is
+// held in memory. Parts must be at least 5 MiB, except for the last one, and an upload can have
+// up to 10000 parts. Bodies that fit in a single part are written with a single request instead.
+// On error, the multipart upload is aborted so that its parts are not left behind.
+func (c *DefaultClient) WriteFileMultipart(ctx context.Context, bucket string, file string, body io.Reader, partSize int64) (err error) {
+	defer c.observe("WriteFileMultipart", time.Now(), &err)
+
+	if partSize < minPartSize {
+		return fmt.Errorf("part size must be at least %d bytes, got %d", minPartSize, partSize)
+	}
+
+	buf := make([]byte, partSize)
+	n, err := io.ReadFull(body, buf)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return c.WriteFile(ctx, bucket, file, bytes.NewReader(buf[:n]))
+	}
+	if err != nil {
+		return fmt.Errorf("error reading file body: %w", err)
+	}
+
+	c.logger().Debug("writing file: %s to bucket: %s with a multipart upload", file, bucket)
+
+	input := &s3.CreateMultipartUploadInput{
+		Bucket:       &bucket,
+		Key:          &file,
+		RequestPayer: c.requestPayer(),
+	}
+	if c.opts.ServerSideEncryption != "" {
+		input.ServerSideEncryption = types.ServerSideEncryption(c.opts.ServerSideEncryption)
+	}
+	if c.opts.SSEKMSKeyID != "" {
+		input.SSEKMSKeyId = aws.String(c.opts.SSEKMSKeyID)
+	}
+	input.ChecksumAlgorithm = types.ChecksumAlgorithm(c.opts.ChecksumAlgorithm)
+
+	upload, err := c.Svc.CreateMultipartUpload(ctx, input)
+	if err != nil {
+		return fmt.Errorf("error writing file to s3: %w", mapError(err))
+	}
+
+	parts, err := c.uploadParts(ctx, bucket, file, upload.UploadId, body, buf, n)
+	if err == nil {
+		_, err = c.Svc.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:          &bucket,
+			Key:             &file,
+			UploadId:        upload.UploadId,
+			MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+			RequestPayer:    c.requestPayer(),
+		})
+	}
+	if err != nil {
+		// abort even if ctx is done, otherwise the uploaded parts are kept and billed.
+		_, abortErr := c.Svc.AbortMultipartUpload(context.WithoutCancel(ctx), &s3.AbortMultipartUploadInput{
+			Bucket:       &bucket,
+			Key:          &file,
+			UploadId:     upload.UploadId,
+			RequestPayer: c.requestPayer(),
+		})
+		if abortErr != nil {
+			c.logger().Error("error aborting multipart upload of file: %s to bucket: %s: %v", file, bucket, abortErr)
+		}
+		return fmt.Errorf("error writing file to s3: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// uploadParts uploads the parts of a multipart upload read from body, the first one of which,
+// n bytes long, has already been read into buf.
+func (c *DefaultClient) uploadParts(ctx context.Context, bucket string, file string, uploadID *string, body io.Reader, buf []byte, n int) ([]types.CompletedPart, error) {
+	var parts []types.CompletedPart
+	for number := int32(1); ; number++ {
+		if number > maxParts {
+			return parts, fmt.Errorf("file exceeds %d parts of %d bytes", maxParts, len(buf))
+		}
+
+		resp, err := c.Svc.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:            &bucket,
+			Key:               &file,
+			UploadId:          uploadID,
+			PartNumber:        aws.Int32(number),
+			Body:              bytes.NewReader(buf[:n]),
+			ContentLength:     aws.Int64(int64(n)),
+			ChecksumAlgorithm: types.ChecksumAlgorithm(c.opts.ChecksumAlgorithm),
+			RequestPayer:      c.requestPayer(),
+		})
+		if err != nil {
+			return parts, err
+		}
+		// uploads created with a checksum algorithm are only completed with the checksums of their parts.
+		parts = append(parts, types.CompletedPart{
+			ETag:           resp.ETag,
+			PartNumber:     aws.Int32(number),
+			ChecksumCRC32:  resp.ChecksumCRC32,
+			ChecksumCRC32C: resp.ChecksumCRC32C,
+			ChecksumSHA1:   resp.ChecksumSHA1,
+			ChecksumSHA256: resp.ChecksumSHA256,
+		})
+
+		n, err = io.ReadFull(body, buf)
+		if n == 0 && (errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)) {
+			return parts, nil
+		}
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return parts, fmt.Errorf("error reading file body: %w", err)
+		}
+	}
+}
+
+// DeleteFileIfMatch deletes the specified file from the given S3 bucket only if its
+// current ETag matches etag. The object is checked with HeadObject right before the
+// delete, and ErrPreconditionFailed is returned when the ETags differ.
+func (c *DefaultClient) DeleteFileIfMatch(ctx context.Context, bucket string, file string, etag string) (err error) {
+	defer c.observe("DeleteFileIfMatch", time.Now(), &err)
+
+	head, err := c.headObject(ctx, bucket, file)
+	if err != nil {
+		return fmt.Errorf("error checking file on s3: %w", mapError(err))
+	}
+
+	current := aws.ToString(head.ETag)
+	if trimETag(current) != trimETag(etag) {
+		c.logger().Debug("not deleting file: %s from bucket: %s, etag: %s does not match: %s", file, bucket, current, etag)
+		return fmt.Errorf("file %q etag %s does not match %s: %w", file, current, etag, ErrPreconditionFailed)
+	}
+
+	c.logger().Debug("deleting file: %s from bucket: %s with etag: %s", file, bucket, current)
+	_, err = c.Svc.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket:       &bucket,
+		Key:          &file,
+		RequestPayer: c.requestPayer(),
+	})
+	if err != nil {
+		return fmt.Errorf("error deleting file from s3: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// CopyFile copies the specified file from the source bucket to the destination bucket and file,
+// both buckets may be the same.
+func (c *DefaultClient) CopyFile(ctx context.Context, srcBucket string, srcFile string, dstBucket string, dstFile string) (err error) {
+	defer c.observe("CopyFile", time.Now(), &err)
+
+	c.logger().Debug("copying file: %s from bucket: %s to file: %s on bucket: %s", srcFile, srcBucket, dstFile, dstBucket)
+
+	input := &s3.CopyObjectInput{
+		Bucket:       &dstBucket,
+		Key:          &dstFile,
+		CopySource:   aws.String(copySource(srcBucket, srcFile)),
+		RequestPayer: c.requestPayer(),
+	}
+	if c.opts.ServerSideEncryption != "" {
+		input.ServerSideEncryption = types.ServerSideEncryption(c.opts.ServerSideEncryption)
+	}
+	if c.opts.SSEKMSKeyID != "" {
+		input.SSEKMSKeyId = aws.String(c.opts.SSEKMSKeyID)
+	}
+	// the source is decrypted and the copy encrypted with the same customer-provided key.
+	input.CopySourceSSECustomerAlgorithm, input.CopySourceSSECustomerKey, input.CopySourceSSECustomerKeyMD5 = c.sseCustomerKey()
+	input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5 = c.sseCustomerKey()
+
+	_, err = c.Svc.CopyObject(ctx, input)
+	if err != nil {
+		return fmt.Errorf("error copying file on s3: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// MoveFile moves the specified file from the source bucket to the destination bucket and file,
+// copying it and then deleting the source. If the copy fails, the source is left in place.
+func (c *DefaultClient) MoveFile(ctx context.Context, srcBucket string, srcFile string, dstBucket string, dstFile string) (err error) {
+	defer c.observe("MoveFile", time.Now(), &err)
+
+	if srcBucket == dstBucket && srcFile == dstFile {
+		return fmt.Errorf("cannot move file %q onto itself", srcFile)
+	}
+
+	if err := c.CopyFile(ctx, srcBucket, srcFile, dstBucket, dstFile); err != nil {
+		return err
+	}
+
+	c.logger().Debug("deleting moved file: %s from bucket: %s", srcFile, srcBucket)
+	_, err = c.Svc.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket:       &srcBucket,
+		Key:          &srcFile,
+		RequestPayer: c.requestPayer(),
+	})
+	if err != nil {
+		return fmt.Errorf("error deleting file from s3: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// GetObjectTags returns the tags set on the specified file in the S3 bucket.
+func (c *DefaultClient) GetObjectTags(ctx context.Context, bucket string, file string) (_ map[string]string, err error) {
+	defer c.observe("GetObjectTags", time.Now(), &err)
+
+	resp, err := c.Svc.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{
+		Bucket:       &bucket,
+		Key:          &file,
+		RequestPayer: c.requestPayer(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting file tags from s3: %w", mapError(err))
+	}
+
+	tags := make(map[string]string, len(resp.TagSet))
+	for _, tag := range resp.TagSet {
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return tags, nil
+}
+
+// PutObjectTags replaces the tags set on the specified file in the S3 bucket with the given ones.
+func (c *DefaultClient) PutObjectTags(ctx context.Context, bucket string, file string, tags map[string]string) (err error) {
+	defer c.observe("PutObjectTags", time.Now(), &err)
+
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	tagSet := make([]types.Tag, 0, len(keys))
+	for _, key := range keys {
+		tagSet = append(tagSet, types.Tag{Key: aws.String(key), Value: aws.String(tags[key])})
+	}
+
+	c.logger().Debug("putting %d tag(s) on file: %s from bucket: %s", len(tagSet), file, bucket)
+	_, err = c.Svc.PutObjectTagging(ctx, &s3.PutObjectTaggingInput{
+		Bucket:       &bucket,
+		Key:          &file,
+		Tagging:      &types.Tagging{TagSet: tagSet},
+		RequestPayer: c.requestPayer(),
+	})
+	if err != nil {
+		return fmt.Errorf("error putting file tags to s3: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// GetObjectRetention returns the object lock retention of the specified file in the S3 bucket.
+// Objects without retention, including those of buckets without object lock, get a zero RetentionInfo.
+func (c *DefaultClient) GetObjectRetention(ctx context.Context, bucket string, file string) (_ RetentionInfo, err error) {
+	defer c.observe("GetObjectRetention", time.Now(), &err)
+
+	resp, err := c.Svc.GetObjectRetention(ctx, &s3.GetObjectRetentionInput{
+		Bucket:       &bucket,
+		Key:          &file,
+		RequestPayer: c.requestPayer(),
+	})
+	if err != nil {
+		if isNoObjectLock(err) {
+			return RetentionInfo{}, nil
+		}
+		return RetentionInfo{}, fmt.Errorf("error getting file retention from s3: %w", mapError(err))
+	}
+	if resp.Retention == nil {
+		return RetentionInfo{}, nil
+	}
+
+	return RetentionInfo{
+		Mode:        string(resp.Retention.Mode),
+		RetainUntil: aws.ToTime(resp.Retention.RetainUntilDate),
+	}, nil
+}
+
+// Retained reports whether the object can't be deleted at the given time because of its retention.
+func (r RetentionInfo) Retained(at time.Time) bool {
+	return r.Mode != "" && at.Before(r.RetainUntil)
+}
+
+// GetObjectLegalHold reports whether the specified file in the S3 bucket is under legal hold.
+// Objects without legal hold, including those of buckets without object lock, are not.
+func (c *DefaultClient) GetObjectLegalHold(ctx context.Context, bucket string, file string) (_ bool, err error) {
+	defer c.observe("GetObjectLegalHold", time.Now(), &err)
+
+	resp, err := c.Svc.GetObjectLegalHold(ctx, &s3.GetObjectLegalHoldInput{
+		Bucket:       &bucket,
+		Key:          &file,
+		RequestPayer: c.requestPayer(),
+	})
+	if err != nil {
+		if isNoObjectLock(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error getting file legal hold from s3: %w", mapError(err))
+	}
+
+	return resp.LegalHold != nil && resp.LegalHold.Status == types.ObjectLockLegalHoldStatusOn, nil
+}
+
+// isNoObjectLock reports whether err is S3 reporting that the object has no retention or legal
+// hold set, or that its bucket doesn't have object lock enabled at all.
+func isNoObjectLock(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.ErrorCode() {
+	case "NoSuchObjectLockConfiguration", "ObjectLockConfigurationNotFoundError":
+		return true
+	}
+	return false
+}
+
+// BucketExists reports whether the specified bucket exists and is accessible with the
+// configured credentials.
+func (c *DefaultClient) BucketExists(ctx context.Context, bucket string) (_ bool, err error) {
+	defer c.observe("BucketExists", time.Now(), &err)
+
+	_, err = inBucketRegion(c, bucket, func(optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error) {
+		return c.Svc.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: &bucket}, optFns...)
+	})
+	if err != nil {
+		err = mapError(err)
+		// HeadBucket reports a missing bucket with a bare NotFound status.
+		if errors.Is(err, ErrNoSuchBucket) || errors.Is(err, ErrNoSuchKey) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error checking bucket in s3: %w", err)
+	}
+
+	return true, nil
+}
+
+// CreateBucket creates the specified bucket in the given region, or in the region of
+// the client when empty.
+func (c *DefaultClient) CreateBucket(ctx context.Context, bucket string, region string) (err error) {
+	defer c.observe("CreateBucket", time.Now(), &err)
+
+	if region == "" {
+		region = c.opts.Region
+	}
+
+	input := &s3.CreateBucketInput{Bucket: &bucket}
+	// us-east-1 is the default location, and S3 rejects it as an explicit location constraint.
+	if region != "" && region != "us-east-1" {
+		input.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+			LocationConstraint: types.BucketLocationConstraint(region),
+		}
+	}
+
+	// the request is sent to the endpoint of the bucket's region, as S3 rejects location
+	// constraints other than their own, and the us-east-1 one implies no constraint.
+	var optFns []func(*s3.Options)
+	if region != "" && region != c.opts.Region {
+		optFns = append(optFns, withRegion(region))
+	}
+
+	c.logger().Debug("creating bucket: %s in region: %s", bucket, region)
+	if _, err := c.Svc.CreateBucket(ctx, input, optFns...); err != nil {
+		return fmt.Errorf("error creating bucket in s3: %w", mapError(err))
+	}
+
+	return nil
+}
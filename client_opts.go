@@ -1,15 +1,42 @@
 package s3client
 
 import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go/middleware"
 )
 
+// AssumeRoleStep is a single hop of a chain of assumed roles.
+type AssumeRoleStep struct {
+	// RoleARN is the role to assume.
+	RoleARN string
+	// ExternalID is the external ID required to assume the role, if any.
+	ExternalID string
+	// SessionName is the name of the role session.
+	SessionName string
+	// Duration is the duration of the role session.
+	Duration time.Duration
+}
+
 // ClientOpts represents options for configuring an S3 client.
 type ClientOpts struct {
 	// Region is the AWS region to connect to.
@@ -21,6 +48,7 @@ type ClientOpts struct {
 	// SecretKey is the secret key to use for authentication.
 	SecretKey string
 	// AssumeRoleARN is the part of assume role parameter for assume role authentication.
+	// When static credentials are set too, they are the ones the role is assumed with.
 	AssumeRoleARN string
 	// AssumeRoleSessionName is the part of assume role parameter for assume role authentication.
 	AssumeRoleSessionName string
@@ -28,8 +56,241 @@ type ClientOpts struct {
 	AssumeRoleExternalID string
 	// AssumeRoleDuration is the part of assume role parameter for assume role authentication.
 	AssumeRoleDuration *time.Duration
+	// AssumeRoleMFASerial is the identification number of the MFA device used for assume role authentication.
+	AssumeRoleMFASerial string
+	// AssumeRoleTokenProvider returns the MFA token code used for assume role authentication.
+	AssumeRoleTokenProvider func() (string, error)
+	// AssumeRoleChain is the ordered list of roles to assume, each using the credentials of the previous one.
+	AssumeRoleChain []AssumeRoleStep
+	// WebIdentityRoleARN is the role assumed with web identity authentication.
+	WebIdentityRoleARN string
+	// WebIdentityTokenFile is the path to the web identity token used with web identity authentication.
+	WebIdentityTokenFile string
+	// WebIdentitySessionName is the session name used with web identity authentication.
+	WebIdentitySessionName string
+	// RolesAnywhereTrustAnchorARN is the trust anchor that issued RolesAnywhereCertificate,
+	// used with IAM Roles Anywhere authentication.
+	RolesAnywhereTrustAnchorARN string
+	// RolesAnywhereProfileARN is the profile used with IAM Roles Anywhere authentication.
+	RolesAnywhereProfileARN string
+	// RolesAnywhereRoleARN is the role assumed with IAM Roles Anywhere authentication.
+	RolesAnywhereRoleARN string
+	// RolesAnywhereCertificate is the certificate, along with its private key and any
+	// intermediate certificates, sessions are created with for IAM Roles Anywhere authentication.
+	RolesAnywhereCertificate *tls.Certificate
 	// EC2IMDSClientEnableState is used for IMDS authentication.
 	EC2IMDSClientEnableState *imds.ClientEnableState
+	// NoEC2IMDS disables IMDS regardless of EC2IMDSClientEnableState.
+	NoEC2IMDS bool
+	// ServerSideEncryption is the server-side encryption algorithm applied to written objects.
+	ServerSideEncryption string
+	// SSEKMSKeyID is the KMS key ID used when ServerSideEncryption is aws:kms.
+	SSEKMSKeyID string
+	// ChecksumAlgorithm is the algorithm of the checksum uploads are sent with, such as CRC32,
+	// none when empty.
+	ChecksumAlgorithm string
+	// SniffLen is the number of bytes peeked from an object to detect its format.
+	// Defaults to DefaultSniffLen.
+	SniffLen int
+	// RequestPayer confirms that the requester pays for requests made against requester-pays buckets.
+	RequestPayer bool
+	// ProgressCallback is called with the number of bytes read so far while an object is consumed.
+	ProgressCallback func(bytesRead int64)
+	// S3Compatible makes the client use Endpoint as is, without rewriting its hostname,
+	// regardless of the region. Setting Region to "minio" has the same effect.
+	S3Compatible bool
+	// AutoRegion makes the client retry requests against the bucket's actual region
+	// when S3 reports that it lives in a different one.
+	AutoRegion bool
+	// MaxReadResumes is the number of times reading an object's body is resumed from the
+	// last byte read after a transient error. Zero disables resuming.
+	MaxReadResumes int
+	// SkipLongLines makes line reads log and skip lines longer than the maximum buffer size,
+	// instead of failing with bufio.ErrTooLong.
+	SkipLongLines bool
+	// DetectContentType makes line reads of objects labeled with a generic binary content type
+	// detect their content type from their first bytes, failing with ErrBinaryContent unless it is text.
+	DetectContentType bool
+	// DetectGzippedTar makes reads of gzipped objects check whether their decompressed content is
+	// a tar archive, reading the contents of its files in place of the archive when it is.
+	DetectGzippedTar bool
+	// MaxBufferSizes are the maximum buffer sizes line reads use for the files with the given
+	// lower-cased extensions, such as ".jsonl", in place of the one they are called with.
+	MaxBufferSizes map[string]int
+	// SalvageTruncated makes line reads of compressed objects that end abruptly, such as gzip
+	// files whose writer crashed mid-flush, keep the lines decompressed before the truncation
+	// and log it as a warning, instead of failing with io.ErrUnexpectedEOF.
+	SalvageTruncated bool
+	// CredentialsProvider is a custom provider of the credentials used to sign requests.
+	CredentialsProvider aws.CredentialsProvider
+	// UseFIPSEndpoint makes the client use FIPS 140-2 validated endpoints.
+	// It is ignored when a custom Endpoint is set.
+	UseFIPSEndpoint bool
+	// UseDualStackEndpoint makes the client use endpoints reachable over both IPv4 and IPv6.
+	// It is ignored when a custom Endpoint is set.
+	UseDualStackEndpoint bool
+	// Accelerate makes the client use S3 Transfer Acceleration endpoints. The bucket name is
+	// then part of the hostname, so it can't be combined with S3 compatible stores, which
+	// require path-style addressing.
+	Accelerate bool
+	// UseARNRegion makes requests to access points given by their ARN go to the region of the
+	// ARN when it differs from Region, which fail otherwise.
+	UseARNRegion bool
+	// SigningRegion is the region requests are signed for with SigV4, when it differs from Region,
+	// as with some S3 compatible stores. Defaults to Region.
+	SigningRegion string
+	// Anonymous makes the client send unsigned requests, to read from public buckets.
+	Anonymous bool
+	// UserAgentAppend holds the product tokens, e.g. "product/version", appended to the
+	// User-Agent of every request.
+	UserAgentAppend []string
+	// EndpointResolver resolves the endpoint of every request, taking precedence over
+	// Endpoint, S3Compatible and the "minio" region.
+	EndpointResolver s3.EndpointResolverV2
+	// MaxIdleConns is the number of idle connections kept open for reuse, per host and overall.
+	// Defaults to DefaultMaxIdleConns.
+	MaxIdleConns int
+	// MaxConnsPerHost is the number of connections open at once to a host, past which requests
+	// wait for one to be released. Defaults to DefaultMaxConnsPerHost.
+	MaxConnsPerHost int
+	// ListConcurrency is the number of prefixes listed at once when matching patterns that
+	// cover several of them. Defaults to DefaultListConcurrency.
+	ListConcurrency int
+	// ListPageSize is the number of keys listed per ListObjectsV2 and ListObjectVersions page,
+	// from 1 to 1000. S3 lists up to 1000 of them when zero.
+	ListPageSize int
+	// CaseInsensitiveMatch makes listings match keys against patterns regardless of case.
+	CaseInsensitiveMatch bool
+	// ListThrottleThreshold is the number of consecutive throttle errors listing requests get
+	// before listings are aborted with ErrListThrottled. Zero leaves them to the SDK's retries.
+	ListThrottleThreshold int
+	// ListThrottleCooldown is how long listings fail fast after ListThrottleThreshold is reached.
+	ListThrottleCooldown time.Duration
+	// MetricsRecorder is notified of the duration and outcome of every operation.
+	MetricsRecorder MetricsRecorder
+	// APIOptions are extra functions adding middleware to the stack of every S3 API call,
+	// e.g. for tracing.
+	APIOptions []func(*middleware.Stack) error
+	// SSECustomerKey is the 256-bit key objects encrypted with customer-provided keys (SSE-C) are
+	// read and copied with.
+	SSECustomerKey []byte
+	// TLSConfig is the TLS configuration of the connections to S3, e.g. to trust the CA of a
+	// self-hosted store. It can't be set along HTTPClient.
+	TLSConfig *tls.Config
+	// InsecureSkipVerify disables the verification of the certificate S3 presents, leaving the
+	// connections open to interception. It can't be set along HTTPClient.
+	InsecureSkipVerify bool
+	// HTTPClient is the HTTP client requests are sent with, replacing the one the client builds,
+	// along with its connection limits and TLS configuration.
+	HTTPClient *http.Client
+	// SharedCredentials makes clients created with the same credentials options share their
+	// credentials, so they are only resolved and refreshed once for all of them.
+	SharedCredentials bool
+	// CredentialsExpiryWindow is how long before they expire shared credentials are refreshed.
+	// Defaults to DefaultCredentialsExpiryWindow.
+	CredentialsExpiryWindow time.Duration
+	// Logger is the logger the client reports its progress to, none when nil.
+	Logger Logger
+}
+
+const (
+	// DefaultMaxIdleConns is the number of idle connections kept open when not set with WithMaxIdleConns.
+	DefaultMaxIdleConns = 100
+	// DefaultMaxConnsPerHost is the number of connections open at once to a host when not set
+	// with WithMaxConnsPerHost. It bounds the file descriptors many concurrent reads can take.
+	DefaultMaxConnsPerHost = 256
+	// DefaultListConcurrency is the number of prefixes listed at once when not set with WithListConcurrency.
+	DefaultListConcurrency = 4
+	// sseCustomerAlgorithm is the only algorithm S3 supports for customer-provided keys.
+	sseCustomerAlgorithm = "AES256"
+	// sseCustomerKeyLen is the length in bytes of the keys used with sseCustomerAlgorithm.
+	sseCustomerKeyLen = 32
+	// defaultIdleConnTimeout is how long an idle connection is kept open before being closed.
+	defaultIdleConnTimeout = 90 * time.Second
+)
+
+const (
+	// minAssumeRoleDuration is the shortest role session STS accepts.
+	minAssumeRoleDuration = 15 * time.Minute
+	// maxAssumeRoleDuration is the longest role session STS accepts.
+	maxAssumeRoleDuration = 12 * time.Hour
+)
+
+// validate returns an error describing the first impossible combination
+// or malformed value found on the options.
+func (o *ClientOpts) validate() error {
+	if (o.AccessKey == "") != (o.SecretKey == "") {
+		return errors.New("static credentials require both an access key and a secret key")
+	}
+
+	var methods []string
+	if o.hasStaticCredentials() {
+		methods = append(methods, "static credentials")
+	}
+	if o.CredentialsProvider != nil {
+		methods = append(methods, "credentials provider")
+	}
+	if o.Anonymous {
+		methods = append(methods, "anonymous credentials")
+	}
+	// static credentials are the source credentials the role is assumed with.
+	if o.AssumeRoleARN != "" && !o.hasStaticCredentials() {
+		methods = append(methods, "assume role")
+	}
+	if o.WebIdentityRoleARN != "" {
+		methods = append(methods, "web identity")
+	}
+	if o.RolesAnywhereRoleARN != "" {
+		methods = append(methods, "roles anywhere")
+	}
+	if len(methods) > 1 {
+		return fmt.Errorf("conflicting authentication methods: %s", strings.Join(methods, ", "))
+	}
+
+	if o.AssumeRoleARN == "" && (o.AssumeRoleSessionName != "" || o.AssumeRoleExternalID != "" || o.AssumeRoleDuration != nil || o.AssumeRoleMFASerial != "") {
+		return errors.New("assume role parameters require an assume role arn")
+	}
+	if o.AssumeRoleDuration != nil {
+		if err := validateAssumeRoleDuration(*o.AssumeRoleDuration); err != nil {
+			return err
+		}
+	}
+	for i, step := range o.AssumeRoleChain {
+		if step.RoleARN == "" {
+			return fmt.Errorf("assume role chain step %d is missing a role arn", i)
+		}
+		if step.Duration != 0 {
+			if err := validateAssumeRoleDuration(step.Duration); err != nil {
+				return fmt.Errorf("assume role chain step %d: %w", i, err)
+			}
+		}
+	}
+
+	if o.WebIdentityRoleARN != "" && o.WebIdentityTokenFile == "" {
+		return errors.New("web identity requires a token file")
+	}
+
+	if o.Accelerate && (o.Region == "minio" || o.S3Compatible || o.Endpoint != "") {
+		return errors.New("transfer acceleration cannot be used with path-style addressing of custom endpoints")
+	}
+
+	if o.HTTPClient != nil && (o.TLSConfig != nil || o.InsecureSkipVerify) {
+		return errors.New("tls options cannot be used with a custom http client, configure its transport instead")
+	}
+
+	if o.SSEKMSKeyID != "" && o.ServerSideEncryption == "" {
+		return errors.New("kms key id requires server-side encryption")
+	}
+
+	return nil
+}
+
+// validateAssumeRoleDuration returns an error if d is outside the range STS accepts.
+func validateAssumeRoleDuration(d time.Duration) error {
+	if d < minAssumeRoleDuration || d > maxAssumeRoleDuration {
+		return fmt.Errorf("assume role duration %s must be between %s and %s", d, minAssumeRoleDuration, maxAssumeRoleDuration)
+	}
+	return nil
 }
 
 // LoadOptions returns a slice of functions that can be passed to the config.Load function
@@ -37,41 +298,56 @@ type ClientOpts struct {
 func (o *ClientOpts) LoadOptions() []func(options *config.LoadOptions) error {
 	var loadOpts []func(options *config.LoadOptions) error
 
+	if (o.Region == "minio" || o.S3Compatible) && o.EndpointResolver == nil {
+		//nolint:staticcheck
+		// This is a special case for minio and other S3 compatible stores.
+		//	https://github.com/minio/minio/discussions/12030#discussioncomment-590564
+		//	this is backwards compatible flag to make it work with minio.
+		loadOpts = append(loadOpts, config.WithEndpointResolverWithOptions(
+			aws.EndpointResolverWithOptionsFunc(
+				func(service string, region string, options ...interface{}) (aws.Endpoint, error) {
+					signingRegion := region
+					if o.SigningRegion != "" {
+						signingRegion = o.SigningRegion
+					}
+					return aws.Endpoint{
+						URL:               o.Endpoint,
+						SigningRegion:     signingRegion,
+						HostnameImmutable: true,
+					}, nil
+				},
+			),
+		))
+	}
+
 	if o.Region != "" {
-		if o.Region == "minio" {
-			//nolint:staticcheck
-			// This is a special case for minio.
-			//	https://github.com/minio/minio/discussions/12030#discussioncomment-590564
-			//	this is backwards compatible flag to make it work with minio.
-			loadOpts = append(loadOpts, config.WithEndpointResolverWithOptions(
-				aws.EndpointResolverWithOptionsFunc(
-					func(service string, region string, options ...interface{}) (aws.Endpoint, error) {
-						return aws.Endpoint{
-							URL:               o.Endpoint,
-							SigningRegion:     region,
-							HostnameImmutable: true,
-						}, nil
-					},
-				),
-			))
-		}
 		// Add a function to the slice that sets the region on the LoadOptions.
 		loadOpts = append(loadOpts, config.WithRegion(o.Region))
 	}
 
-	if o.EC2IMDSClientEnableState != nil {
+	// Custom endpoints are used as they are, the SDK rejects them along FIPS or dualstack.
+	if o.UseFIPSEndpoint && o.Endpoint == "" {
+		loadOpts = append(loadOpts, config.WithUseFIPSEndpoint(aws.FIPSEndpointStateEnabled))
+	}
+	if o.UseDualStackEndpoint && o.Endpoint == "" {
+		loadOpts = append(loadOpts, config.WithUseDualStackEndpoint(aws.DualStackEndpointStateEnabled))
+	}
+
+	if o.EC2IMDSClientEnableState != nil && !o.NoEC2IMDS && !o.hasStaticCredentials() && o.CredentialsProvider == nil && !o.Anonymous {
 		// If IMDS is specified, this authentication method should be handled.
 		loadOpts = append(loadOpts, config.WithEC2IMDSClientEnableState(
 			*o.EC2IMDSClientEnableState),
 		)
 	} else {
-		// If IMDS is not specified, this authentication method should be disabled.
+		// If IMDS is not specified, explicitly disabled, or static, custom or no credentials are used,
+		// this authentication method should be disabled so the SDK never probes the
+		// instance metadata endpoint, which adds startup latency outside AWS.
 		loadOpts = append(loadOpts, config.WithEC2IMDSClientEnableState(
 			imds.ClientDisabled),
 		)
 	}
 
-	if o.AccessKey != "" && o.SecretKey != "" {
+	if o.hasStaticCredentials() {
 		// Add a function to the slice that sets the credentials' provider on the LoadOptions.
 		loadOpts = append(loadOpts, config.WithCredentialsProvider(
 			credentials.NewStaticCredentialsProvider(
@@ -82,28 +358,189 @@ func (o *ClientOpts) LoadOptions() []func(options *config.LoadOptions) error {
 		))
 	}
 
+	if o.CredentialsProvider != nil {
+		loadOpts = append(loadOpts, config.WithCredentialsProvider(o.CredentialsProvider))
+	}
+
+	if o.Anonymous {
+		// Anonymous credentials make the SDK skip signing requests altogether.
+		loadOpts = append(loadOpts, config.WithCredentialsProvider(aws.AnonymousCredentials{}))
+	}
+
+	for _, token := range o.UserAgentAppend {
+		// the SDK replaces "/" in keys, so the version is passed on its own.
+		if product, version, ok := strings.Cut(token, "/"); ok {
+			loadOpts = append(loadOpts, config.WithAPIOptions([]func(*middleware.Stack) error{
+				awsmiddleware.AddUserAgentKeyValue(product, version),
+			}))
+		} else {
+			loadOpts = append(loadOpts, config.WithAPIOptions([]func(*middleware.Stack) error{
+				awsmiddleware.AddUserAgentKey(token),
+			}))
+		}
+	}
+
+	if o.SharedCredentials {
+		loadOpts = append(loadOpts, config.WithCredentialsCacheOptions(o.credentialsCacheOptions))
+	}
+
 	if o.AssumeRoleARN == "" {
 		return loadOpts
 	}
 
-	loadOpts = append(loadOpts,
-		config.WithAssumeRoleCredentialOptions(func(options *stscreds.AssumeRoleOptions) {
-			options.RoleARN = o.AssumeRoleARN
-			if o.AssumeRoleSessionName != "" {
-				options.RoleSessionName = o.AssumeRoleSessionName
-			}
-			if o.AssumeRoleExternalID != "" {
-				options.ExternalID = aws.String(o.AssumeRoleExternalID)
-			}
-			if o.AssumeRoleDuration != nil {
-				options.Duration = *o.AssumeRoleDuration
-			}
-		}),
-	)
+	loadOpts = append(loadOpts, config.WithAssumeRoleCredentialOptions(o.assumeRoleOptions))
 
 	return loadOpts
 }
 
+// assumeRoleOptions sets the parameters of the AssumeRole call made with the AssumeRole fields.
+func (o *ClientOpts) assumeRoleOptions(options *stscreds.AssumeRoleOptions) {
+	options.RoleARN = o.AssumeRoleARN
+	if o.AssumeRoleSessionName != "" {
+		options.RoleSessionName = o.AssumeRoleSessionName
+	}
+	if o.AssumeRoleExternalID != "" {
+		options.ExternalID = aws.String(o.AssumeRoleExternalID)
+	}
+	if o.AssumeRoleDuration != nil {
+		options.Duration = *o.AssumeRoleDuration
+	}
+	if o.AssumeRoleMFASerial != "" {
+		options.SerialNumber = aws.String(o.AssumeRoleMFASerial)
+		options.TokenProvider = o.AssumeRoleTokenProvider
+	}
+}
+
+// applyCredentials configures the credential providers that need an already loaded
+// configuration, such as those calling STS, on top of cfg.
+func (o *ClientOpts) applyCredentials(cfg *aws.Config) {
+	if o.WebIdentityRoleARN != "" {
+		// The web identity token is exchanged for credentials through STS, which does
+		// not need the request to be signed.
+		cfg.Credentials = aws.NewCredentialsCache(stscreds.NewWebIdentityRoleProvider(
+			sts.NewFromConfig(*cfg),
+			o.WebIdentityRoleARN,
+			stscreds.IdentityTokenFile(o.WebIdentityTokenFile),
+			func(options *stscreds.WebIdentityRoleOptions) {
+				if o.WebIdentitySessionName != "" {
+					options.RoleSessionName = o.WebIdentitySessionName
+				}
+			},
+		), o.credentialsCacheOptions)
+	}
+
+	if o.RolesAnywhereRoleARN != "" {
+		cfg.Credentials = aws.NewCredentialsCache(newRolesAnywhereProvider(cfg.HTTPClient, o), o.credentialsCacheOptions)
+	}
+
+	// The SDK only assumes the role of a shared config profile, so a role assumed with
+	// static credentials is set up here, signing the AssumeRole call with them.
+	if o.AssumeRoleARN != "" && o.hasStaticCredentials() {
+		cfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(
+			sts.NewFromConfig(cfg.Copy()),
+			o.AssumeRoleARN,
+			o.assumeRoleOptions,
+		), o.credentialsCacheOptions)
+	}
+
+	// Each hop of the chain gets its own STS client signing with the credentials of the
+	// previous hop, so the first role is assumed with the loaded credentials, the second
+	// one with the credentials of the first role, and so on.
+	for _, step := range o.AssumeRoleChain {
+		hopCfg := cfg.Copy()
+		cfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(
+			sts.NewFromConfig(hopCfg),
+			step.RoleARN,
+			func(options *stscreds.AssumeRoleOptions) {
+				if step.SessionName != "" {
+					options.RoleSessionName = step.SessionName
+				}
+				if step.ExternalID != "" {
+					options.ExternalID = aws.String(step.ExternalID)
+				}
+				if step.Duration != 0 {
+					options.Duration = step.Duration
+				}
+			},
+		), o.credentialsCacheOptions)
+	}
+}
+
+// maxIdleConns returns the number of idle connections to keep open.
+func (o *ClientOpts) maxIdleConns() int {
+	if o.MaxIdleConns > 0 {
+		return o.MaxIdleConns
+	}
+	return DefaultMaxIdleConns
+}
+
+// maxConnsPerHost returns the number of connections to open at once to a host.
+func (o *ClientOpts) maxConnsPerHost() int {
+	if o.MaxConnsPerHost > 0 {
+		return o.MaxConnsPerHost
+	}
+	return DefaultMaxConnsPerHost
+}
+
+// maxBufferSize returns the maximum buffer size to read the lines of the given file with,
+// maxBufferSize unless another one is set for the extension of the file.
+func (o *ClientOpts) maxBufferSize(file string, maxBufferSize int) int {
+	if len(o.MaxBufferSizes) == 0 {
+		return maxBufferSize
+	}
+
+	name := strings.ToLower(path.Base(file))
+	ext := path.Ext(name)
+	if _, ok := fileReaderFor(ext); ok {
+		// the format of the decompressed content takes precedence.
+		if size, ok := o.MaxBufferSizes[path.Ext(strings.TrimSuffix(name, ext))]; ok {
+			return size
+		}
+	}
+	if size, ok := o.MaxBufferSizes[ext]; ok {
+		return size
+	}
+	return maxBufferSize
+}
+
+// listConcurrency returns the number of prefixes to list at once.
+func (o *ClientOpts) listConcurrency() int {
+	if o.ListConcurrency > 0 {
+		return o.ListConcurrency
+	}
+	return DefaultListConcurrency
+}
+
+// listMaxKeys returns the MaxKeys of the listing pages, nil to let S3 list as many keys as it can.
+func (o *ClientOpts) listMaxKeys() *int32 {
+	if o.ListPageSize > 0 {
+		return aws.Int32(int32(o.ListPageSize))
+	}
+	return nil
+}
+
+// tlsConfig returns the TLS configuration of the transport, or nil to use the default one.
+func (o *ClientOpts) tlsConfig() *tls.Config {
+	if o.TLSConfig == nil && !o.InsecureSkipVerify {
+		return nil
+	}
+
+	cfg := &tls.Config{}
+	if o.TLSConfig != nil {
+		// the configuration given is left untouched.
+		cfg = o.TLSConfig.Clone()
+	}
+	if o.InsecureSkipVerify {
+		cfg.InsecureSkipVerify = true //nolint:gosec // explicitly requested through WithInsecureSkipVerify.
+	}
+	return cfg
+}
+
+// hasStaticCredentials returns true if both the access key and secret key are set.
+func (o *ClientOpts) hasStaticCredentials() bool {
+	return o.AccessKey != "" && o.SecretKey != ""
+}
+
 // ClientOptsFunc is a function that takes a *ClientOpts pointer and returns an error.
 type ClientOptsFunc func(*ClientOpts) error
 
@@ -123,6 +560,62 @@ func WithEndpoint(endpoint string) ClientOptsFunc {
 	}
 }
 
+// WithS3Compatible returns a ClientOptsFunc that sets the endpoint field on the ClientOpts and
+// marks it as an S3 compatible store, such as minio, whose hostname must be used as is.
+// Unlike passing the "minio" region, this works with any region the store is configured with.
+func WithS3Compatible(endpoint string) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		opts.Endpoint = endpoint
+		opts.S3Compatible = true
+		return nil
+	}
+}
+
+// WithR2 returns a ClientOptsFunc that sets the Endpoint and Region fields on the ClientOpts to
+// read from and write to the Cloudflare R2 buckets of the given account, whose endpoint is
+// https://<accountID>.r2.cloudflarestorage.com. R2 only accepts requests signed for the "auto"
+// region, and supports neither Transfer Acceleration nor KMS keys, which must not be enabled.
+// Credentials are the access key and secret of an R2 API token, set with WithStaticCredentials.
+func WithR2(accountID string) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		if !isHostLabel(accountID) {
+			return fmt.Errorf("invalid r2 account id %q", accountID)
+		}
+		opts.Endpoint = fmt.Sprintf("https://%s.r2.cloudflarestorage.com", accountID)
+		opts.Region = "auto"
+		return nil
+	}
+}
+
+// WithB2 returns a ClientOptsFunc that sets the Endpoint and Region fields on the ClientOpts to
+// read from and write to the Backblaze B2 buckets of the given region, such as "us-west-004",
+// whose endpoint is https://s3.<region>.backblazeb2.com. Requests are signed for that region.
+// B2 supports neither Transfer Acceleration nor KMS keys, which must not be enabled.
+// Credentials are the key ID and application key of a B2 application key, set with WithStaticCredentials.
+func WithB2(region string) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		if !isHostLabel(region) {
+			return fmt.Errorf("invalid b2 region %q", region)
+		}
+		opts.Endpoint = fmt.Sprintf("https://s3.%s.backblazeb2.com", region)
+		opts.Region = region
+		return nil
+	}
+}
+
+// isHostLabel reports whether s can be used as a single label of a hostname.
+func isHostLabel(s string) bool {
+	if s == "" || len(s) > 63 || s[0] == '-' || s[len(s)-1] == '-' {
+		return false
+	}
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
 // WithStaticCredentials returns a ClientOptsFunc that sets the access key and secret key fields on the ClientOpts.
 func WithStaticCredentials(a, s string) ClientOptsFunc {
 	return func(opts *ClientOpts) error {
@@ -149,6 +642,81 @@ func WithAssumeRoleCredentialOptions(a, s, id string, t *time.Duration) ClientOp
 	}
 }
 
+// WithAssumeRoleMFA returns a ClientOptsFunc that sets the MFA fields for AssumeRole on the ClientOpts.
+// The tokenProvider is called whenever the role is assumed to get the current MFA token code,
+// for instance by prompting the user or reading it from a hardware device.
+func WithAssumeRoleMFA(serial string, tokenProvider func() (string, error)) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		if serial == "" || tokenProvider == nil {
+			return errors.New("assume role mfa requires both a serial number and a token provider")
+		}
+		opts.AssumeRoleMFASerial = serial
+		opts.AssumeRoleTokenProvider = tokenProvider
+		return nil
+	}
+}
+
+// WithAssumeRoleChain returns a ClientOptsFunc that sets the AssumeRoleChain field on the ClientOpts,
+// to reach a role through one or more intermediate roles. The first step is assumed with the
+// credentials the client would otherwise use, and every following step with the credentials
+// obtained from the step before it, so the final role's credentials sign the S3 requests.
+func WithAssumeRoleChain(steps []AssumeRoleStep) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		for i, step := range steps {
+			if step.RoleARN == "" {
+				return fmt.Errorf("assume role chain step %d is missing a role arn", i)
+			}
+		}
+		opts.AssumeRoleChain = steps
+		return nil
+	}
+}
+
+// WithWebIdentityRoleCredentials returns a ClientOptsFunc that sets the web identity fields on the ClientOpts,
+// to authenticate by exchanging the token stored at tokenFilePath for the credentials of roleARN, as done
+// with IAM roles for service accounts (IRSA) on EKS.
+func WithWebIdentityRoleCredentials(roleARN, tokenFilePath, sessionName string) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		opts.WebIdentityRoleARN = roleARN
+		opts.WebIdentityTokenFile = tokenFilePath
+		opts.WebIdentitySessionName = sessionName
+		return nil
+	}
+}
+
+// WithRolesAnywhere returns a ClientOptsFunc that sets the Roles Anywhere fields on the ClientOpts,
+// to authenticate workloads running outside of AWS with IAM Roles Anywhere: sessions of roleARN
+// are created for profileARN in the region of trustAnchorARN, signed with cert, which must be
+// issued by the trust anchor and hold an RSA or ECDSA private key. Sessions last for the duration
+// of the profile, and are created again once they expire.
+func WithRolesAnywhere(trustAnchorARN, profileARN, roleARN string, cert tls.Certificate) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		for _, v := range []struct{ name, arn string }{{"trust anchor", trustAnchorARN}, {"profile", profileARN}, {"role", roleARN}} {
+			if !arn.IsARN(v.arn) {
+				return fmt.Errorf("roles anywhere %s must be an arn, got %q", v.name, v.arn)
+			}
+		}
+		// sessions are created in the region of the trust anchor.
+		if anchor, _ := arn.Parse(trustAnchorARN); anchor.Region == "" {
+			return fmt.Errorf("roles anywhere trust anchor arn %q has no region", trustAnchorARN)
+		}
+		if _, err := leafCertificate(cert); err != nil {
+			return fmt.Errorf("invalid roles anywhere certificate: %w", err)
+		}
+		switch cert.PrivateKey.(type) {
+		case *rsa.PrivateKey, *ecdsa.PrivateKey:
+		default:
+			return fmt.Errorf("roles anywhere certificate must hold an RSA or ECDSA private key, got %T", cert.PrivateKey)
+		}
+
+		opts.RolesAnywhereTrustAnchorARN = trustAnchorARN
+		opts.RolesAnywhereProfileARN = profileARN
+		opts.RolesAnywhereRoleARN = roleARN
+		opts.RolesAnywhereCertificate = &cert
+		return nil
+	}
+}
+
 // WithEC2IMDSClientEnableState returns a ClientOptsFunc that sets EC2IMDSClientEnableState fields on the ClientOpts.
 func WithEC2IMDSClientEnableState(s *imds.ClientEnableState) ClientOptsFunc {
 	return func(opts *ClientOpts) error {
@@ -156,3 +724,464 @@ func WithEC2IMDSClientEnableState(s *imds.ClientEnableState) ClientOptsFunc {
 		return nil
 	}
 }
+
+// WithNoEC2IMDS returns a ClientOptsFunc that sets the NoEC2IMDS field on the ClientOpts,
+// ensuring the SDK never reaches the EC2 instance metadata service.
+// IMDS is already disabled unless enabled through WithEC2IMDSClientEnableState, and it is
+// also always disabled when static credentials are used.
+func WithNoEC2IMDS() ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		opts.NoEC2IMDS = true
+		return nil
+	}
+}
+
+// WithServerSideEncryption returns a ClientOptsFunc that sets the server-side encryption fields on the ClientOpts.
+// The algorithm must be one of AES256, aws:kms or aws:kms:dsse, and kmsKeyID is only accepted for the KMS algorithms.
+func WithServerSideEncryption(algo string, kmsKeyID string) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		switch types.ServerSideEncryption(algo) {
+		case types.ServerSideEncryptionAes256:
+			if kmsKeyID != "" {
+				return fmt.Errorf("kms key id cannot be used with server-side encryption %q", algo)
+			}
+		case types.ServerSideEncryptionAwsKms, types.ServerSideEncryptionAwsKmsDsse:
+		default:
+			return fmt.Errorf("unsupported server-side encryption %q", algo)
+		}
+		opts.ServerSideEncryption = algo
+		opts.SSEKMSKeyID = kmsKeyID
+		return nil
+	}
+}
+
+// WithRequestChecksumCalculation returns a ClientOptsFunc that sets the ChecksumAlgorithm field on
+// the ClientOpts, the algorithm of the checksum WriteFile and WriteFileMultipart send their objects
+// and parts with for S3 to verify, one of CRC32, CRC32C, SHA1 or SHA256, or none when empty, the
+// default. The checksum is sent in a trailer of an aws-chunked body, which stores without support
+// for flexible checksums reject, such as older MinIO and Ceph RGW releases and other S3-compatible
+// stores set with WithS3Compatible: leave it empty, or set it back to empty, for those.
+func WithRequestChecksumCalculation(algorithm string) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		if algorithm != "" && !slices.Contains(types.ChecksumAlgorithm("").Values(), types.ChecksumAlgorithm(algorithm)) {
+			return fmt.Errorf("unsupported checksum algorithm %q", algorithm)
+		}
+		opts.ChecksumAlgorithm = algorithm
+		return nil
+	}
+}
+
+// WithSniffLen returns a ClientOptsFunc that sets the number of bytes peeked from the beginning
+// of an object to detect its format before streaming the rest of it.
+func WithSniffLen(n int) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		if n < minSniffLen {
+			return fmt.Errorf("sniff length must be at least %d bytes, got %d", minSniffLen, n)
+		}
+		opts.SniffLen = n
+		return nil
+	}
+}
+
+// WithRequestPayer returns a ClientOptsFunc that sets the RequestPayer field on the ClientOpts,
+// required to read from requester-pays buckets.
+func WithRequestPayer() ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		opts.RequestPayer = true
+		return nil
+	}
+}
+
+// WithProgressCallback returns a ClientOptsFunc that sets the ProgressCallback field on the ClientOpts.
+// The callback is invoked from the read loop every megabyte read and once the object is fully read,
+// so it must return quickly and hand off any slow work.
+func WithProgressCallback(fn func(bytesRead int64)) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		opts.ProgressCallback = fn
+		return nil
+	}
+}
+
+// WithAutoRegion returns a ClientOptsFunc that sets the AutoRegion field on the ClientOpts.
+// When enabled, a request failing because the bucket lives in a different region is retried
+// once against the region S3 reports, which is then cached for later requests to that bucket.
+// Reads and listings, such as ReadFile and ListFiles, are retried. Without it, BucketRegion
+// returns the region reported by the error of such a request.
+func WithAutoRegion(enabled bool) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		opts.AutoRegion = enabled
+		return nil
+	}
+}
+
+// WithReadResumes returns a ClientOptsFunc that sets the MaxReadResumes field on the ClientOpts.
+// When a read of an object's body fails midway, the object is requested again from the last
+// byte read, after an exponential backoff, up to maxResumes times per object.
+func WithReadResumes(maxResumes int) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		if maxResumes < 0 {
+			return fmt.Errorf("read resumes must not be negative, got %d", maxResumes)
+		}
+		opts.MaxReadResumes = maxResumes
+		return nil
+	}
+}
+
+// WithSkipLongLines returns a ClientOptsFunc that sets the SkipLongLines field on the ClientOpts.
+// When enabled, a line that doesn't fit in the maximum buffer size is logged and dropped, and the
+// rest of the file is still read.
+func WithSkipLongLines(enabled bool) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		opts.SkipLongLines = enabled
+		return nil
+	}
+}
+
+// WithDetectContentType returns a ClientOptsFunc that sets the DetectContentType field on the ClientOpts.
+// When enabled, line reads, such as with ReadFile, of objects whose Content-Type is missing or a
+// generic one, like the "binary/octet-stream" S3 defaults to, pass the first 512 bytes of their
+// decoded content to http.DetectContentType, without consuming them. Objects detected as text are
+// read as usual, and others fail with an error wrapping ErrBinaryContent before any line is sent.
+func WithDetectContentType(enabled bool) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		opts.DetectContentType = enabled
+		return nil
+	}
+}
+
+// WithDetectGzippedTar returns a ClientOptsFunc that sets the DetectGzippedTar field on the ClientOpts.
+// When enabled, reads of gzipped objects, named ".gz" or served with a gzip Content-Encoding, peek
+// at the tar magic of their decompressed content, and archives such as an "archive.gz" holding a tar
+// are read as the contents of their files one after the other, each ending with a newline, instead
+// of as the tar framing. Other objects are read as usual.
+func WithDetectGzippedTar(enabled bool) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		opts.DetectGzippedTar = enabled
+		return nil
+	}
+}
+
+// WithMaxBufferSize returns a ClientOptsFunc that adds the maximum buffer size of the files with the
+// given extension to the MaxBufferSizes field on the ClientOpts. Line reads of those files, such as
+// with ReadFile, then fail with bufio.ErrTooLong, or skip lines with WithSkipLongLines, past size
+// rather than past the maximum buffer size they are called with, so that formats holding large
+// records, such as ".jsonl", can be read along plain text logs kept to small lines.
+// The extension is matched before any compression extension, ".jsonl" applying to "app.jsonl.gz",
+// and against the last extension otherwise, so ".gz" applies to the other gzipped files.
+func WithMaxBufferSize(ext string, size int) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		if !strings.HasPrefix(ext, ".") || len(ext) < 2 {
+			return fmt.Errorf("invalid file extension %q", ext)
+		}
+		if size <= 0 {
+			return fmt.Errorf("max buffer size must be positive, got %d", size)
+		}
+		if opts.MaxBufferSizes == nil {
+			opts.MaxBufferSizes = make(map[string]int)
+		}
+		opts.MaxBufferSizes[strings.ToLower(ext)] = size
+		return nil
+	}
+}
+
+// WithCredentialsProvider returns a ClientOptsFunc that sets the CredentialsProvider field on the
+// ClientOpts, signing requests with the credentials it retrieves instead of any of the built-in
+// authentication methods, which cannot be combined with it.
+func WithCredentialsProvider(provider aws.CredentialsProvider) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		opts.CredentialsProvider = provider
+		return nil
+	}
+}
+
+// WithFIPSEndpoint returns a ClientOptsFunc that sets the UseFIPSEndpoint field on the ClientOpts.
+// It has no effect along a custom endpoint.
+func WithFIPSEndpoint(enabled bool) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		opts.UseFIPSEndpoint = enabled
+		return nil
+	}
+}
+
+// WithDualStackEndpoint returns a ClientOptsFunc that sets the UseDualStackEndpoint field on the ClientOpts.
+// It has no effect along a custom endpoint.
+func WithDualStackEndpoint(enabled bool) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		opts.UseDualStackEndpoint = enabled
+		return nil
+	}
+}
+
+// WithAccelerate returns a ClientOptsFunc that sets the Accelerate field on the ClientOpts.
+// Transfer Acceleration requires virtual-hosted-style addressing, so enabling it disables the
+// path-style addressing the client uses otherwise, and it is rejected along a custom endpoint.
+// The bucket must have Transfer Acceleration enabled and a DNS compliant name without dots.
+func WithAccelerate(enabled bool) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		opts.Accelerate = enabled
+		return nil
+	}
+}
+
+// WithSigningRegion returns a ClientOptsFunc that sets the SigningRegion field on the ClientOpts,
+// for stores such as Wasabi or Cloudflare R2 that reject requests signed for the configured region
+// with SignatureDoesNotMatch.
+func WithSigningRegion(region string) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		opts.SigningRegion = region
+		return nil
+	}
+}
+
+// WithAnonymousCredentials returns a ClientOptsFunc that sets the Anonymous field on the ClientOpts,
+// so that requests are sent unsigned, as public buckets reject signed requests from accounts
+// without permissions on them.
+func WithAnonymousCredentials() ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		opts.Anonymous = true
+		return nil
+	}
+}
+
+// WithUserAgentAppend returns a ClientOptsFunc that appends the given product token, such as
+// "product/version", to the User-Agent of every request, to attribute traffic to the caller
+// in CloudTrail or S3 access logs. It can be given several times.
+func WithUserAgentAppend(token string) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		if token == "" {
+			return errors.New("user agent token must not be empty")
+		}
+		opts.UserAgentAppend = append(opts.UserAgentAppend, token)
+		return nil
+	}
+}
+
+// WithEndpointResolver returns a ClientOptsFunc that sets the EndpointResolver field on the ClientOpts,
+// for deployments whose endpoints can't be described with WithEndpoint or WithS3Compatible alone.
+// The resolver is used as is for every request, instead of the ones built from those options.
+func WithEndpointResolver(resolver s3.EndpointResolverV2) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		if resolver == nil {
+			return errors.New("endpoint resolver must not be nil")
+		}
+		opts.EndpointResolver = resolver
+		return nil
+	}
+}
+
+// WithMaxIdleConns returns a ClientOptsFunc that sets the MaxIdleConns field on the ClientOpts,
+// the number of idle connections kept open for reuse by later requests.
+func WithMaxIdleConns(n int) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		if n <= 0 {
+			return fmt.Errorf("max idle connections must be positive, got %d", n)
+		}
+		opts.MaxIdleConns = n
+		return nil
+	}
+}
+
+// WithMaxConnsPerHost returns a ClientOptsFunc that sets the MaxConnsPerHost field on the ClientOpts.
+// Requests past the limit wait for a connection to be released instead of dialing a new one, so
+// concurrent reads from a shared client never hold more than n connections to S3.
+func WithMaxConnsPerHost(n int) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		if n <= 0 {
+			return fmt.Errorf("max connections per host must be positive, got %d", n)
+		}
+		opts.MaxConnsPerHost = n
+		return nil
+	}
+}
+
+// WithListPageSize returns a ClientOptsFunc that sets the ListPageSize field on the ClientOpts,
+// the number of keys listed per request by ListFiles and the other listing operations, from 1 to
+// the 1000 S3 lists at most. Smaller pages return sooner, at the cost of more requests.
+func WithListPageSize(size int) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		if size < 1 || size > maxListPageSize {
+			return fmt.Errorf("list page size must be between 1 and %d, got %d", maxListPageSize, size)
+		}
+		opts.ListPageSize = size
+		return nil
+	}
+}
+
+// WithCaseInsensitiveMatch returns a ClientOptsFunc that sets the CaseInsensitiveMatch field on the
+// ClientOpts. When enabled, ListFiles and the other listings match keys against glob patterns, and
+// against patterns without wildcards naming a single key, regardless of case, "logs/*.LOG" matching
+// "Logs/App.log". S3 prefixes are case-sensitive, so the objects listed to match a pattern are only
+// narrowed down by its start up to the first letter, which can take in much more of the bucket.
+func WithCaseInsensitiveMatch(enabled bool) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		opts.CaseInsensitiveMatch = enabled
+		return nil
+	}
+}
+
+// WithListCircuitBreaker returns a ClientOptsFunc that sets the ListThrottleThreshold and
+// ListThrottleCooldown fields on the ClientOpts. Listings, such as with ListFiles, then count the
+// consecutive throttle errors, like "SlowDown", their requests get, every attempt retried by the
+// SDK included, and once threshold of them are reached, the listing in progress is aborted with an
+// error wrapping ErrListThrottled instead of retrying on, as are the listings started within
+// cooldown of it, without sending any request. A successful page resets the count.
+func WithListCircuitBreaker(threshold int, cooldown time.Duration) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		if threshold <= 0 {
+			return fmt.Errorf("list throttle threshold must be positive, got %d", threshold)
+		}
+		if cooldown < 0 {
+			return fmt.Errorf("list throttle cooldown must not be negative, got %s", cooldown)
+		}
+		opts.ListThrottleThreshold = threshold
+		opts.ListThrottleCooldown = cooldown
+		return nil
+	}
+}
+
+// WithListConcurrency returns a ClientOptsFunc that sets the ListConcurrency field on the ClientOpts.
+// Patterns with brace alternatives in their literal prefix, such as "logs/{app,web}/*.log", and
+// the patterns of ListFilesMulti list every prefix they derive, and up to n of them are listed at once.
+func WithListConcurrency(n int) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		if n <= 0 {
+			return fmt.Errorf("list concurrency must be positive, got %d", n)
+		}
+		opts.ListConcurrency = n
+		return nil
+	}
+}
+
+// WithMetricsRecorder returns a ClientOptsFunc that sets the MetricsRecorder field on the ClientOpts,
+// to be notified of the duration and outcome of every operation of the client.
+// A nil recorder disables the notifications.
+func WithMetricsRecorder(recorder MetricsRecorder) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		opts.MetricsRecorder = recorder
+		return nil
+	}
+}
+
+// WithAPIOptions returns a ClientOptsFunc that appends the given functions to the APIOptions field
+// on the ClientOpts, to add middleware to the stack of every S3 API call made by the client.
+// They are only added to S3 calls: the STS calls made to fetch credentials don't go through them.
+func WithAPIOptions(fns ...func(*middleware.Stack) error) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		opts.APIOptions = append(opts.APIOptions, fns...)
+		return nil
+	}
+}
+
+// WithSSECustomerKey returns a ClientOptsFunc that sets the SSECustomerKey field on the ClientOpts,
+// to read objects encrypted server-side with a customer-provided key (SSE-C). The key and its MD5
+// digest are sent with every GetObject and HeadObject request, as S3 rejects them otherwise, and
+// CopyObject requests both decrypt their source and encrypt their copy with it.
+// The key must be 32 bytes long, as SSE-C only supports AES256.
+func WithSSECustomerKey(key []byte) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		if len(key) != sseCustomerKeyLen {
+			return fmt.Errorf("sse-c key must be %d bytes long, got %d", sseCustomerKeyLen, len(key))
+		}
+		opts.SSECustomerKey = key
+		return nil
+	}
+}
+
+// WithSalvageTruncated returns a ClientOptsFunc that sets the SalvageTruncated field on the ClientOpts.
+// When enabled, the complete lines decompressed from a truncated compressed object are read, its
+// incomplete last line is dropped, and the truncation is logged rather than failing the read.
+func WithSalvageTruncated(enabled bool) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		opts.SalvageTruncated = enabled
+		return nil
+	}
+}
+
+// WithTLSConfig returns a ClientOptsFunc that sets the TLSConfig field on the ClientOpts, to
+// configure the TLS connections to S3, e.g. with the RootCAs of a self-hosted store whose
+// certificate is signed by a private CA. It cannot be combined with WithHTTPClient.
+func WithTLSConfig(cfg *tls.Config) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		if cfg == nil {
+			return errors.New("tls config cannot be nil")
+		}
+		opts.TLSConfig = cfg
+		return nil
+	}
+}
+
+// WithInsecureSkipVerify returns a ClientOptsFunc that sets the InsecureSkipVerify field on the ClientOpts.
+// When enabled, the certificate presented by S3 is accepted without being verified, so anyone
+// able to intercept the connections can impersonate the store, read the credentials-signed
+// requests and the objects, and tamper with them. It is only meant for development against
+// stores with self-signed certificates; trust their CA with WithTLSConfig instead otherwise.
+// It cannot be combined with WithHTTPClient.
+func WithInsecureSkipVerify(enabled bool) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		opts.InsecureSkipVerify = enabled
+		return nil
+	}
+}
+
+// WithHTTPClient returns a ClientOptsFunc that sets the HTTPClient field on the ClientOpts, to send
+// requests with a client of the caller's own. The connection limits set with WithMaxIdleConns and
+// WithMaxConnsPerHost don't apply to it, and Close leaves its connections open.
+// It cannot be combined with WithTLSConfig or WithInsecureSkipVerify.
+func WithHTTPClient(client *http.Client) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		if client == nil {
+			return errors.New("http client cannot be nil")
+		}
+		opts.HTTPClient = client
+		return nil
+	}
+}
+
+// WithSharedCredentialsCache returns a ClientOptsFunc that sets the SharedCredentials and
+// CredentialsExpiryWindow fields on the ClientOpts. Clients created with the same credentials
+// options, such as the same role to assume, then share a single cache of credentials instead of
+// each resolving them, e.g. calling STS, on their own. Cached credentials are refreshed once
+// they are within expiryWindow of their expiration, DefaultCredentialsExpiryWindow when zero,
+// by the first request made past that point by any of the clients, the others still using the
+// cached ones until then. Custom providers set with WithCredentialsProvider are never shared.
+// The cache of credentials is held until all the clients sharing it are closed with Close.
+func WithSharedCredentialsCache(expiryWindow time.Duration) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		if expiryWindow < 0 {
+			return fmt.Errorf("credentials expiry window must not be negative, got %s", expiryWindow)
+		}
+		opts.SharedCredentials = true
+		opts.CredentialsExpiryWindow = expiryWindow
+		return nil
+	}
+}
+
+// WithUseARNRegion returns a ClientOptsFunc that sets the UseARNRegion field on the ClientOpts,
+// for reading through access points, given by their ARN in place of the bucket name, that are
+// in another region than the client's.
+func WithUseARNRegion(enabled bool) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		opts.UseARNRegion = enabled
+		return nil
+	}
+}
+
+// WithLogger returns a ClientOptsFunc that sets the Logger field on the ClientOpts, the logger the
+// client reports its progress to in place of the one passed to New.
+func WithLogger(logger Logger) ClientOptsFunc {
+	return func(opts *ClientOpts) error {
+		if logger == nil {
+			return errors.New("logger cannot be nil")
+		}
+		opts.Logger = logger
+		return nil
+	}
+}
+
+// logger returns the logger of the client, one discarding everything when none is set.
+func (o *ClientOpts) logger() Logger {
+	if o.Logger == nil {
+		return nopLogger{}
+	}
+	return o.Logger
+}
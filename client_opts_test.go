@@ -0,0 +1,814 @@
+package s3client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	smithyauth "github.com/aws/smithy-go/auth"
+	smithyendpoints "github.com/aws/smithy-go/endpoints"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// loadOptions applies the given ClientOpts load options onto an empty config.LoadOptions.
+func loadOptions(t *testing.T, opts ClientOpts) config.LoadOptions {
+	t.Helper()
+
+	var loadOpts config.LoadOptions
+	for _, fn := range opts.LoadOptions() {
+		assert.NoError(t, fn(&loadOpts))
+	}
+	return loadOpts
+}
+
+func TestWithServerSideEncryption(t *testing.T) {
+	tests := []struct {
+		name        string
+		algo        string
+		kmsKeyID    string
+		expectedErr string
+	}{
+		{name: "aes256", algo: "AES256"},
+		{name: "kms", algo: "aws:kms", kmsKeyID: "key-id"},
+		{name: "kms default key", algo: "aws:kms"},
+		{name: "kms dsse", algo: "aws:kms:dsse", kmsKeyID: "key-id"},
+		{name: "aes256 with key", algo: "AES256", kmsKeyID: "key-id", expectedErr: `kms key id cannot be used with server-side encryption "AES256"`},
+		{name: "unsupported", algo: "rot13", expectedErr: `unsupported server-side encryption "rot13"`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var opts ClientOpts
+			err := WithServerSideEncryption(tc.algo, tc.kmsKeyID)(&opts)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.algo, opts.ServerSideEncryption)
+			assert.Equal(t, tc.kmsKeyID, opts.SSEKMSKeyID)
+		})
+	}
+}
+
+func TestWithSniffLen(t *testing.T) {
+	var opts ClientOpts
+	assert.NoError(t, WithSniffLen(1024)(&opts))
+	assert.Equal(t, 1024, opts.SniffLen)
+
+	err := WithSniffLen(8)(&opts)
+	assert.EqualError(t, err, "sniff length must be at least 16 bytes, got 8")
+}
+
+func TestClientOpts_LoadOptions_S3Compatible(t *testing.T) {
+	tests := []struct {
+		name          string
+		optFns        []ClientOptsFunc
+		minioed       bool
+		signingRegion string
+	}{
+		{name: "aws", optFns: []ClientOptsFunc{WithRegion("us-east-1")}},
+		{name: "minio region", optFns: []ClientOptsFunc{WithRegion("minio"), WithEndpoint("http://localhost:9000")}, minioed: true},
+		{name: "s3 compatible", optFns: []ClientOptsFunc{WithRegion("us-east-1"), WithS3Compatible("http://localhost:9000")}, minioed: true},
+		{name: "s3 compatible without region", optFns: []ClientOptsFunc{WithS3Compatible("http://localhost:9000")}, minioed: true},
+		{name: "signing region", optFns: []ClientOptsFunc{WithRegion("us-east-1"), WithS3Compatible("https://s3.wasabisys.com"), WithSigningRegion("eu-central-1")}, minioed: true, signingRegion: "eu-central-1"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var opts ClientOpts
+			for _, fn := range tc.optFns {
+				assert.NoError(t, fn(&opts))
+			}
+
+			loadOpts := loadOptions(t, opts)
+			assert.Equal(t, opts.Region, loadOpts.Region)
+			if !tc.minioed {
+				assert.Zero(t, loadOpts.EndpointResolverWithOptions)
+				return
+			}
+
+			signingRegion := "us-east-1"
+			if tc.signingRegion != "" {
+				signingRegion = tc.signingRegion
+			}
+
+			endpoint, err := loadOpts.EndpointResolverWithOptions.ResolveEndpoint("s3", "us-east-1")
+			assert.NoError(t, err)
+			assert.Equal(t, opts.Endpoint, endpoint.URL)
+			assert.Equal(t, signingRegion, endpoint.SigningRegion)
+			assert.True(t, endpoint.HostnameImmutable)
+		})
+	}
+}
+
+func TestClientOpts_LoadOptions_EC2IMDS(t *testing.T) {
+	enabled := imds.ClientEnabled
+
+	tests := []struct {
+		name     string
+		optFns   []ClientOptsFunc
+		expected imds.ClientEnableState
+	}{
+		{name: "default", expected: imds.ClientDisabled},
+		{name: "enabled", optFns: []ClientOptsFunc{WithEC2IMDSClientEnableState(&enabled)}, expected: imds.ClientEnabled},
+		{name: "no imds", optFns: []ClientOptsFunc{WithEC2IMDSClientEnableState(&enabled), WithNoEC2IMDS()}, expected: imds.ClientDisabled},
+		{name: "static credentials", optFns: []ClientOptsFunc{WithEC2IMDSClientEnableState(&enabled), WithStaticCredentials("access", "secret")}, expected: imds.ClientDisabled},
+		{name: "credentials provider", optFns: []ClientOptsFunc{WithEC2IMDSClientEnableState(&enabled), WithCredentialsProvider(aws.AnonymousCredentials{})}, expected: imds.ClientDisabled},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var opts ClientOpts
+			for _, fn := range tc.optFns {
+				assert.NoError(t, fn(&opts))
+			}
+
+			loadOpts := loadOptions(t, opts)
+			assert.Equal(t, tc.expected, loadOpts.EC2IMDSClientEnableState)
+		})
+	}
+
+	t.Run("static credentials provider", func(t *testing.T) {
+		var opts ClientOpts
+		assert.NoError(t, WithStaticCredentials("access", "secret")(&opts))
+
+		loadOpts := loadOptions(t, opts)
+		_, ok := loadOpts.Credentials.(credentials.StaticCredentialsProvider)
+		assert.True(t, ok)
+	})
+
+	t.Run("custom credentials provider", func(t *testing.T) {
+		provider := aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "vault-access", SecretAccessKey: "vault-secret"}, nil
+		})
+
+		var opts ClientOpts
+		assert.NoError(t, WithCredentialsProvider(provider)(&opts))
+
+		loadOpts := loadOptions(t, opts)
+		creds, err := loadOpts.Credentials.Retrieve(context.TODO())
+		assert.NoError(t, err)
+		assert.Equal(t, "vault-access", creds.AccessKeyID)
+	})
+}
+
+func TestClientOpts_LoadOptions_Endpoints(t *testing.T) {
+	tests := []struct {
+		name      string
+		optFns    []ClientOptsFunc
+		fips      aws.FIPSEndpointState
+		dualStack aws.DualStackEndpointState
+	}{
+		{name: "default"},
+		{name: "fips", optFns: []ClientOptsFunc{WithFIPSEndpoint(true)}, fips: aws.FIPSEndpointStateEnabled},
+		{name: "dualstack", optFns: []ClientOptsFunc{WithDualStackEndpoint(true)}, dualStack: aws.DualStackEndpointStateEnabled},
+		{name: "custom endpoint", optFns: []ClientOptsFunc{
+			WithRegion("us-east-1"),
+			WithEndpoint("http://localhost:9000"),
+			WithFIPSEndpoint(true),
+			WithDualStackEndpoint(true),
+		}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var opts ClientOpts
+			for _, fn := range tc.optFns {
+				assert.NoError(t, fn(&opts))
+			}
+
+			loadOpts := loadOptions(t, opts)
+			assert.Equal(t, tc.fips, loadOpts.UseFIPSEndpoint)
+			assert.Equal(t, tc.dualStack, loadOpts.UseDualStackEndpoint)
+		})
+	}
+}
+
+func TestWithAnonymousCredentials(t *testing.T) {
+	var authorization []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = append(authorization, r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte("public data"))
+	}))
+	defer srv.Close()
+
+	c, err := New(context.TODO(), NullLogger{}, WithRegion("us-east-1"), WithS3Compatible(srv.URL), WithAnonymousCredentials())
+	assert.NoError(t, err)
+	defer c.Close()
+
+	outCh, errCh := c.ReadFile(context.TODO(), "open-data", "file.txt", 64*1024, 10*1024*1024)
+
+	var lines []string
+	for line := range outCh {
+		lines = append(lines, line)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assert.Equal(t, []string{"public data"}, lines)
+	assert.Equal(t, []string{""}, authorization)
+}
+
+func TestWithUserAgentAppend(t *testing.T) {
+	var userAgent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+	}))
+	defer srv.Close()
+
+	c, err := New(context.TODO(), NullLogger{},
+		WithRegion("us-east-1"),
+		WithS3Compatible(srv.URL),
+		WithStaticCredentials("access", "secret"),
+		WithUserAgentAppend("pipeline/1.2.3"),
+		WithUserAgentAppend("team"),
+	)
+	assert.NoError(t, err)
+	defer c.Close()
+
+	assert.NoError(t, c.WriteFile(context.TODO(), "bucket", "file.txt", strings.NewReader("data")))
+	assert.Contains(t, userAgent, " pipeline/1.2.3")
+	assert.Contains(t, userAgent, " team")
+}
+
+// staticResolver resolves every request to the same base URL, keeping the bucket in the path.
+type staticResolver struct {
+	url string
+}
+
+func (r staticResolver) ResolveEndpoint(ctx context.Context, params s3.EndpointParameters) (smithyendpoints.Endpoint, error) {
+	u, err := url.Parse(r.url + "/" + aws.ToString(params.Bucket))
+	if err != nil {
+		return smithyendpoints.Endpoint{}, err
+	}
+	return smithyendpoints.Endpoint{URI: *u}, nil
+}
+
+func TestWithEndpointResolver(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+	}))
+	defer srv.Close()
+
+	// the resolver takes precedence over the endpoint of the S3 compatible store.
+	c, err := New(context.TODO(), NullLogger{},
+		WithRegion("us-east-1"),
+		WithS3Compatible("http://localhost:1"),
+		WithStaticCredentials("access", "secret"),
+		WithEndpointResolver(staticResolver{url: srv.URL + "/custom"}),
+	)
+	assert.NoError(t, err)
+	defer c.Close()
+
+	assert.NoError(t, c.WriteFile(context.TODO(), "bucket", "file.txt", strings.NewReader("data")))
+	assert.Equal(t, []string{"/custom/bucket/file.txt"}, paths)
+
+	assert.Zero(t, loadOptions(t, c.opts).EndpointResolverWithOptions)
+
+	var opts ClientOpts
+	assert.EqualError(t, WithEndpointResolver(nil)(&opts), "endpoint resolver must not be nil")
+}
+
+func TestWithConnectionLimits(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		c, err := New(context.TODO(), NullLogger{}, WithRegion("us-east-1"), WithStaticCredentials("access", "secret"))
+		assert.NoError(t, err)
+		defer c.Close()
+
+		assert.Equal(t, DefaultMaxIdleConns, c.transport.MaxIdleConns)
+		assert.Equal(t, DefaultMaxIdleConns, c.transport.MaxIdleConnsPerHost)
+		assert.Equal(t, DefaultMaxConnsPerHost, c.transport.MaxConnsPerHost)
+	})
+
+	t.Run("set", func(t *testing.T) {
+		c, err := New(context.TODO(), NullLogger{},
+			WithRegion("us-east-1"),
+			WithStaticCredentials("access", "secret"),
+			WithMaxIdleConns(10),
+			WithMaxConnsPerHost(20),
+		)
+		assert.NoError(t, err)
+		defer c.Close()
+
+		assert.Equal(t, 10, c.transport.MaxIdleConns)
+		assert.Equal(t, 10, c.transport.MaxIdleConnsPerHost)
+		assert.Equal(t, 20, c.transport.MaxConnsPerHost)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		var opts ClientOpts
+		assert.EqualError(t, WithMaxIdleConns(0)(&opts), "max idle connections must be positive, got 0")
+		assert.EqualError(t, WithMaxConnsPerHost(-1)(&opts), "max connections per host must be positive, got -1")
+	})
+}
+
+func TestClientOpts_ApplyCredentials_WebIdentity(t *testing.T) {
+	var opts ClientOpts
+	assert.NoError(t, WithWebIdentityRoleCredentials("arn:aws:iam::123456789012:role/reader", "/var/run/secrets/token", "session")(&opts))
+	assert.Equal(t, "arn:aws:iam::123456789012:role/reader", opts.WebIdentityRoleARN)
+	assert.Equal(t, "/var/run/secrets/token", opts.WebIdentityTokenFile)
+	assert.Equal(t, "session", opts.WebIdentitySessionName)
+
+	cfg := aws.Config{Region: "us-east-1"}
+	opts.applyCredentials(&cfg)
+	assert.True(t, aws.IsCredentialsProvider(cfg.Credentials, &stscreds.WebIdentityRoleProvider{}))
+
+	// without web identity the loaded credentials are kept.
+	cfg = aws.Config{Region: "us-east-1", Credentials: credentials.NewStaticCredentialsProvider("access", "secret", "")}
+	(&ClientOpts{}).applyCredentials(&cfg)
+	assert.True(t, aws.IsCredentialsProvider(cfg.Credentials, credentials.StaticCredentialsProvider{}))
+}
+
+func TestClientOpts_LoadOptions_AssumeRoleMFA(t *testing.T) {
+	var opts ClientOpts
+	assert.NoError(t, WithAssumeRoleCredentialOptions("arn:aws:iam::123456789012:role/admin", "session", "", nil)(&opts))
+	assert.NoError(t, WithAssumeRoleMFA("arn:aws:iam::123456789012:mfa/user", func() (string, error) {
+		return "123456", nil
+	})(&opts))
+
+	loadOpts := loadOptions(t, opts)
+
+	var assumeRoleOpts stscreds.AssumeRoleOptions
+	loadOpts.AssumeRoleCredentialOptions(&assumeRoleOpts)
+	assert.Equal(t, "arn:aws:iam::123456789012:role/admin", assumeRoleOpts.RoleARN)
+	assert.Equal(t, "arn:aws:iam::123456789012:mfa/user", *assumeRoleOpts.SerialNumber)
+
+	token, err := assumeRoleOpts.TokenProvider()
+	assert.NoError(t, err)
+	assert.Equal(t, "123456", token)
+
+	err = WithAssumeRoleMFA("arn:aws:iam::123456789012:mfa/user", nil)(&opts)
+	assert.EqualError(t, err, "assume role mfa requires both a serial number and a token provider")
+}
+
+func TestClientOpts_ApplyCredentials_AssumeRoleChain(t *testing.T) {
+	credentialRe := regexp.MustCompile(`Credential=([^/]+)/`)
+
+	var mu sync.Mutex
+	var signedWith []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "AssumeRole", r.PostForm.Get("Action"))
+
+		mu.Lock()
+		signedWith = append(signedWith, credentialRe.FindStringSubmatch(r.Header.Get("Authorization"))[1])
+		mu.Unlock()
+
+		role := r.PostForm.Get("RoleArn")
+		role = role[strings.LastIndex(role, "/")+1:]
+		_, _ = fmt.Fprintf(w, `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleResult>
+    <Credentials>
+      <AccessKeyId>key-for-%s</AccessKeyId>
+      <SecretAccessKey>secret</SecretAccessKey>
+      <SessionToken>token</SessionToken>
+      <Expiration>2100-01-01T00:00:00Z</Expiration>
+    </Credentials>
+  </AssumeRoleResult>
+</AssumeRoleResponse>`, role)
+	}))
+	defer srv.Close()
+
+	var opts ClientOpts
+	assert.NoError(t, WithAssumeRoleChain([]AssumeRoleStep{
+		{RoleARN: "arn:aws:iam::123456789012:role/a", SessionName: "hop-a"},
+		{RoleARN: "arn:aws:iam::210987654321:role/b", ExternalID: "external"},
+	})(&opts))
+
+	cfg := aws.Config{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(srv.URL),
+		Credentials:  credentials.NewStaticCredentialsProvider("base", "secret", ""),
+	}
+	opts.applyCredentials(&cfg)
+
+	creds, err := cfg.Credentials.Retrieve(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, "key-for-b", creds.AccessKeyID)
+	assert.Equal(t, []string{"base", "key-for-a"}, signedWith)
+
+	err = WithAssumeRoleChain([]AssumeRoleStep{{SessionName: "missing"}})(&opts)
+	assert.EqualError(t, err, "assume role chain step 0 is missing a role arn")
+}
+
+func TestClientOpts_ApplyCredentials_StaticAssumeRole(t *testing.T) {
+	credentialRe := regexp.MustCompile(`Credential=([^/]+)/`)
+
+	var signedWith, roles []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "AssumeRole", r.PostForm.Get("Action"))
+
+		signedWith = append(signedWith, credentialRe.FindStringSubmatch(r.Header.Get("Authorization"))[1])
+		roles = append(roles, r.PostForm.Get("RoleArn")+" "+r.PostForm.Get("RoleSessionName"))
+		_, _ = fmt.Fprint(w, `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleResult>
+    <Credentials>
+      <AccessKeyId>assumed</AccessKeyId>
+      <SecretAccessKey>secret</SecretAccessKey>
+      <SessionToken>token</SessionToken>
+      <Expiration>2100-01-01T00:00:00Z</Expiration>
+    </Credentials>
+  </AssumeRoleResult>
+</AssumeRoleResponse>`)
+	}))
+	defer srv.Close()
+
+	var opts ClientOpts
+	assert.NoError(t, WithStaticCredentials("source", "secret")(&opts))
+	assert.NoError(t, WithAssumeRoleCredentialOptions("arn:aws:iam::123456789012:role/a", "session", "", nil)(&opts))
+	assert.NoError(t, opts.validate())
+
+	cfg := aws.Config{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(srv.URL),
+		Credentials:  credentials.NewStaticCredentialsProvider(opts.AccessKey, opts.SecretKey, ""),
+	}
+	opts.applyCredentials(&cfg)
+
+	creds, err := cfg.Credentials.Retrieve(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, "assumed", creds.AccessKeyID)
+	assert.Equal(t, []string{"source"}, signedWith)
+	assert.Equal(t, []string{"arn:aws:iam::123456789012:role/a session"}, roles)
+}
+
+func TestClientOpts_Validate(t *testing.T) {
+	tooShort := 5 * time.Minute
+	valid := time.Hour
+
+	tests := []struct {
+		name        string
+		optFns      []ClientOptsFunc
+		expectedErr string
+	}{
+		{name: "empty"},
+		{name: "region and endpoint", optFns: []ClientOptsFunc{WithRegion("minio"), WithEndpoint("http://localhost:9000")}},
+		{name: "static credentials", optFns: []ClientOptsFunc{WithStaticCredentials("access", "secret")}},
+		{name: "assume role", optFns: []ClientOptsFunc{WithAssumeRoleCredentialOptions("arn:aws:iam::123456789012:role/a", "session", "external", &valid)}},
+		{name: "static credentials and assume role chain", optFns: []ClientOptsFunc{
+			WithStaticCredentials("access", "secret"),
+			WithAssumeRoleChain([]AssumeRoleStep{{RoleARN: "arn:aws:iam::123456789012:role/a", Duration: valid}}),
+		}},
+		{
+			name:        "access key without secret key",
+			optFns:      []ClientOptsFunc{WithStaticCredentials("access", "")},
+			expectedErr: "static credentials require both an access key and a secret key",
+		},
+		{
+			name: "static credentials and assume role",
+			optFns: []ClientOptsFunc{
+				WithStaticCredentials("access", "secret"),
+				WithAssumeRoleCredentialOptions("arn:aws:iam::123456789012:role/a", "", "", nil),
+			},
+		},
+		{
+			name: "credentials provider and assume role",
+			optFns: []ClientOptsFunc{
+				WithCredentialsProvider(aws.AnonymousCredentials{}),
+				WithAssumeRoleCredentialOptions("arn:aws:iam::123456789012:role/a", "", "", nil),
+			},
+			expectedErr: "conflicting authentication methods: credentials provider, assume role",
+		},
+		{
+			name: "assume role and web identity",
+			optFns: []ClientOptsFunc{
+				WithAssumeRoleCredentialOptions("arn:aws:iam::123456789012:role/a", "", "", nil),
+				WithWebIdentityRoleCredentials("arn:aws:iam::123456789012:role/b", "/token", ""),
+			},
+			expectedErr: "conflicting authentication methods: assume role, web identity",
+		},
+		{
+			name: "static credentials and credentials provider",
+			optFns: []ClientOptsFunc{
+				WithStaticCredentials("access", "secret"),
+				WithCredentialsProvider(aws.AnonymousCredentials{}),
+			},
+			expectedErr: "conflicting authentication methods: static credentials, credentials provider",
+		},
+		{
+			name: "accelerate with s3 compatible endpoint",
+			optFns: []ClientOptsFunc{
+				WithRegion("us-east-1"),
+				WithS3Compatible("http://localhost:9000"),
+				WithAccelerate(true),
+			},
+			expectedErr: "transfer acceleration cannot be used with path-style addressing of custom endpoints",
+		},
+		{
+			name: "static credentials and anonymous credentials",
+			optFns: []ClientOptsFunc{
+				WithStaticCredentials("access", "secret"),
+				WithAnonymousCredentials(),
+			},
+			expectedErr: "conflicting authentication methods: static credentials, anonymous credentials",
+		},
+		{name: "endpoint without region", optFns: []ClientOptsFunc{WithEndpoint("http://localhost:9000")}},
+		{name: "s3 compatible without region", optFns: []ClientOptsFunc{WithS3Compatible("http://localhost:9000")}},
+		{
+			name:        "assume role duration too short",
+			optFns:      []ClientOptsFunc{WithAssumeRoleCredentialOptions("arn:aws:iam::123456789012:role/a", "", "", &tooShort)},
+			expectedErr: "assume role duration 5m0s must be between 15m0s and 12h0m0s",
+		},
+		{
+			name:        "assume role parameters without role",
+			optFns:      []ClientOptsFunc{WithAssumeRoleCredentialOptions("", "session", "", nil)},
+			expectedErr: "assume role parameters require an assume role arn",
+		},
+		{
+			name:        "assume role mfa without role",
+			optFns:      []ClientOptsFunc{WithAssumeRoleMFA("serial", func() (string, error) { return "", nil })},
+			expectedErr: "assume role parameters require an assume role arn",
+		},
+		{
+			name:        "assume role chain duration too short",
+			optFns:      []ClientOptsFunc{WithAssumeRoleChain([]AssumeRoleStep{{RoleARN: "arn:aws:iam::123456789012:role/a", Duration: tooShort}})},
+			expectedErr: "assume role chain step 0: assume role duration 5m0s must be between 15m0s and 12h0m0s",
+		},
+		{
+			name:        "web identity without token file",
+			optFns:      []ClientOptsFunc{WithWebIdentityRoleCredentials("arn:aws:iam::123456789012:role/a", "", "")},
+			expectedErr: "web identity requires a token file",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var opts ClientOpts
+			for _, fn := range tc.optFns {
+				assert.NoError(t, fn(&opts))
+			}
+
+			err := opts.validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tc.expectedErr)
+		})
+	}
+
+	t.Run("accelerate", func(t *testing.T) {
+		c, err := New(context.TODO(), NullLogger{}, WithRegion("us-east-1"), WithStaticCredentials("access", "secret"), WithAccelerate(true))
+		assert.NoError(t, err)
+
+		options := c.Svc.(*s3.Client).Options()
+		assert.True(t, options.UseAccelerate)
+		assert.False(t, options.UsePathStyle)
+	})
+
+	t.Run("new", func(t *testing.T) {
+		_, err := New(context.TODO(), NullLogger{}, WithStaticCredentials("access", ""))
+		assert.EqualError(t, err, "invalid client options: static credentials require both an access key and a secret key")
+	})
+}
+
+func TestWithAPIOptions(t *testing.T) {
+	ctx := context.TODO()
+	noop := func(stack *middleware.Stack) error { return nil }
+
+	base, err := New(ctx, NullLogger{}, WithRegion("us-east-1"), WithStaticCredentials("access", "secret"))
+	assert.NoError(t, err)
+
+	c, err := New(ctx, NullLogger{}, WithRegion("us-east-1"), WithStaticCredentials("access", "secret"), WithAPIOptions(noop, noop))
+	assert.NoError(t, err)
+
+	// the options are only added to the S3 client, not to the loaded config shared with STS.
+	assert.Equal(t, len(base.Svc.(*s3.Client).Options().APIOptions)+2, len(c.Svc.(*s3.Client).Options().APIOptions))
+
+	var opts ClientOpts
+	assert.NoError(t, WithAPIOptions(noop)(&opts))
+	cfg, err := config.LoadDefaultConfig(ctx, opts.LoadOptions()...)
+	assert.NoError(t, err)
+	assert.Zero(t, cfg.APIOptions)
+}
+
+func TestWithTLSConfig(t *testing.T) {
+	ctx := context.TODO()
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<ListBucketResult><Contents><Key>file.log</Key></Contents></ListBucketResult>`))
+	}))
+	defer srv.Close()
+
+	newClient := func(optFns ...ClientOptsFunc) *DefaultClient {
+		c, err := New(ctx, NullLogger{}, append([]ClientOptsFunc{
+			WithRegion("us-east-1"),
+			WithStaticCredentials("access", "secret"),
+			WithS3Compatible(srv.URL),
+		}, optFns...)...)
+		assert.NoError(t, err)
+		t.Cleanup(func() { c.Close() })
+		return c
+	}
+
+	t.Run("default", func(t *testing.T) {
+		assert.Zero(t, newClient().transport.TLSClientConfig)
+	})
+
+	t.Run("trusted ca", func(t *testing.T) {
+		pool := x509.NewCertPool()
+		pool.AddCert(srv.Certificate())
+		cfg := &tls.Config{RootCAs: pool}
+
+		files, err := newClient(WithTLSConfig(cfg)).ListFiles(ctx, "bucket", "*.log")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"file.log"}, files)
+		assert.False(t, cfg.InsecureSkipVerify)
+	})
+
+	t.Run("insecure skip verify", func(t *testing.T) {
+		c := newClient(WithInsecureSkipVerify(true))
+		assert.True(t, c.transport.TLSClientConfig.InsecureSkipVerify)
+
+		files, err := c.ListFiles(ctx, "bucket", "*.log")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"file.log"}, files)
+	})
+
+	t.Run("http client", func(t *testing.T) {
+		files, err := newClient(WithHTTPClient(srv.Client())).ListFiles(ctx, "bucket", "*.log")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"file.log"}, files)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		var opts ClientOpts
+		assert.EqualError(t, WithTLSConfig(nil)(&opts), "tls config cannot be nil")
+		assert.EqualError(t, WithHTTPClient(nil)(&opts), "http client cannot be nil")
+
+		_, err := New(ctx, NullLogger{}, WithRegion("us-east-1"), WithHTTPClient(srv.Client()), WithInsecureSkipVerify(true))
+		assert.EqualError(t, err, "invalid client options: tls options cannot be used with a custom http client, configure its transport instead")
+	})
+}
+
+func TestWithMaxBufferSize(t *testing.T) {
+	var opts ClientOpts
+	assert.EqualError(t, WithMaxBufferSize("jsonl", 1024)(&opts), `invalid file extension "jsonl"`)
+	assert.EqualError(t, WithMaxBufferSize(".jsonl", 0)(&opts), "max buffer size must be positive, got 0")
+	assert.Equal(t, 64, opts.maxBufferSize("records.jsonl", 64))
+
+	assert.NoError(t, WithMaxBufferSize(".jsonl", 1024)(&opts))
+	assert.NoError(t, WithMaxBufferSize(".gz", 512)(&opts))
+
+	assert.Equal(t, 1024, opts.maxBufferSize("logs/records.jsonl", 64))
+	assert.Equal(t, 1024, opts.maxBufferSize("logs/Records.JSONL.gz", 64))
+	assert.Equal(t, 512, opts.maxBufferSize("logs/app.log.gz", 64))
+	assert.Equal(t, 64, opts.maxBufferSize("logs/app.log", 64))
+	assert.Equal(t, 64, opts.maxBufferSize("logs.jsonl/app", 64))
+}
+
+func TestWithR2AndB2(t *testing.T) {
+	ctx := context.TODO()
+
+	// resolve returns the URL and signing region of the requests to bucket made by a client with the given option.
+	resolve := func(t *testing.T, opt ClientOptsFunc) (string, string) {
+		t.Helper()
+
+		c, err := New(ctx, NullLogger{}, opt, WithStaticCredentials("access", "secret"))
+		assert.NoError(t, err)
+
+		options := c.Svc.(*s3.Client).Options()
+		endpoint, err := options.EndpointResolverV2.ResolveEndpoint(ctx, s3.EndpointParameters{
+			Bucket:         aws.String("bucket"),
+			Region:         aws.String(options.Region),
+			Endpoint:       options.BaseEndpoint,
+			ForcePathStyle: aws.Bool(options.UsePathStyle),
+		})
+		assert.NoError(t, err)
+
+		authOptions, _ := smithyauth.GetAuthOptions(&endpoint.Properties)
+		for _, option := range authOptions {
+			if option.SchemeID == smithyauth.SchemeIDSigV4 {
+				region, _ := smithyhttp.GetSigV4SigningRegion(&option.SignerProperties)
+				return endpoint.URI.String(), region
+			}
+		}
+		return endpoint.URI.String(), ""
+	}
+
+	uri, region := resolve(t, WithR2("0123456789abcdef0123456789abcdef"))
+	assert.Equal(t, "https://0123456789abcdef0123456789abcdef.r2.cloudflarestorage.com/bucket", uri)
+	assert.Equal(t, "auto", region)
+
+	uri, region = resolve(t, WithB2("us-west-004"))
+	assert.Equal(t, "https://s3.us-west-004.backblazeb2.com/bucket", uri)
+	assert.Equal(t, "us-west-004", region)
+
+	var opts ClientOpts
+	assert.EqualError(t, WithR2("")(&opts), `invalid r2 account id ""`)
+	assert.EqualError(t, WithR2("evil.com/x")(&opts), `invalid r2 account id "evil.com/x"`)
+	assert.EqualError(t, WithB2("US-West-004")(&opts), `invalid b2 region "US-West-004"`)
+
+	assert.NoError(t, WithR2("account")(&opts))
+	assert.NoError(t, WithAccelerate(true)(&opts))
+	assert.EqualError(t, opts.validate(), "transfer acceleration cannot be used with path-style addressing of custom endpoints")
+}
+
+// recordingLogger is a Logger recording the warnings logged to it.
+type recordingLogger struct {
+	NullLogger
+	mu       sync.Mutex
+	warnings []string
+}
+
+func (l *recordingLogger) Warn(format string, a ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.warnings = append(l.warnings, fmt.Sprintf(format, a...))
+}
+
+func TestWithLogger(t *testing.T) {
+	ctx := context.TODO()
+
+	t.Run("overrides the logger passed to new", func(t *testing.T) {
+		passed, set := &recordingLogger{}, &recordingLogger{}
+		c, err := New(ctx, passed, WithRegion("us-east-1"), WithStaticCredentials("access", "secret"), WithInsecureSkipVerify(true), WithLogger(set))
+		assert.NoError(t, err)
+		assert.Equal(t, Logger(set), c.Logger)
+		assert.Zero(t, passed.warnings)
+		assert.Equal(t, []string{"TLS certificate verification is disabled, connections to S3 can be intercepted"}, set.warnings)
+	})
+
+	t.Run("nil logger", func(t *testing.T) {
+		_, err := New(ctx, nil, WithRegion("us-east-1"), WithStaticCredentials("access", "secret"), WithInsecureSkipVerify(true))
+		assert.NoError(t, err)
+
+		c, err := NewMemoryClient(nil, map[string][]byte{"logs/app.log": []byte("a\nb\n")})
+		assert.NoError(t, err)
+		c.Logger = nil
+
+		files, err := c.ListFiles(ctx, "bucket", "logs/*.log")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"logs/app.log"}, files)
+
+		outCh, errCh := c.ReadFile(ctx, "bucket", "logs/app.log", 64*1024, 1024*1024)
+		var lines []string
+		for outCh != nil || errCh != nil {
+			select {
+			case line, ok := <-outCh:
+				if !ok {
+					outCh = nil
+					continue
+				}
+				lines = append(lines, line)
+			case err, ok := <-errCh:
+				if !ok {
+					errCh = nil
+					continue
+				}
+				assert.NoError(t, err)
+			}
+		}
+		assert.Equal(t, []string{"a", "b"}, lines)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		var opts ClientOpts
+		assert.EqualError(t, WithLogger(nil)(&opts), "logger cannot be nil")
+	})
+}
+
+func TestWithRequestChecksumCalculation(t *testing.T) {
+	ctx := context.TODO()
+
+	var mu sync.Mutex
+	var headers []http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		headers = append(headers, r.Header.Clone())
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	for _, algorithm := range []string{"", "CRC32"} {
+		c, err := New(ctx, NullLogger{}, WithRegion("us-east-1"), WithS3Compatible(srv.URL), WithStaticCredentials("access", "secret"), WithRequestChecksumCalculation(algorithm))
+		assert.NoError(t, err)
+		assert.NoError(t, c.WriteFile(ctx, "bucket", "file.log", strings.NewReader("data")))
+		c.Close()
+	}
+
+	// no checksum is sent unless an algorithm is set.
+	assert.Equal(t, 2, len(headers))
+	assert.Zero(t, headers[0].Get("X-Amz-Checksum-Crc32"))
+	assert.Zero(t, headers[0].Get("X-Amz-Sdk-Checksum-Algorithm"))
+	assert.Equal(t, "rfPzYw==", headers[1].Get("X-Amz-Checksum-Crc32"))
+
+	var opts ClientOpts
+	assert.EqualError(t, WithRequestChecksumCalculation("MD5")(&opts), `unsupported checksum algorithm "MD5"`)
+}
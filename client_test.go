@@ -3,19 +3,29 @@ package s3client
 import (
 	"bufio"
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
 	"time"
 
 	"github.com/alecthomas/assert/v2"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/smithy-go"
+	smithyauth "github.com/aws/smithy-go/auth"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 
 	"github.com/calyptia/go-s3-client/ifaces"
 )
@@ -54,6 +64,31 @@ func TestDefaultClient_ReadFile(t *testing.T) {
 					return []string{"single line"}
 				},
 			},
+			{
+				name: "content encoding gzip",
+				file: "testdata/large-file.csv",
+				clientMock: &ifaces.ClientMock{
+					GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+						pwd, err := os.Getwd()
+						assert.NoError(t, err)
+
+						filename := filepath.Join(pwd, "testdata/large-file.csv.gz")
+						// nolint:gosec //ignore this is just a test.
+						content, err := os.ReadFile(filename)
+						assert.NoError(t, err)
+						return &s3.GetObjectOutput{
+							Body:            io.NopCloser(bytes.NewReader(content)),
+							ContentType:     aws.String("text/csv"),
+							ContentEncoding: aws.String("gzip"),
+						}, nil
+					},
+				},
+				expectedMessages: func() []string {
+					lines, err := linesFromFile("testdata/large-file.csv.gz")
+					assert.NoError(t, err)
+					return lines
+				},
+			},
 			{
 				name: "compressed multiline",
 				file: "testdata/large-file.csv.gz",
@@ -252,6 +287,107 @@ func TestDefaultClient_ListFiles(t *testing.T) {
 		assert.Equal(t, len(files), 2)
 	})
 
+	t.Run("ok nested", func(t *testing.T) {
+		client := ifaces.ClientMock{
+			ListObjectsV2Func: func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+				assert.Equal(t, "dir/sub/", *params.Prefix)
+				return &s3.ListObjectsV2Output{
+					Contents: []types.Object{
+						{Key: aws.String("dir/sub/one.txt")},
+						{Key: aws.String("dir/sub/deeper/two.txt")},
+						{Key: aws.String("dir/sub/three.log")},
+					},
+				}, nil
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		files, err := c.ListFiles(ctx, "", "dir/sub/*.txt")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"dir/sub/one.txt"}, files)
+	})
+
+	t.Run("ok exact", func(t *testing.T) {
+		client := ifaces.ClientMock{
+			ListObjectsV2Func: func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+				return &s3.ListObjectsV2Output{
+					Contents: []types.Object{
+						{Key: aws.String("logs/app.log")},
+						{Key: aws.String("logs/app.log.1")},
+						{Key: aws.String("anything/else/app.log")},
+					},
+				}, nil
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		files, err := c.ListFiles(ctx, "", "logs/app.log")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"logs/app.log"}, files)
+	})
+
+	t.Run("ok braces", func(t *testing.T) {
+		client := ifaces.ClientMock{
+			ListObjectsV2Func: func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+				// alternatives in the literal prefix are listed apart.
+				var contents []types.Object
+				for _, key := range []string{"logs/one.log", "logs/two.txt", "logs/three.json", "logs/four.csv", "logs/five.gz"} {
+					if strings.HasPrefix(key, aws.StringValue(params.Prefix)) {
+						contents = append(contents, types.Object{Key: aws.String(key)})
+					}
+				}
+				return &s3.ListObjectsV2Output{Contents: contents}, nil
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		files, err := c.ListFiles(ctx, "", "logs/*.{log,txt}")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"logs/one.log", "logs/two.txt"}, files)
+
+		files, err = c.ListFiles(ctx, "", "logs/*.{log,{json,csv}}")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"logs/one.log", "logs/three.json", "logs/four.csv"}, files)
+
+		files, err = c.ListFiles(ctx, "", "logs/five.{gz,zip}")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"logs/five.gz"}, files)
+	})
+
+	t.Run("ok backslash", func(t *testing.T) {
+		client := ifaces.ClientMock{
+			ListObjectsV2Func: func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+				return &s3.ListObjectsV2Output{
+					Contents: []types.Object{
+						{Key: aws.String(`dir/file\.txt`)},
+						{Key: aws.String(`dir/file.txt`)},
+					},
+				}, nil
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		files, err := c.ListFiles(ctx, "", `dir/file\.txt`)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{`dir/file\.txt`}, files)
+	})
+
 	t.Run("no match", func(t *testing.T) {
 		client := ifaces.ClientMock{
 			ListObjectsV2Func: func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
@@ -285,5 +421,2522 @@ func TestDefaultClient_ListFiles(t *testing.T) {
 		assert.Error(t, err)
 		assert.Zero(t, files)
 		assert.EqualError(t, err, "error listing files from s3: cannot retrieve objects")
+
+		var partialErr *PartialListError
+		assert.False(t, errors.As(err, &partialErr))
+	})
+
+	t.Run("error on second page", func(t *testing.T) {
+		client := ifaces.ClientMock{
+			ListObjectsV2Func: func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+				if params.ContinuationToken != nil {
+					return nil, fmt.Errorf("cannot retrieve objects")
+				}
+				return &s3.ListObjectsV2Output{
+					Contents: []types.Object{
+						{Key: aws.String("one.log")},
+						{Key: aws.String("two.txt")},
+					},
+					IsTruncated:           aws.Bool(true),
+					NextContinuationToken: aws.String("next"),
+				}, nil
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		files, err := c.ListFiles(ctx, "", "*.log")
+		assert.EqualError(t, err, "error listing files from s3: listing failed after 1 page(s): cannot retrieve objects")
+		assert.Equal(t, []string{"one.log"}, files)
+
+		var partialErr *PartialListError
+		assert.True(t, errors.As(err, &partialErr))
+		assert.Equal(t, 1, partialErr.Pages)
+	})
+}
+
+func TestDefaultClient_OpenFile(t *testing.T) {
+	ctx := context.TODO()
+
+	t.Run("ok", func(t *testing.T) {
+		lastModified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		client := ifaces.ClientMock{
+			GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				return &s3.GetObjectOutput{
+					Body:          io.NopCloser(bytes.NewReader([]byte("one\ntwo"))),
+					ContentType:   aws.String("text/plain"),
+					ContentLength: aws.Int64(7),
+					ETag:          aws.String(`"etag"`),
+					LastModified:  aws.Time(lastModified),
+					Metadata:      map[string]string{"owner": "team"},
+				}, nil
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		info, outCh, errCh := c.OpenFile(ctx, "bucket", "file.txt", 64*1024, 10*1024*1024)
+		assert.Equal(t, ObjectInfo{
+			Key:          "file.txt",
+			Size:         7,
+			ContentType:  "text/plain",
+			ETag:         `"etag"`,
+			LastModified: lastModified,
+			Metadata:     map[string]string{"owner": "team"},
+		}, info)
+
+		var lines []string
+		for line := range outCh {
+			lines = append(lines, line)
+		}
+		assert.Equal(t, []string{"one", "two"}, lines)
+		if err := <-errCh; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		client := ifaces.ClientMock{
+			GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				return nil, fmt.Errorf("cannot get object")
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		info, _, errCh := c.OpenFile(ctx, "bucket", "file.txt", 64*1024, 10*1024*1024)
+		assert.Equal(t, ObjectInfo{Key: "file.txt"}, info)
+		assert.EqualError(t, <-errCh, "cannot get object")
+	})
+}
+
+func TestDefaultClient_WriteFile(t *testing.T) {
+	ctx := context.TODO()
+
+	t.Run("ok", func(t *testing.T) {
+		var got *s3.PutObjectInput
+		client := ifaces.ClientMock{
+			PutObjectFunc: func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+				got = params
+				return &s3.PutObjectOutput{}, nil
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		err := c.WriteFile(ctx, "bucket", "file.txt", strings.NewReader("content"))
+		assert.NoError(t, err)
+		assert.Equal(t, "bucket", *got.Bucket)
+		assert.Equal(t, "file.txt", *got.Key)
+		assert.Equal(t, types.ServerSideEncryption(""), got.ServerSideEncryption)
+		assert.Zero(t, got.SSEKMSKeyId)
+	})
+
+	t.Run("server-side encryption", func(t *testing.T) {
+		var got *s3.PutObjectInput
+		client := ifaces.ClientMock{
+			PutObjectFunc: func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+				got = params
+				return &s3.PutObjectOutput{}, nil
+			},
+		}
+
+		var opts ClientOpts
+		err := WithServerSideEncryption("aws:kms", "key-id")(&opts)
+		assert.NoError(t, err)
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+			opts:   opts,
+		}
+
+		err = c.WriteFile(ctx, "bucket", "file.txt", strings.NewReader("content"))
+		assert.NoError(t, err)
+		assert.Equal(t, types.ServerSideEncryptionAwsKms, got.ServerSideEncryption)
+		assert.Equal(t, "key-id", *got.SSEKMSKeyId)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		client := ifaces.ClientMock{
+			PutObjectFunc: func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+				return nil, fmt.Errorf("cannot put object")
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		err := c.WriteFile(ctx, "bucket", "file.txt", strings.NewReader("content"))
+		assert.EqualError(t, err, "error writing file to s3: cannot put object")
+	})
+}
+
+func TestDefaultClient_DeleteFileIfMatch(t *testing.T) {
+	ctx := context.TODO()
+
+	newClient := func(etag string) *ifaces.ClientMock {
+		return &ifaces.ClientMock{
+			HeadObjectFunc: func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+				return &s3.HeadObjectOutput{ETag: aws.String(etag)}, nil
+			},
+			DeleteObjectFunc: func(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+				return &s3.DeleteObjectOutput{}, nil
+			},
+		}
+	}
+
+	t.Run("match", func(t *testing.T) {
+		client := newClient(`"abc"`)
+		c := DefaultClient{
+			Svc:    client,
+			Logger: NullLogger{},
+		}
+
+		err := c.DeleteFileIfMatch(ctx, "bucket", "file.txt", "abc")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(client.DeleteObjectCalls()))
+		assert.Equal(t, "file.txt", *client.DeleteObjectCalls()[0].Params.Key)
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		client := newClient(`"def"`)
+		c := DefaultClient{
+			Svc:    client,
+			Logger: NullLogger{},
+		}
+
+		err := c.DeleteFileIfMatch(ctx, "bucket", "file.txt", `"abc"`)
+		assert.IsError(t, err, ErrPreconditionFailed)
+		assert.Zero(t, len(client.DeleteObjectCalls()))
+	})
+
+	t.Run("head error", func(t *testing.T) {
+		client := newClient("")
+		client.HeadObjectFunc = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			return nil, fmt.Errorf("cannot head object")
+		}
+		c := DefaultClient{
+			Svc:    client,
+			Logger: NullLogger{},
+		}
+
+		err := c.DeleteFileIfMatch(ctx, "bucket", "file.txt", "abc")
+		assert.EqualError(t, err, "error checking file on s3: cannot head object")
+		assert.Zero(t, len(client.DeleteObjectCalls()))
 	})
 }
+
+func TestDefaultClient_RequestPayer(t *testing.T) {
+	ctx := context.TODO()
+
+	client := ifaces.ClientMock{
+		GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			return &s3.GetObjectOutput{
+				Body: io.NopCloser(strings.NewReader("line")),
+			}, nil
+		},
+		ListObjectsV2Func: func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+			return &s3.ListObjectsV2Output{}, nil
+		},
+	}
+
+	var opts ClientOpts
+	assert.NoError(t, WithRequestPayer()(&opts))
+
+	c := DefaultClient{
+		Svc:    &client,
+		Logger: NullLogger{},
+		opts:   opts,
+	}
+
+	_, err := c.ListFiles(ctx, "bucket", "*.log")
+	assert.NoError(t, err)
+	assert.Equal(t, types.RequestPayerRequester, client.ListObjectsV2Calls()[0].Params.RequestPayer)
+
+	outCh, _ := c.ReadFile(ctx, "bucket", "file.txt", 64*1024, 10*1024*1024)
+	for range outCh {
+	}
+	assert.Equal(t, types.RequestPayerRequester, client.GetObjectCalls()[0].Params.RequestPayer)
+}
+
+func TestDefaultClient_ReadFile_ProgressCallback(t *testing.T) {
+	ctx := context.TODO()
+
+	content := strings.Repeat("a line of text\n", 200*1024)
+	client := ifaces.ClientMock{
+		GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			return &s3.GetObjectOutput{
+				Body: io.NopCloser(strings.NewReader(content)),
+			}, nil
+		},
+	}
+
+	var progress []int64
+	var opts ClientOpts
+	assert.NoError(t, WithProgressCallback(func(bytesRead int64) {
+		progress = append(progress, bytesRead)
+	})(&opts))
+
+	c := DefaultClient{
+		Svc:    &client,
+		Logger: NullLogger{},
+		opts:   opts,
+	}
+
+	outCh, _ := c.ReadFile(ctx, "bucket", "file.txt", 64*1024, 10*1024*1024)
+	for range outCh {
+	}
+
+	assert.True(t, len(progress) > 1)
+	for i := 1; i < len(progress); i++ {
+		assert.True(t, progress[i] > progress[i-1])
+	}
+	assert.Equal(t, int64(len(content)), progress[len(progress)-1])
+}
+
+func TestDefaultClient_ReadFiles(t *testing.T) {
+	ctx := context.TODO()
+
+	contents := map[string]string{
+		"one.log": "a\nb",
+		"two.log": "c\nd\ne",
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		client := ifaces.ClientMock{
+			GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				return &s3.GetObjectOutput{
+					Body: io.NopCloser(strings.NewReader(contents[*params.Key])),
+				}, nil
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		outCh, eventsCh, errCh := c.ReadFiles(ctx, "bucket", []string{"one.log", "two.log"}, 64*1024, 10*1024*1024)
+
+		var lines []string
+		var events []FileEvent
+		for outCh != nil || eventsCh != nil {
+			select {
+			case line, ok := <-outCh:
+				if !ok {
+					outCh = nil
+					continue
+				}
+				lines = append(lines, line)
+			case event, ok := <-eventsCh:
+				if !ok {
+					eventsCh = nil
+					continue
+				}
+				events = append(events, event)
+			case err, ok := <-errCh:
+				if ok {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+		}
+
+		assert.Equal(t, []string{"a", "b", "c", "d", "e"}, lines)
+		assert.Equal(t, []FileEvent{
+			{Kind: FileStarted, Key: "one.log"},
+			{Kind: FileCompleted, Key: "one.log", Lines: 2},
+			{Kind: FileStarted, Key: "two.log"},
+			{Kind: FileCompleted, Key: "two.log", Lines: 3},
+		}, events)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		client := ifaces.ClientMock{
+			GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				if *params.Key == "two.log" {
+					return nil, fmt.Errorf("cannot get object")
+				}
+				return &s3.GetObjectOutput{
+					Body: io.NopCloser(strings.NewReader(contents[*params.Key])),
+				}, nil
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		outCh, eventsCh, errCh := c.ReadFiles(ctx, "bucket", []string{"one.log", "two.log", "three.log"}, 64*1024, 10*1024*1024)
+
+		var events []FileEvent
+		var gotErr error
+		for outCh != nil || eventsCh != nil {
+			select {
+			case _, ok := <-outCh:
+				if !ok {
+					outCh = nil
+				}
+			case event, ok := <-eventsCh:
+				if !ok {
+					eventsCh = nil
+					continue
+				}
+				events = append(events, event)
+			case err, ok := <-errCh:
+				if ok {
+					gotErr = err
+				}
+			}
+		}
+
+		assert.EqualError(t, gotErr, "cannot get object")
+		assert.Equal(t, 4, len(events))
+		assert.Equal(t, FileCompleted, events[3].Kind)
+		assert.Equal(t, "two.log", events[3].Key)
+		assert.EqualError(t, events[3].Err, "cannot get object")
+	})
+}
+
+func TestDefaultClient_Close(t *testing.T) {
+	c, err := New(context.TODO(), NullLogger{}, WithRegion("us-east-1"), WithStaticCredentials("access", "secret"))
+	assert.NoError(t, err)
+	assert.NotZero(t, c.transport)
+	assert.NoError(t, c.Close())
+
+	// clients built without New have no transport to release.
+	assert.NoError(t, (&DefaultClient{}).Close())
+}
+
+func TestDefaultClient_ReadFile_NoSuchKey(t *testing.T) {
+	client := ifaces.ClientMock{
+		GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			return nil, &types.NoSuchKey{Message: aws.String("The specified key does not exist.")}
+		},
+	}
+
+	c := DefaultClient{
+		Svc:    &client,
+		Logger: NullLogger{},
+	}
+
+	_, errCh := c.ReadFile(context.TODO(), "bucket", "missing.txt", 64*1024, 10*1024*1024)
+	err := <-errCh
+	assert.IsError(t, err, ErrNoSuchKey)
+
+	var noSuchKey *types.NoSuchKey
+	assert.True(t, errors.As(err, &noSuchKey))
+}
+
+func TestDefaultClient_ReadFileChunks(t *testing.T) {
+	ctx := context.TODO()
+
+	source := bytes.Repeat([]byte("0123456789"), 1000)
+
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	_, err := w.Write(source)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+
+	tt := []struct {
+		name string
+		file string
+		body []byte
+	}{
+		{name: "plain", file: "file.bin", body: source},
+		{name: "compressed", file: "file.bin.gz", body: compressed.Bytes()},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			client := ifaces.ClientMock{
+				GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+					return &s3.GetObjectOutput{
+						Body: io.NopCloser(bytes.NewReader(tc.body)),
+					}, nil
+				},
+			}
+
+			c := DefaultClient{
+				Svc:    &client,
+				Logger: NullLogger{},
+			}
+
+			outCh, _ := c.ReadFileChunks(ctx, "bucket", tc.file, 4096)
+
+			var chunks [][]byte
+			for chunk := range outCh {
+				chunks = append(chunks, chunk)
+			}
+
+			assert.Equal(t, 3, len(chunks))
+			assert.Equal(t, 4096, len(chunks[0]))
+			assert.Equal(t, 4096, len(chunks[1]))
+			assert.Equal(t, len(source)-2*4096, len(chunks[2]))
+			assert.Equal(t, source, bytes.Join(chunks, nil))
+		})
+	}
+
+	t.Run("truncated", func(t *testing.T) {
+		truncated := compressed.Bytes()[:compressed.Len()/2]
+		client := ifaces.ClientMock{
+			GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				return &s3.GetObjectOutput{
+					Body: io.NopCloser(bytes.NewReader(truncated)),
+				}, nil
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		outCh, errCh := c.ReadFileChunks(ctx, "bucket", "file.bin.gz", 4096)
+
+		var errs []error
+		for outCh != nil || errCh != nil {
+			select {
+			case _, ok := <-outCh:
+				if !ok {
+					outCh = nil
+				}
+			case err, ok := <-errCh:
+				if !ok {
+					errCh = nil
+					continue
+				}
+				errs = append(errs, err)
+			}
+		}
+		assert.NotZero(t, errs)
+		assert.IsError(t, errs[0], io.ErrUnexpectedEOF)
+	})
+
+	t.Run("invalid chunk size", func(t *testing.T) {
+		c := DefaultClient{
+			Svc:    &ifaces.ClientMock{},
+			Logger: NullLogger{},
+		}
+
+		_, errCh := c.ReadFileChunks(ctx, "bucket", "file.bin", 0)
+		assert.EqualError(t, <-errCh, "invalid chunk size: 0")
+	})
+}
+
+func TestDefaultClient_ListDir(t *testing.T) {
+	ctx := context.TODO()
+
+	lastModified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	newClient := func() *ifaces.ClientMock {
+		return &ifaces.ClientMock{
+			ListObjectsV2Func: func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+				return &s3.ListObjectsV2Output{
+					CommonPrefixes: []types.CommonPrefix{
+						{Prefix: aws.String("logs/2024/")},
+						{Prefix: aws.String("logs/2025/")},
+					},
+					Contents: []types.Object{
+						{
+							Key:          aws.String("logs/index.json"),
+							Size:         aws.Int64(42),
+							ETag:         aws.String(`"etag"`),
+							LastModified: aws.Time(lastModified),
+						},
+					},
+				}, nil
+			},
+		}
+	}
+
+	for _, prefix := range []string{"logs", "logs/"} {
+		t.Run(prefix, func(t *testing.T) {
+			client := newClient()
+			c := DefaultClient{
+				Svc:    client,
+				Logger: NullLogger{},
+			}
+
+			dirs, files, err := c.ListDir(ctx, "bucket", prefix)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"logs/2024/", "logs/2025/"}, dirs)
+			assert.Equal(t, []ObjectInfo{{
+				Key:          "logs/index.json",
+				Size:         42,
+				ETag:         `"etag"`,
+				LastModified: lastModified,
+			}}, files)
+
+			params := client.ListObjectsV2Calls()[0].Params
+			assert.Equal(t, "/", *params.Delimiter)
+			assert.Equal(t, "logs/", *params.Prefix)
+		})
+	}
+
+	t.Run("bucket root", func(t *testing.T) {
+		client := newClient()
+		c := DefaultClient{
+			Svc:    client,
+			Logger: NullLogger{},
+		}
+
+		_, _, err := c.ListDir(ctx, "bucket", "")
+		assert.NoError(t, err)
+		assert.Zero(t, client.ListObjectsV2Calls()[0].Params.Prefix)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		client := ifaces.ClientMock{
+			ListObjectsV2Func: func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+				return nil, fmt.Errorf("cannot retrieve objects")
+			},
+		}
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		_, _, err := c.ListDir(ctx, "bucket", "logs")
+		assert.EqualError(t, err, "error listing directory from s3: cannot retrieve objects")
+	})
+}
+
+func TestDefaultClient_ListDirs(t *testing.T) {
+	ctx := context.TODO()
+
+	client := ifaces.ClientMock{
+		ListObjectsV2Func: func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+			prefix := aws.StringValue(params.Prefix)
+			return &s3.ListObjectsV2Output{
+				CommonPrefixes: []types.CommonPrefix{
+					{Prefix: aws.String(prefix + "2024/")},
+					{Prefix: aws.String(prefix + "2025/")},
+				},
+				Contents: []types.Object{{Key: aws.String(prefix + "index.json")}},
+			}, nil
+		},
+	}
+
+	c := DefaultClient{
+		Svc:    &client,
+		Logger: NullLogger{},
+	}
+
+	tt := []struct {
+		name   string
+		prefix string
+		want   []string
+		params string
+	}{
+		{name: "trailing slash", prefix: "logs/", want: []string{"logs/2024/", "logs/2025/"}, params: "logs/"},
+		{name: "no trailing slash", prefix: "logs", want: []string{"logs/2024/", "logs/2025/"}, params: "logs/"},
+		{name: "bucket root", prefix: "", want: []string{"2024/", "2025/"}},
+	}
+
+	for i, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			dirs, err := c.ListDirs(ctx, "bucket", tc.prefix)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, dirs)
+
+			params := client.ListObjectsV2Calls()[i].Params
+			assert.Equal(t, "/", aws.StringValue(params.Delimiter))
+			assert.Equal(t, tc.params, aws.StringValue(params.Prefix))
+		})
+	}
+}
+
+func TestDefaultClient_ListFiles_Concurrent(t *testing.T) {
+	ctx := context.TODO()
+
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	client := ifaces.ClientMock{
+		ListObjectsV2Func: func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+			mu.Lock()
+			running++
+			maxRunning = max(maxRunning, running)
+			mu.Unlock()
+			defer func() {
+				mu.Lock()
+				running--
+				mu.Unlock()
+			}()
+
+			// later prefixes are listed faster, without changing the order of the files.
+			prefix := aws.StringValue(params.Prefix)
+			time.Sleep(time.Duration('f'-prefix[len("logs/")]) * time.Millisecond)
+			if prefix == "logs/d/" {
+				return nil, errors.New("connection reset")
+			}
+			return &s3.ListObjectsV2Output{
+				Contents: []types.Object{{Key: aws.String(prefix + "app.log")}, {Key: aws.String(prefix + "app.txt")}},
+			}, nil
+		},
+	}
+
+	c := DefaultClient{
+		Svc:    &client,
+		Logger: NullLogger{},
+		opts:   ClientOpts{ListConcurrency: 2},
+	}
+
+	files, err := c.ListFiles(ctx, "bucket", "logs/{c,a,b}/*.log")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"logs/a/app.log", "logs/b/app.log", "logs/c/app.log"}, files)
+	assert.Equal(t, 3, len(client.ListObjectsV2Calls()))
+	assert.Equal(t, 2, maxRunning)
+
+	// the files of the prefixes before the failed one are kept.
+	files, err = c.ListFiles(ctx, "bucket", "logs/{a,b,c,d,e}/*.log")
+	assert.EqualError(t, err, "error listing files from s3: listing failed after 3 page(s): connection reset")
+	var partial *PartialListError
+	assert.True(t, errors.As(err, &partial))
+	assert.Equal(t, []string{"logs/a/app.log", "logs/b/app.log", "logs/c/app.log"}, files)
+}
+
+func TestDefaultClient_ListFilesStream_Concurrent(t *testing.T) {
+	release := make(chan struct{})
+	client := ifaces.ClientMock{
+		ListObjectsV2Func: func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+			prefix := aws.StringValue(params.Prefix)
+			if prefix == "logs/a/" && params.ContinuationToken == nil {
+				return &s3.ListObjectsV2Output{
+					Contents:              []types.Object{{Key: aws.String(prefix + "app.log")}},
+					IsTruncated:           aws.Bool(true),
+					NextContinuationToken: aws.String("next"),
+				}, nil
+			}
+			// the rest of the first prefix and the later one are only listed once the first
+			// key has been received.
+			select {
+			case <-release:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			return &s3.ListObjectsV2Output{Contents: []types.Object{{Key: aws.String(prefix + "web.log")}}}, nil
+		},
+	}
+	c := DefaultClient{Svc: &client, Logger: NullLogger{}}
+
+	outCh, errCh := c.ListFilesStream(context.TODO(), "bucket", "logs/{a,b}/*.log")
+	select {
+	case key := <-outCh:
+		assert.Equal(t, "logs/a/app.log", key)
+	case err := <-errCh:
+		t.Fatalf("unexpected error: %v", err)
+	case <-time.After(time.Second):
+		t.Fatal("the first key wasn't sent before the later prefix was listed")
+	}
+	close(release)
+
+	var keys []string
+	for outCh != nil || errCh != nil {
+		select {
+		case key, ok := <-outCh:
+			if !ok {
+				outCh = nil
+				continue
+			}
+			keys = append(keys, key)
+		case err, ok := <-errCh:
+			if !ok {
+				errCh = nil
+				continue
+			}
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	assert.Equal(t, []string{"logs/a/web.log", "logs/b/web.log"}, keys)
+}
+
+func TestDefaultClient_ListFilesMulti(t *testing.T) {
+	ctx := context.TODO()
+
+	keys := []string{
+		"data/a.json",
+		"data/b.txt",
+		"logs/app-1.log",
+		"logs/app-2.txt",
+		"logs/web.log",
+		"other/x.log",
+	}
+	newClient := func() (*ifaces.ClientMock, DefaultClient) {
+		client := &ifaces.ClientMock{
+			ListObjectsV2Func: func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+				var contents []types.Object
+				for _, key := range keys {
+					if strings.HasPrefix(key, aws.StringValue(params.Prefix)) {
+						contents = append(contents, types.Object{Key: aws.String(key)})
+					}
+				}
+				return &s3.ListObjectsV2Output{Contents: contents}, nil
+			},
+		}
+		return client, DefaultClient{Svc: client, Logger: NullLogger{}}
+	}
+
+	t.Run("dedup", func(t *testing.T) {
+		client, c := newClient()
+
+		// logs/app-1.log matches three of the patterns.
+		files, err := c.ListFilesMulti(ctx, "bucket", []string{"logs/*.log", "logs/app-*", "data/*.json", "logs/app-*.log"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"data/a.json", "logs/app-1.log", "logs/app-2.txt", "logs/web.log"}, files)
+
+		var prefixes []string
+		for _, call := range client.ListObjectsV2Calls() {
+			prefixes = append(prefixes, aws.StringValue(call.Params.Prefix))
+		}
+		// prefixes are listed concurrently.
+		sort.Strings(prefixes)
+		assert.Equal(t, []string{"data/", "logs/"}, prefixes)
+	})
+
+	t.Run("whole bucket", func(t *testing.T) {
+		client, c := newClient()
+
+		files, err := c.ListFilesMulti(ctx, "bucket", []string{"**/*.log", "data/*.txt"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"data/b.txt", "logs/app-1.log", "logs/web.log", "other/x.log"}, files)
+		assert.Equal(t, 1, len(client.ListObjectsV2Calls()))
+	})
+
+	t.Run("no patterns", func(t *testing.T) {
+		client, c := newClient()
+
+		files, err := c.ListFilesMulti(ctx, "bucket", nil)
+		assert.NoError(t, err)
+		assert.Zero(t, files)
+		assert.Zero(t, client.ListObjectsV2Calls())
+	})
+}
+
+func TestDefaultClient_ListFilesStream(t *testing.T) {
+	ctx := context.TODO()
+
+	pages := map[string]*s3.ListObjectsV2Output{
+		"": {
+			Contents: []types.Object{
+				{Key: aws.String("logs/one.log")},
+				{Key: aws.String("logs/two.txt")},
+			},
+			IsTruncated:           aws.Bool(true),
+			NextContinuationToken: aws.String("next"),
+		},
+		"next": {
+			Contents: []types.Object{
+				{Key: aws.String("logs/three.log")},
+			},
+		},
+	}
+	client := ifaces.ClientMock{
+		ListObjectsV2Func: func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			return pages[aws.StringValue(params.ContinuationToken)], nil
+		},
+	}
+
+	c := DefaultClient{
+		Svc:    &client,
+		Logger: NullLogger{},
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		outCh, errCh := c.ListFilesStream(ctx, "bucket", "logs/*.log")
+
+		var files []string
+		for file := range outCh {
+			files = append(files, file)
+		}
+		if err := <-errCh; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		assert.Equal(t, []string{"logs/one.log", "logs/three.log"}, files)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		outCh, errCh := c.ListFilesStream(ctx, "bucket", "logs/*.log")
+
+		assert.Equal(t, "logs/one.log", <-outCh)
+		cancel()
+
+		for {
+			select {
+			case <-outCh:
+				continue
+			case err := <-errCh:
+				assert.IsError(t, err, context.Canceled)
+			}
+			break
+		}
+	})
+}
+
+func TestDefaultClient_CountFiles(t *testing.T) {
+	ctx := context.TODO()
+
+	t.Run("ok", func(t *testing.T) {
+		pages := map[string]*s3.ListObjectsV2Output{
+			"": {
+				Contents: []types.Object{
+					{Key: aws.String("logs/one.log"), Size: aws.Int64(10)},
+					{Key: aws.String("logs/two.txt"), Size: aws.Int64(20)},
+				},
+				IsTruncated:           aws.Bool(true),
+				NextContinuationToken: aws.String("next"),
+			},
+			"next": {
+				Contents: []types.Object{
+					{Key: aws.String("logs/three.log"), Size: aws.Int64(30)},
+				},
+			},
+		}
+		client := ifaces.ClientMock{
+			ListObjectsV2Func: func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+				return pages[aws.StringValue(params.ContinuationToken)], nil
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		count, totalBytes, err := c.CountFiles(ctx, "bucket", "logs/*.log")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+		assert.Equal(t, int64(40), totalBytes)
+		assert.Equal(t, 2, len(client.ListObjectsV2Calls()))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		client := ifaces.ClientMock{
+			ListObjectsV2Func: func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+				return nil, fmt.Errorf("cannot retrieve objects")
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		_, _, err := c.CountFiles(ctx, "bucket", "logs/*.log")
+		assert.EqualError(t, err, "error counting files from s3: cannot retrieve objects")
+	})
+}
+
+func TestDefaultClient_LatestFile(t *testing.T) {
+	ctx := context.TODO()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	pages := map[string]*s3.ListObjectsV2Output{
+		"": {
+			Contents: []types.Object{
+				{Key: aws.String("exports/2024-01-01.csv"), LastModified: aws.Time(now.Add(-48 * time.Hour))},
+				{Key: aws.String("exports/2024-01-03.csv"), LastModified: aws.Time(now)},
+			},
+			IsTruncated:           aws.Bool(true),
+			NextContinuationToken: aws.String("next"),
+		},
+		"next": {
+			Contents: []types.Object{
+				{Key: aws.String("exports/2024-01-02.csv"), LastModified: aws.Time(now.Add(-24 * time.Hour))},
+				{Key: aws.String("exports/latest.json"), LastModified: aws.Time(now.Add(time.Hour))},
+			},
+		},
+	}
+	client := ifaces.ClientMock{
+		ListObjectsV2Func: func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+			return pages[aws.StringValue(params.ContinuationToken)], nil
+		},
+	}
+
+	c := DefaultClient{
+		Svc:    &client,
+		Logger: NullLogger{},
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		info, err := c.LatestFile(ctx, "bucket", "exports/*.csv")
+		assert.NoError(t, err)
+		assert.Equal(t, "exports/2024-01-03.csv", info.Key)
+		assert.Equal(t, now, info.LastModified)
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		_, err := c.LatestFile(ctx, "bucket", "exports/*.parquet")
+		assert.IsError(t, err, ErrNoMatchingFiles)
+	})
+}
+
+func TestDefaultClient_ReadFileVersion(t *testing.T) {
+	ctx := context.TODO()
+
+	client := ifaces.ClientMock{
+		GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			return &s3.GetObjectOutput{
+				Body: io.NopCloser(strings.NewReader("version " + aws.StringValue(params.VersionId))),
+			}, nil
+		},
+	}
+
+	c := DefaultClient{
+		Svc:    &client,
+		Logger: NullLogger{},
+	}
+
+	outCh, errCh := c.ReadFileVersion(ctx, "bucket", "file.log", "v1", 64*1024, 10*1024*1024)
+
+	var lines []string
+	for line := range outCh {
+		lines = append(lines, line)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assert.Equal(t, []string{"version v1"}, lines)
+}
+
+func TestDefaultClient_ListFileVersions(t *testing.T) {
+	ctx := context.TODO()
+
+	older := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	newer := older.Add(time.Hour)
+
+	t.Run("ok", func(t *testing.T) {
+		client := ifaces.ClientMock{
+			ListObjectVersionsFunc: func(ctx context.Context, params *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+				return &s3.ListObjectVersionsOutput{
+					Versions: []types.ObjectVersion{
+						{Key: aws.String("logs/a.log"), VersionId: aws.String("a1"), Size: aws.Int64(10), LastModified: aws.Time(older)},
+						{Key: aws.String("logs/b.log"), VersionId: aws.String("b1"), Size: aws.Int64(20), LastModified: aws.Time(older), IsLatest: aws.Bool(true)},
+					},
+					DeleteMarkers: []types.DeleteMarkerEntry{
+						{Key: aws.String("logs/a.log"), VersionId: aws.String("a2"), LastModified: aws.Time(newer), IsLatest: aws.Bool(true)},
+					},
+				}, nil
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		versions, err := c.ListFileVersions(ctx, "bucket", "logs/")
+		assert.NoError(t, err)
+		assert.Equal(t, []ObjectVersion{
+			{ObjectInfo: ObjectInfo{Key: "logs/a.log", LastModified: newer}, VersionID: "a2", IsLatest: true, IsDeleteMarker: true},
+			{ObjectInfo: ObjectInfo{Key: "logs/a.log", Size: 10, LastModified: older}, VersionID: "a1"},
+			{ObjectInfo: ObjectInfo{Key: "logs/b.log", Size: 20, LastModified: older}, VersionID: "b1", IsLatest: true},
+		}, versions)
+		assert.Equal(t, "logs/", *client.ListObjectVersionsCalls()[0].Params.Prefix)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		client := ifaces.ClientMock{
+			ListObjectVersionsFunc: func(ctx context.Context, params *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+				return nil, fmt.Errorf("cannot list versions")
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		_, err := c.ListFileVersions(ctx, "bucket", "logs/")
+		assert.EqualError(t, err, "error listing file versions from s3: cannot list versions")
+	})
+}
+
+func TestDefaultClient_ReadFileIfChanged(t *testing.T) {
+	ctx := context.TODO()
+
+	lastRead := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("not modified", func(t *testing.T) {
+		client := ifaces.ClientMock{
+			GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				// S3 answers with a bodyless 304, exposing only the status text as code.
+				return nil, &smithy.GenericAPIError{Code: "NotModified", Message: "Not Modified"}
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		cond := ReadConditions{IfNoneMatch: `"etag"`, IfModifiedSince: lastRead}
+		_, errCh := c.ReadFileIfChanged(ctx, "bucket", "file.log", cond, 64*1024, 10*1024*1024)
+		err := <-errCh
+		assert.IsError(t, err, ErrNotModified)
+
+		params := client.GetObjectCalls()[0].Params
+		assert.Equal(t, `"etag"`, aws.StringValue(params.IfNoneMatch))
+		assert.Equal(t, lastRead, aws.TimeValue(params.IfModifiedSince))
+	})
+
+	t.Run("modified", func(t *testing.T) {
+		client := ifaces.ClientMock{
+			GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("changed"))}, nil
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		outCh, errCh := c.ReadFileIfChanged(ctx, "bucket", "file.log", ReadConditions{IfNoneMatch: `"etag"`}, 64*1024, 10*1024*1024)
+
+		var lines []string
+		for line := range outCh {
+			lines = append(lines, line)
+		}
+		if err := <-errCh; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		assert.Equal(t, []string{"changed"}, lines)
+		assert.Zero(t, client.GetObjectCalls()[0].Params.IfModifiedSince)
+	})
+}
+
+// closeTracker records whether the body it wraps has been closed.
+type closeTracker struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeTracker) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestDefaultClient_OpenReader(t *testing.T) {
+	ctx := context.TODO()
+
+	t.Run("ok", func(t *testing.T) {
+		var compressed bytes.Buffer
+		w := gzip.NewWriter(&compressed)
+		_, err := w.Write([]byte(`{"key": "value"}`))
+		assert.NoError(t, err)
+		assert.NoError(t, w.Close())
+
+		body := &closeTracker{Reader: bytes.NewReader(compressed.Bytes())}
+		client := ifaces.ClientMock{
+			GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				return &s3.GetObjectOutput{Body: body}, nil
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		reader, err := c.OpenReader(ctx, "bucket", "file.json.gz")
+		assert.NoError(t, err)
+
+		got, err := io.ReadAll(reader)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"key": "value"}`, string(got))
+
+		assert.False(t, body.closed)
+		assert.NoError(t, reader.Close())
+		assert.True(t, body.closed)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		client := ifaces.ClientMock{
+			GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				return nil, &types.NoSuchKey{Message: aws.String("The specified key does not exist.")}
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		_, err := c.OpenReader(ctx, "bucket", "missing.json")
+		assert.IsError(t, err, ErrNoSuchKey)
+	})
+}
+
+func TestDefaultClient_ReadFile_SkipLongLines(t *testing.T) {
+	ctx := context.TODO()
+
+	content := "first\n" + strings.Repeat("x", 100) + "\nsecond\n" + strings.Repeat("y", 100)
+	newClient := func() *ifaces.ClientMock {
+		return &ifaces.ClientMock{
+			GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(content))}, nil
+			},
+		}
+	}
+
+	t.Run("skip", func(t *testing.T) {
+		c := DefaultClient{
+			Svc:    newClient(),
+			Logger: NullLogger{},
+			opts: ClientOpts{
+				SkipLongLines: true,
+			},
+		}
+
+		outCh, errCh := c.ReadFile(ctx, "bucket", "file.log", 16, 32)
+
+		var lines []string
+		for line := range outCh {
+			lines = append(lines, line)
+		}
+		if err := <-errCh; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		assert.Equal(t, []string{"first", "second"}, lines)
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		c := DefaultClient{
+			Svc:    newClient(),
+			Logger: NullLogger{},
+		}
+
+		outCh, errCh := c.ReadFile(ctx, "bucket", "file.log", 16, 32)
+		for {
+			select {
+			case <-outCh:
+				continue
+			case err := <-errCh:
+				assert.IsError(t, err, bufio.ErrTooLong)
+			}
+			break
+		}
+	})
+}
+
+func TestDefaultClient_ReadFile_MaxBufferSizes(t *testing.T) {
+	ctx := context.TODO()
+
+	record := `{"message":"` + strings.Repeat("x", 100) + `"}`
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	_, _ = gw.Write([]byte(record + "\n"))
+	assert.NoError(t, gw.Close())
+
+	client := ifaces.ClientMock{
+		GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			if strings.HasSuffix(aws.StringValue(params.Key), ".gz") {
+				return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(gz.Bytes()))}, nil
+			}
+			return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(record + "\n"))}, nil
+		},
+	}
+
+	var opts ClientOpts
+	assert.NoError(t, WithMaxBufferSize(".JSONL", 1024)(&opts))
+	c := DefaultClient{
+		Svc:    &client,
+		Logger: NullLogger{},
+		opts:   opts,
+	}
+
+	read := func(file string) ([]string, error) {
+		outCh, errCh := c.ReadFile(ctx, "bucket", file, 16, 32)
+		var lines []string
+		var err error
+		for outCh != nil || errCh != nil {
+			select {
+			case line, ok := <-outCh:
+				if !ok {
+					outCh = nil
+					continue
+				}
+				lines = append(lines, line)
+			case e, ok := <-errCh:
+				if !ok {
+					errCh = nil
+					continue
+				}
+				err = e
+			}
+		}
+		return lines, err
+	}
+
+	for _, file := range []string{"records.jsonl", "records.jsonl.gz"} {
+		lines, err := read(file)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{record}, lines)
+	}
+
+	// other files keep the maximum buffer size of the call.
+	_, err := read("app.log")
+	assert.IsError(t, err, bufio.ErrTooLong)
+}
+
+func TestDefaultClient_ReadFileLines(t *testing.T) {
+	ctx := context.TODO()
+
+	content := "first\n" + strings.Repeat("x", 100) + "\n\nsecond\r\nthird"
+	client := ifaces.ClientMock{
+		GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(content))}, nil
+		},
+	}
+
+	c := DefaultClient{
+		Svc:    &client,
+		Logger: NullLogger{},
+		opts: ClientOpts{
+			SkipLongLines: true,
+		},
+	}
+
+	outCh, errCh := c.ReadFileLines(ctx, "bucket", "file.log", 16, 32, func(o *ReadFileOptions) {
+		o.VersionID = "v1"
+	})
+
+	var lines []Line
+	for line := range outCh {
+		lines = append(lines, line)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the skipped line keeps its number.
+	assert.Equal(t, []Line{
+		{LineNum: 1, Text: "first"},
+		{LineNum: 3, Text: ""},
+		{LineNum: 4, Text: "second"},
+		{LineNum: 5, Text: "third"},
+	}, lines)
+	assert.Equal(t, "v1", aws.StringValue(client.GetObjectCalls()[0].Params.VersionId))
+}
+
+func TestDefaultClient_ReadFile_SalvageTruncated(t *testing.T) {
+	ctx := context.TODO()
+
+	// the writer crashes after flushing the first lines, in the middle of the next ones.
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	_, err := w.Write([]byte("first\nsecond\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Flush())
+	flushed := b.Len()
+	_, err = w.Write([]byte(strings.Repeat("third line cut short ", 100)))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Flush())
+	truncated := b.Bytes()[:flushed+(b.Len()-flushed)/2]
+
+	newClient := func() *ifaces.ClientMock {
+		return &ifaces.ClientMock{
+			GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(truncated))}, nil
+			},
+		}
+	}
+
+	t.Run("salvage", func(t *testing.T) {
+		c := DefaultClient{
+			Svc:    newClient(),
+			Logger: NullLogger{},
+			opts: ClientOpts{
+				SalvageTruncated: true,
+			},
+		}
+
+		outCh, errCh := c.ReadFile(ctx, "bucket", "file.log.gz", 16, 4096)
+
+		var lines []string
+		for line := range outCh {
+			lines = append(lines, line)
+		}
+		if err := <-errCh; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		assert.Equal(t, []string{"first", "second"}, lines)
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		c := DefaultClient{
+			Svc:    newClient(),
+			Logger: NullLogger{},
+		}
+
+		outCh, errCh := c.ReadFile(ctx, "bucket", "file.log.gz", 16, 4096)
+
+		// both the scan and closing the decompressed stream report the truncation.
+		var errs []error
+		for outCh != nil || errCh != nil {
+			select {
+			case _, ok := <-outCh:
+				if !ok {
+					outCh = nil
+				}
+			case err, ok := <-errCh:
+				if !ok {
+					errCh = nil
+					continue
+				}
+				errs = append(errs, err)
+			}
+		}
+		assert.NotZero(t, errs)
+		for _, err := range errs {
+			assert.IsError(t, err, io.ErrUnexpectedEOF)
+		}
+	})
+}
+
+func TestDefaultClient_ObjectTags(t *testing.T) {
+	ctx := context.TODO()
+
+	t.Run("get", func(t *testing.T) {
+		client := ifaces.ClientMock{
+			GetObjectTaggingFunc: func(ctx context.Context, params *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+				return &s3.GetObjectTaggingOutput{
+					TagSet: []types.Tag{
+						{Key: aws.String("stage"), Value: aws.String("pending")},
+						{Key: aws.String("owner"), Value: aws.String("team")},
+					},
+				}, nil
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		tags, err := c.GetObjectTags(ctx, "bucket", "file.log")
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"stage": "pending", "owner": "team"}, tags)
+	})
+
+	t.Run("put", func(t *testing.T) {
+		client := ifaces.ClientMock{
+			PutObjectTaggingFunc: func(ctx context.Context, params *s3.PutObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.PutObjectTaggingOutput, error) {
+				return &s3.PutObjectTaggingOutput{}, nil
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		err := c.PutObjectTags(ctx, "bucket", "file.log", map[string]string{"stage": "processed", "owner": "team"})
+		assert.NoError(t, err)
+
+		params := client.PutObjectTaggingCalls()[0].Params
+		assert.Equal(t, "file.log", *params.Key)
+		assert.Equal(t, []types.Tag{
+			{Key: aws.String("owner"), Value: aws.String("team")},
+			{Key: aws.String("stage"), Value: aws.String("processed")},
+		}, params.Tagging.TagSet)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		client := ifaces.ClientMock{
+			GetObjectTaggingFunc: func(ctx context.Context, params *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+				return nil, &smithy.GenericAPIError{Code: "AccessDenied"}
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		_, err := c.GetObjectTags(ctx, "bucket", "file.log")
+		assert.IsError(t, err, ErrAccessDenied)
+	})
+}
+
+func TestDefaultClient_MoveFile(t *testing.T) {
+	ctx := context.TODO()
+
+	t.Run("ok", func(t *testing.T) {
+		client := ifaces.ClientMock{
+			CopyObjectFunc: func(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+				return &s3.CopyObjectOutput{}, nil
+			},
+			DeleteObjectFunc: func(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+				return &s3.DeleteObjectOutput{}, nil
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		err := c.MoveFile(ctx, "src", "pending/my file+1.log", "dst", "processed/my file+1.log")
+		assert.NoError(t, err)
+
+		copyParams := client.CopyObjectCalls()[0].Params
+		assert.Equal(t, "src/pending/my%20file%2B1.log", *copyParams.CopySource)
+		assert.Equal(t, "dst", *copyParams.Bucket)
+		assert.Equal(t, "processed/my file+1.log", *copyParams.Key)
+
+		deleteParams := client.DeleteObjectCalls()[0].Params
+		assert.Equal(t, "src", *deleteParams.Bucket)
+		assert.Equal(t, "pending/my file+1.log", *deleteParams.Key)
+	})
+
+	t.Run("copy error", func(t *testing.T) {
+		client := ifaces.ClientMock{
+			CopyObjectFunc: func(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+				return nil, fmt.Errorf("cannot copy object")
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		err := c.MoveFile(ctx, "src", "pending/file.log", "dst", "processed/file.log")
+		assert.EqualError(t, err, "error copying file on s3: cannot copy object")
+		assert.Equal(t, 0, len(client.DeleteObjectCalls()))
+	})
+
+	t.Run("onto itself", func(t *testing.T) {
+		c := DefaultClient{
+			Svc:    &ifaces.ClientMock{},
+			Logger: NullLogger{},
+		}
+
+		err := c.MoveFile(ctx, "bucket", "file.log", "bucket", "file.log")
+		assert.EqualError(t, err, `cannot move file "file.log" onto itself`)
+	})
+}
+
+func TestDefaultClient_WriteFileMultipart(t *testing.T) {
+	ctx := context.TODO()
+
+	const partSize = minPartSize
+	newClient := func(failPart int32) *ifaces.ClientMock {
+		return &ifaces.ClientMock{
+			PutObjectFunc: func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+				return &s3.PutObjectOutput{}, nil
+			},
+			CreateMultipartUploadFunc: func(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+				return &s3.CreateMultipartUploadOutput{UploadId: aws.String("upload-id")}, nil
+			},
+			UploadPartFunc: func(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+				if *params.PartNumber == failPart {
+					return nil, fmt.Errorf("cannot upload part")
+				}
+				return &s3.UploadPartOutput{ETag: aws.String(fmt.Sprintf(`"etag-%d"`, *params.PartNumber))}, nil
+			},
+			CompleteMultipartUploadFunc: func(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+				return &s3.CompleteMultipartUploadOutput{}, nil
+			},
+			AbortMultipartUploadFunc: func(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+				return &s3.AbortMultipartUploadOutput{}, nil
+			},
+		}
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		client := newClient(0)
+		c := DefaultClient{
+			Svc:    client,
+			Logger: NullLogger{},
+		}
+
+		body := bytes.Repeat([]byte("x"), 2*partSize+10)
+		err := c.WriteFileMultipart(ctx, "bucket", "file.log", bytes.NewReader(body), partSize)
+		assert.NoError(t, err)
+
+		uploads := client.UploadPartCalls()
+		assert.Equal(t, 3, len(uploads))
+		assert.Equal(t, int64(partSize), *uploads[0].Params.ContentLength)
+		assert.Equal(t, int64(10), *uploads[2].Params.ContentLength)
+
+		completed := client.CompleteMultipartUploadCalls()[0].Params
+		assert.Equal(t, "upload-id", *completed.UploadId)
+		assert.Equal(t, []types.CompletedPart{
+			{ETag: aws.String(`"etag-1"`), PartNumber: aws.Int32(1)},
+			{ETag: aws.String(`"etag-2"`), PartNumber: aws.Int32(2)},
+			{ETag: aws.String(`"etag-3"`), PartNumber: aws.Int32(3)},
+		}, completed.MultipartUpload.Parts)
+		assert.Equal(t, 0, len(client.AbortMultipartUploadCalls()))
+		assert.Equal(t, 0, len(client.PutObjectCalls()))
+	})
+
+	t.Run("single part", func(t *testing.T) {
+		client := newClient(0)
+		c := DefaultClient{
+			Svc:    client,
+			Logger: NullLogger{},
+		}
+
+		err := c.WriteFileMultipart(ctx, "bucket", "file.log", strings.NewReader("small"), partSize)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(client.PutObjectCalls()))
+		assert.Equal(t, 0, len(client.CreateMultipartUploadCalls()))
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		client := newClient(2)
+		c := DefaultClient{
+			Svc:    client,
+			Logger: NullLogger{},
+		}
+
+		body := bytes.Repeat([]byte("x"), 3*partSize)
+		err := c.WriteFileMultipart(ctx, "bucket", "file.log", bytes.NewReader(body), partSize)
+		assert.EqualError(t, err, "error writing file to s3: cannot upload part")
+		assert.Equal(t, 0, len(client.CompleteMultipartUploadCalls()))
+		assert.Equal(t, "upload-id", *client.AbortMultipartUploadCalls()[0].Params.UploadId)
+	})
+
+	t.Run("part size too small", func(t *testing.T) {
+		c := DefaultClient{
+			Svc:    newClient(0),
+			Logger: NullLogger{},
+		}
+
+		err := c.WriteFileMultipart(ctx, "bucket", "file.log", strings.NewReader("small"), 1024)
+		assert.EqualError(t, err, "part size must be at least 5242880 bytes, got 1024")
+	})
+}
+
+func TestDefaultClient_ReadAll(t *testing.T) {
+	ctx := context.TODO()
+
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	_, err := w.Write([]byte(strings.Repeat("x", 100)))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+
+	newClient := func(content []byte) *ifaces.ClientMock {
+		return &ifaces.ClientMock{
+			GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				return &s3.GetObjectOutput{
+					Body:          io.NopCloser(bytes.NewReader(content)),
+					ContentLength: aws.Int64(int64(len(content))),
+				}, nil
+			},
+		}
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		c := DefaultClient{
+			Svc:    newClient([]byte(`{"key": "value"}`)),
+			Logger: NullLogger{},
+		}
+
+		got, err := c.ReadAll(ctx, "bucket", "manifest.json", 16)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"key": "value"}`, string(got))
+	})
+
+	t.Run("compressed", func(t *testing.T) {
+		c := DefaultClient{
+			Svc:    newClient(compressed.Bytes()),
+			Logger: NullLogger{},
+		}
+
+		got, err := c.ReadAll(ctx, "bucket", "manifest.json.gz", 100)
+		assert.NoError(t, err)
+		assert.Equal(t, strings.Repeat("x", 100), string(got))
+	})
+
+	t.Run("exceeded", func(t *testing.T) {
+		c := DefaultClient{
+			Svc:    newClient([]byte(`{"key": "value"}`)),
+			Logger: NullLogger{},
+		}
+
+		_, err := c.ReadAll(ctx, "bucket", "manifest.json", 15)
+		assert.IsError(t, err, ErrTooLarge)
+	})
+
+	t.Run("exceeded once decompressed", func(t *testing.T) {
+		c := DefaultClient{
+			Svc:    newClient(compressed.Bytes()),
+			Logger: NullLogger{},
+		}
+
+		_, err := c.ReadAll(ctx, "bucket", "manifest.json.gz", 99)
+		assert.IsError(t, err, ErrTooLarge)
+	})
+}
+
+func TestDefaultClient_DownloadToFile(t *testing.T) {
+	ctx := context.TODO()
+
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	_, err := w.Write([]byte("line 1\nline 2\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+
+	t.Run("ok", func(t *testing.T) {
+		client := ifaces.ClientMock{
+			GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(compressed.Bytes()))}, nil
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		localPath := filepath.Join(t.TempDir(), "nested", "dir", "file.log")
+		n, err := c.DownloadToFile(ctx, "bucket", "file.log.gz", localPath)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(14), n)
+
+		got, err := os.ReadFile(localPath)
+		assert.NoError(t, err)
+		assert.Equal(t, "line 1\nline 2\n", string(got))
+	})
+
+	t.Run("error removes partial file", func(t *testing.T) {
+		client := ifaces.ClientMock{
+			GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				body := io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(errors.New("connection reset")))
+				return &s3.GetObjectOutput{Body: io.NopCloser(body)}, nil
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		localPath := filepath.Join(t.TempDir(), "file.log")
+		_, err := c.DownloadToFile(ctx, "bucket", "file.log", localPath)
+		assert.EqualError(t, err, fmt.Sprintf("error downloading file from s3 to %s: connection reset", localPath))
+
+		_, err = os.Stat(localPath)
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("error keeps existing file", func(t *testing.T) {
+		client := ifaces.ClientMock{
+			GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				body := io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(errors.New("connection reset")))
+				return &s3.GetObjectOutput{Body: io.NopCloser(body)}, nil
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		dir := t.TempDir()
+		localPath := filepath.Join(dir, "file.log")
+		assert.NoError(t, os.WriteFile(localPath, []byte("previous"), 0o600))
+
+		_, err := c.DownloadToFile(ctx, "bucket", "file.log", localPath)
+		assert.EqualError(t, err, fmt.Sprintf("error downloading file from s3 to %s: connection reset", localPath))
+
+		got, err := os.ReadFile(localPath)
+		assert.NoError(t, err)
+		assert.Equal(t, "previous", string(got))
+
+		entries, err := os.ReadDir(dir)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(entries))
+	})
+
+	t.Run("replace keeps permissions", func(t *testing.T) {
+		client := ifaces.ClientMock{
+			GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("new"))}, nil
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		localPath := filepath.Join(t.TempDir(), "file.log")
+		assert.NoError(t, os.WriteFile(localPath, []byte("previous"), 0o600))
+
+		_, err := c.DownloadToFile(ctx, "bucket", "file.log", localPath)
+		assert.NoError(t, err)
+
+		got, err := os.ReadFile(localPath)
+		assert.NoError(t, err)
+		assert.Equal(t, "new", string(got))
+
+		fi, err := os.Stat(localPath)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		client := ifaces.ClientMock{
+			GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				return nil, &types.NoSuchKey{}
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		localPath := filepath.Join(t.TempDir(), "file.log")
+		_, err := c.DownloadToFile(ctx, "bucket", "file.log", localPath)
+		assert.IsError(t, err, ErrNoSuchKey)
+
+		_, err = os.Stat(localPath)
+		assert.True(t, os.IsNotExist(err))
+	})
+}
+
+func TestWithSSECustomerKey(t *testing.T) {
+	ctx := context.TODO()
+	key := bytes.Repeat([]byte{0x42}, 32)
+	digest := md5.Sum(key)
+
+	var opts ClientOpts
+	assert.NoError(t, WithSSECustomerKey(key)(&opts))
+	assert.EqualError(t, WithSSECustomerKey([]byte("short"))(&opts), "sse-c key must be 32 bytes long, got 5")
+
+	client := ifaces.ClientMock{
+		GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("secret"))}, nil
+		},
+		HeadObjectFunc: func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			return &s3.HeadObjectOutput{ContentLength: aws.Int64(6)}, nil
+		},
+	}
+
+	c := DefaultClient{
+		Svc:    &client,
+		Logger: NullLogger{},
+		opts:   opts,
+	}
+
+	outCh, errCh := c.ReadFile(ctx, "bucket", "file.log", 64*1024, 10*1024*1024)
+	for range outCh {
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err := c.ReadFileTail(ctx, "bucket", "file.log", 1, 64, 1024)
+	assert.NoError(t, err)
+
+	wantKey := base64.StdEncoding.EncodeToString(key)
+	wantMD5 := base64.StdEncoding.EncodeToString(digest[:])
+	for _, call := range client.GetObjectCalls() {
+		assert.Equal(t, "AES256", aws.StringValue(call.Params.SSECustomerAlgorithm))
+		assert.Equal(t, wantKey, aws.StringValue(call.Params.SSECustomerKey))
+		assert.Equal(t, wantMD5, aws.StringValue(call.Params.SSECustomerKeyMD5))
+	}
+	head := client.HeadObjectCalls()[0].Params
+	assert.Equal(t, "AES256", aws.StringValue(head.SSECustomerAlgorithm))
+	assert.Equal(t, wantKey, aws.StringValue(head.SSECustomerKey))
+	assert.Equal(t, wantMD5, aws.StringValue(head.SSECustomerKeyMD5))
+
+	t.Run("copy", func(t *testing.T) {
+		client := ifaces.ClientMock{
+			CopyObjectFunc: func(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+				return &s3.CopyObjectOutput{}, nil
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+			opts:   opts,
+		}
+
+		assert.NoError(t, c.CopyFile(ctx, "src", "file.log", "dst", "copy.log"))
+
+		copied := client.CopyObjectCalls()[0].Params
+		assert.Equal(t, "AES256", aws.StringValue(copied.CopySourceSSECustomerAlgorithm))
+		assert.Equal(t, wantKey, aws.StringValue(copied.CopySourceSSECustomerKey))
+		assert.Equal(t, wantMD5, aws.StringValue(copied.CopySourceSSECustomerKeyMD5))
+		assert.Equal(t, "AES256", aws.StringValue(copied.SSECustomerAlgorithm))
+		assert.Equal(t, wantKey, aws.StringValue(copied.SSECustomerKey))
+		assert.Equal(t, wantMD5, aws.StringValue(copied.SSECustomerKeyMD5))
+	})
+}
+
+func TestDefaultClient_GetObjectRetention(t *testing.T) {
+	ctx := context.TODO()
+	until := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tt := []struct {
+		name    string
+		resp    *s3.GetObjectRetentionOutput
+		err     error
+		want    RetentionInfo
+		wantErr error
+	}{
+		{
+			name: "retained",
+			resp: &s3.GetObjectRetentionOutput{Retention: &types.ObjectLockRetention{
+				Mode:            types.ObjectLockRetentionModeCompliance,
+				RetainUntilDate: aws.Time(until),
+			}},
+			want: RetentionInfo{Mode: "COMPLIANCE", RetainUntil: until},
+		},
+		{name: "no retention", err: &smithy.GenericAPIError{Code: "NoSuchObjectLockConfiguration"}},
+		{name: "no object lock", err: &smithy.GenericAPIError{Code: "ObjectLockConfigurationNotFoundError"}},
+		{name: "missing", err: &types.NoSuchKey{}, wantErr: ErrNoSuchKey},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			client := ifaces.ClientMock{
+				GetObjectRetentionFunc: func(ctx context.Context, params *s3.GetObjectRetentionInput, optFns ...func(*s3.Options)) (*s3.GetObjectRetentionOutput, error) {
+					assert.Equal(t, "file.log", *params.Key)
+					return tc.resp, tc.err
+				},
+			}
+
+			c := DefaultClient{
+				Svc:    &client,
+				Logger: NullLogger{},
+			}
+
+			got, err := c.GetObjectRetention(ctx, "bucket", "file.log")
+			if tc.wantErr != nil {
+				assert.IsError(t, err, tc.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	retention := RetentionInfo{Mode: "GOVERNANCE", RetainUntil: until}
+	assert.True(t, retention.Retained(until.Add(-time.Hour)))
+	assert.False(t, retention.Retained(until))
+	assert.False(t, RetentionInfo{}.Retained(until))
+}
+
+func TestDefaultClient_GetObjectLegalHold(t *testing.T) {
+	ctx := context.TODO()
+
+	tt := []struct {
+		name string
+		resp *s3.GetObjectLegalHoldOutput
+		err  error
+		want bool
+	}{
+		{name: "on", resp: &s3.GetObjectLegalHoldOutput{LegalHold: &types.ObjectLockLegalHold{Status: types.ObjectLockLegalHoldStatusOn}}, want: true},
+		{name: "off", resp: &s3.GetObjectLegalHoldOutput{LegalHold: &types.ObjectLockLegalHold{Status: types.ObjectLockLegalHoldStatusOff}}},
+		{name: "no legal hold", err: &smithy.GenericAPIError{Code: "NoSuchObjectLockConfiguration"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			client := ifaces.ClientMock{
+				GetObjectLegalHoldFunc: func(ctx context.Context, params *s3.GetObjectLegalHoldInput, optFns ...func(*s3.Options)) (*s3.GetObjectLegalHoldOutput, error) {
+					return tc.resp, tc.err
+				},
+			}
+
+			c := DefaultClient{
+				Svc:    &client,
+				Logger: NullLogger{},
+			}
+
+			got, err := c.GetObjectLegalHold(ctx, "bucket", "file.log")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	t.Run("error", func(t *testing.T) {
+		client := ifaces.ClientMock{
+			GetObjectLegalHoldFunc: func(ctx context.Context, params *s3.GetObjectLegalHoldInput, optFns ...func(*s3.Options)) (*s3.GetObjectLegalHoldOutput, error) {
+				return nil, &smithy.GenericAPIError{Code: "AccessDenied"}
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		_, err := c.GetObjectLegalHold(ctx, "bucket", "file.log")
+		assert.IsError(t, err, ErrAccessDenied)
+	})
+}
+
+func TestDefaultClient_BucketExists(t *testing.T) {
+	ctx := context.TODO()
+
+	tt := []struct {
+		name    string
+		err     error
+		want    bool
+		wantErr error
+	}{
+		{name: "exists", want: true},
+		{name: "not found", err: &smithy.GenericAPIError{Code: "NotFound"}},
+		{name: "no such bucket", err: &smithy.GenericAPIError{Code: "NoSuchBucket"}},
+		{name: "forbidden", err: &smithy.GenericAPIError{Code: "Forbidden"}, wantErr: ErrAccessDenied},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			client := ifaces.ClientMock{
+				HeadBucketFunc: func(ctx context.Context, params *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error) {
+					if tc.err != nil {
+						return nil, tc.err
+					}
+					return &s3.HeadBucketOutput{}, nil
+				},
+			}
+
+			c := DefaultClient{
+				Svc:    &client,
+				Logger: NullLogger{},
+			}
+
+			got, err := c.BucketExists(ctx, "bucket")
+			if tc.wantErr != nil {
+				assert.IsError(t, err, tc.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, "bucket", *client.HeadBucketCalls()[0].Params.Bucket)
+		})
+	}
+}
+
+func TestDefaultClient_CreateBucket(t *testing.T) {
+	ctx := context.TODO()
+
+	tt := []struct {
+		name       string
+		region     string
+		optsRegion string
+		want       types.BucketLocationConstraint
+		// wantRegion is the region the request is sent to.
+		wantRegion string
+	}{
+		{name: "region", region: "eu-west-1", want: "eu-west-1", wantRegion: "eu-west-1"},
+		{name: "client region", optsRegion: "us-west-2", want: "us-west-2", wantRegion: "us-west-2"},
+		{name: "us-east-1", region: "us-east-1", wantRegion: "us-east-1"},
+		{name: "other region", region: "us-west-2", optsRegion: "eu-west-1", want: "us-west-2", wantRegion: "us-west-2"},
+		{name: "us-east-1 from other region", region: "us-east-1", optsRegion: "eu-west-1", wantRegion: "us-east-1"},
+		{name: "same region", region: "eu-west-1", optsRegion: "eu-west-1", want: "eu-west-1", wantRegion: "eu-west-1"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			client := ifaces.ClientMock{
+				CreateBucketFunc: func(ctx context.Context, params *s3.CreateBucketInput, optFns ...func(*s3.Options)) (*s3.CreateBucketOutput, error) {
+					return &s3.CreateBucketOutput{}, nil
+				},
+			}
+
+			c := DefaultClient{
+				Svc:    &client,
+				Logger: NullLogger{},
+				opts:   ClientOpts{Region: tc.optsRegion},
+			}
+
+			err := c.CreateBucket(ctx, "bucket", tc.region)
+			assert.NoError(t, err)
+
+			call := client.CreateBucketCalls()[0]
+			options := s3.Options{Region: tc.optsRegion}
+			for _, fn := range call.OptFns {
+				fn(&options)
+			}
+			assert.Equal(t, tc.wantRegion, options.Region)
+
+			config := call.Params.CreateBucketConfiguration
+			if tc.want == "" {
+				assert.Zero(t, config)
+				return
+			}
+			assert.Equal(t, tc.want, config.LocationConstraint)
+		})
+	}
+}
+
+func TestResolverV2_SigningRegion(t *testing.T) {
+	signingRegion := func(r *resolverV2) string {
+		endpoint, err := r.ResolveEndpoint(context.TODO(), s3.EndpointParameters{
+			Bucket: aws.String("bucket"),
+			Region: aws.String("us-east-1"),
+		})
+		assert.NoError(t, err)
+
+		options, ok := smithyauth.GetAuthOptions(&endpoint.Properties)
+		assert.True(t, ok)
+		for _, option := range options {
+			if option.SchemeID == smithyauth.SchemeIDSigV4 {
+				region, _ := smithyhttp.GetSigV4SigningRegion(&option.SignerProperties)
+				return region
+			}
+		}
+		return ""
+	}
+
+	assert.Equal(t, "us-east-1", signingRegion(&resolverV2{Region: "us-east-1"}))
+	assert.Equal(t, "auto", signingRegion(&resolverV2{Region: "us-east-1", SigningRegion: "auto"}))
+}
+
+func TestResolverV2_AccessPointARN(t *testing.T) {
+	resolve := func(bucket string) (string, error) {
+		endpoint, err := (&resolverV2{Region: "us-west-2"}).ResolveEndpoint(context.TODO(), s3.EndpointParameters{
+			Bucket:         aws.String(bucket),
+			Region:         aws.String("us-west-2"),
+			ForcePathStyle: aws.Bool(true),
+		})
+		return endpoint.URI.String(), err
+	}
+
+	uri, err := resolve("arn:aws:s3:us-west-2:123456789012:accesspoint/logs")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://logs-123456789012.s3-accesspoint.us-west-2.amazonaws.com", uri)
+
+	uri, err = resolve("arn:aws:s3-outposts:us-west-2:123456789012:outpost/op-01234567890123456/accesspoint/logs")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://logs-123456789012.op-01234567890123456.s3-outposts.us-west-2.amazonaws.com", uri)
+
+	// bucket names are still addressed path-style.
+	uri, err = resolve("bucket")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://s3.us-west-2.amazonaws.com/bucket", uri)
+
+	_, err = resolve("arn:aws:s3:::bucket")
+	assert.IsError(t, err, ErrInvalidBucketARN)
+	assert.EqualError(t, err, `invalid bucket ARN "arn:aws:s3:::bucket": bucket ARNs are not supported, pass the bucket name instead`)
+
+	_, err = resolve("arn:aws:sqs:us-west-2:123456789012:queue")
+	assert.EqualError(t, err, `invalid bucket ARN "arn:aws:sqs:us-west-2:123456789012:queue": only S3 and S3 on Outposts access point ARNs are supported`)
+
+	_, err = resolve("arn:aws:s3::123456789012:accesspoint/logs")
+	assert.EqualError(t, err, `invalid bucket ARN "arn:aws:s3::123456789012:accesspoint/logs": access point ARNs require a region and an account ID`)
+
+	assert.True(t, IsBucketARN("arn:aws:s3:us-west-2:123456789012:accesspoint/logs"))
+	assert.False(t, IsBucketARN("bucket"))
+}
+
+func BenchmarkDefaultClient_ReadFile(b *testing.B) {
+	benchmarks := map[string][]byte{
+		"small":       []byte(strings.Repeat("x", 1023) + "\n"),
+		"single line": bytes.Repeat([]byte("x"), 4<<20),
+		"many lines":  bytes.Repeat([]byte(strings.Repeat("x", 99)+"\n"), 40000),
+	}
+
+	for name, content := range benchmarks {
+		b.Run(name, func(b *testing.B) {
+			client := ifaces.ClientMock{
+				GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+					return &s3.GetObjectOutput{
+						Body:          io.NopCloser(bytes.NewReader(content)),
+						ContentLength: aws.Int64(int64(len(content))),
+					}, nil
+				},
+			}
+
+			c := DefaultClient{
+				Svc:    &client,
+				Logger: NullLogger{},
+			}
+
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				outCh, errCh := c.ReadFile(context.TODO(), "bucket", "file.log", 64*1024, 10*1024*1024)
+				for range outCh {
+				}
+				if err := <-errCh; err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestScanBufferSize(t *testing.T) {
+	tt := []struct {
+		name string
+		info ObjectInfo
+		want int
+	}{
+		{name: "small", info: ObjectInfo{Key: "file.log", Size: 1024}, want: 1025},
+		{name: "capped", info: ObjectInfo{Key: "file.log", Size: 100 * 1024 * 1024}, want: 10 * 1024 * 1024},
+		{name: "unknown size", info: ObjectInfo{Key: "file.log"}, want: 64 * 1024},
+		{name: "compressed", info: ObjectInfo{Key: "file.log.gz", Size: 1024}, want: 64 * 1024},
+		{name: "content encoding", info: ObjectInfo{Key: "file.log", Size: 1024, ContentEncoding: "gzip"}, want: 64 * 1024},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, scanBufferSize(tc.info, 64*1024, 10*1024*1024))
+		})
+	}
+}
+
+func TestDefaultClient_ReadFile_DetectContentType(t *testing.T) {
+	ctx := context.TODO()
+
+	png := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 600)...)
+	text := strings.Repeat("a line of text\n", 50)
+	newClient := func(contentType string, body []byte) *ifaces.ClientMock {
+		return &ifaces.ClientMock{
+			GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				return &s3.GetObjectOutput{
+					Body:        io.NopCloser(bytes.NewReader(body)),
+					ContentType: aws.String(contentType),
+				}, nil
+			},
+		}
+	}
+
+	read := func(c *DefaultClient) ([]string, error) {
+		outCh, errCh := c.ReadFile(ctx, "bucket", "file", 64*1024, 10*1024*1024)
+		var lines []string
+		var err error
+		for outCh != nil || errCh != nil {
+			select {
+			case line, ok := <-outCh:
+				if !ok {
+					outCh = nil
+					continue
+				}
+				lines = append(lines, line)
+			case e, ok := <-errCh:
+				if !ok {
+					errCh = nil
+					continue
+				}
+				err = e
+			}
+		}
+		return lines, err
+	}
+
+	t.Run("text", func(t *testing.T) {
+		c := &DefaultClient{Svc: newClient("binary/octet-stream", []byte(text)), Logger: NullLogger{}, opts: ClientOpts{DetectContentType: true}}
+
+		// the sniffed bytes are still read.
+		lines, err := read(c)
+		assert.NoError(t, err)
+		assert.Equal(t, 50, len(lines))
+		assert.Equal(t, "a line of text", lines[0])
+	})
+
+	t.Run("binary", func(t *testing.T) {
+		c := &DefaultClient{Svc: newClient("application/octet-stream", png), Logger: NullLogger{}, opts: ClientOpts{DetectContentType: true}}
+
+		lines, err := read(c)
+		assert.IsError(t, err, ErrBinaryContent)
+		assert.EqualError(t, err, "file file detected as image/png: binary content")
+		assert.Zero(t, lines)
+	})
+
+	t.Run("labeled", func(t *testing.T) {
+		// objects with an informative content type are not sniffed.
+		c := &DefaultClient{Svc: newClient("text/plain", png), Logger: NullLogger{}, opts: ClientOpts{DetectContentType: true}}
+
+		_, err := read(c)
+		assert.NoError(t, err)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		c := &DefaultClient{Svc: newClient("application/octet-stream", png), Logger: NullLogger{}}
+
+		_, err := read(c)
+		assert.NoError(t, err)
+	})
+}
+
+func TestDefaultClient_ScanReader(t *testing.T) {
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	_, _ = gw.Write([]byte("first\n" + strings.Repeat("x", 100) + "\nsecond\n"))
+	assert.NoError(t, gw.Close())
+
+	c := &DefaultClient{Logger: NullLogger{}, opts: ClientOpts{SkipLongLines: true}}
+
+	t.Run("ok", func(t *testing.T) {
+		outCh, errCh := c.ScanReader(context.TODO(), "logs/app.log.gz", io.NopCloser(&gz), 16, 32)
+
+		var lines []string
+		for line := range outCh {
+			lines = append(lines, line)
+		}
+		if err := <-errCh; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		assert.Equal(t, []string{"first", "second"}, lines)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.TODO())
+		body := io.NopCloser(strings.NewReader(strings.Repeat("line\n", 100000)))
+		outCh, errCh := c.ScanReader(ctx, "app.log", body, 16, 32)
+
+		<-outCh
+		cancel()
+
+		var err error
+		for outCh != nil || errCh != nil {
+			select {
+			case _, ok := <-outCh:
+				if !ok {
+					outCh = nil
+				}
+			case e, ok := <-errCh:
+				if !ok {
+					errCh = nil
+					continue
+				}
+				err = e
+			}
+		}
+		assert.IsError(t, err, context.Canceled)
+	})
+}
+
+func TestDefaultClient_ListFilesPage(t *testing.T) {
+	ctx := context.TODO()
+
+	files := make(map[string][]byte)
+	for i := range 5 {
+		files[fmt.Sprintf("logs/%d.log", i)] = nil
+		files[fmt.Sprintf("logs/%d.txt", i)] = nil
+	}
+	c, err := NewMemoryClient(NullLogger{}, files)
+	assert.NoError(t, err)
+
+	var pages [][]string
+	token := ""
+	for {
+		page, next, err := c.ListFilesPage(ctx, "bucket", "logs/*.log", token, 4)
+		assert.NoError(t, err)
+		pages = append(pages, page)
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	assert.Equal(t, [][]string{
+		{"logs/0.log", "logs/1.log"},
+		{"logs/2.log", "logs/3.log"},
+		{"logs/4.log"},
+	}, pages)
+
+	t.Run("params", func(t *testing.T) {
+		client := ifaces.ClientMock{
+			ListObjectsV2Func: func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+				return &s3.ListObjectsV2Output{
+					Contents:              []types.Object{{Key: aws.String("logs/a.log")}},
+					IsTruncated:           aws.Bool(true),
+					NextContinuationToken: aws.String("next"),
+				}, nil
+			},
+		}
+		c := DefaultClient{Svc: &client, Logger: NullLogger{}}
+
+		page, next, err := c.ListFilesPage(ctx, "bucket", "logs/*.log", "token", 10)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"logs/a.log"}, page)
+		assert.Equal(t, "next", next)
+
+		params := client.ListObjectsV2Calls()[0].Params
+		assert.Equal(t, "logs/", aws.StringValue(params.Prefix))
+		assert.Equal(t, "token", aws.StringValue(params.ContinuationToken))
+		assert.Equal(t, int32(10), *params.MaxKeys)
+
+		_, _, err = c.ListFilesPage(ctx, "bucket", "logs/*.log", "", 1001)
+		assert.EqualError(t, err, "page size must be between 0 and 1000, got 1001")
+	})
+}
+
+func TestDefaultClient_ListPageSize(t *testing.T) {
+	ctx := context.TODO()
+
+	client := ifaces.ClientMock{
+		ListObjectsV2Func: func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+			return &s3.ListObjectsV2Output{Contents: []types.Object{{Key: aws.String("logs/a.log")}}}, nil
+		},
+		ListObjectVersionsFunc: func(ctx context.Context, params *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+			return &s3.ListObjectVersionsOutput{}, nil
+		},
+	}
+	c := DefaultClient{Svc: &client, Logger: NullLogger{}}
+	assert.NoError(t, WithListPageSize(100)(&c.opts))
+
+	_, err := c.ListFiles(ctx, "bucket", "logs/*.log")
+	assert.NoError(t, err)
+	_, _, err = c.ListDir(ctx, "bucket", "logs/")
+	assert.NoError(t, err)
+	_, _, err = c.ListFilesPage(ctx, "bucket", "logs/*.log", "", 0)
+	assert.NoError(t, err)
+	_, _, err = c.ListFilesPage(ctx, "bucket", "logs/*.log", "", 10)
+	assert.NoError(t, err)
+	_, err = c.ListFileVersions(ctx, "bucket", "logs/")
+	assert.NoError(t, err)
+
+	var maxKeys []int32
+	for _, call := range client.ListObjectsV2Calls() {
+		maxKeys = append(maxKeys, *call.Params.MaxKeys)
+	}
+	assert.Equal(t, []int32{100, 100, 100, 10}, maxKeys)
+	assert.Equal(t, int32(100), *client.ListObjectVersionsCalls()[0].Params.MaxKeys)
+
+	// S3 picks the page size when none is set.
+	c.opts = ClientOpts{}
+	_, err = c.ListFiles(ctx, "bucket", "logs/*.log")
+	assert.NoError(t, err)
+	assert.Zero(t, client.ListObjectsV2Calls()[4].Params.MaxKeys)
+
+	for _, size := range []int{0, -1, 1001} {
+		assert.EqualError(t, WithListPageSize(size)(&c.opts), fmt.Sprintf("list page size must be between 1 and 1000, got %d", size))
+	}
+}
+
+func TestDefaultClient_ListFiles_CaseInsensitive(t *testing.T) {
+	ctx := context.TODO()
+
+	files := map[string][]byte{
+		"2024/Logs/App/One.LOG":   nil,
+		"2024/logs/app/two.log":   nil,
+		"2024/LOGS/WEB/three.Log": nil,
+		"2024/logs/app/notes.txt": nil,
+	}
+	c, err := NewMemoryClient(NullLogger{}, files, WithCaseInsensitiveMatch(true))
+	assert.NoError(t, err)
+
+	matches, err := c.ListFiles(ctx, "bucket", "2024/logs/app/*.log")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2024/Logs/App/One.LOG", "2024/logs/app/two.log"}, matches)
+
+	matches, err = c.ListFiles(ctx, "bucket", "2024/logs/{app,web}/*.log")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2024/LOGS/WEB/three.Log", "2024/Logs/App/One.LOG", "2024/logs/app/two.log"}, matches)
+
+	// patterns without wildcards match a single key in any case.
+	matches, err = c.ListFiles(ctx, "bucket", "2024/logs/app/one.log")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2024/Logs/App/One.LOG"}, matches)
+
+	page, _, err := c.ListFilesPage(ctx, "bucket", "2024/LOGS/**/*.LOG", "", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2024/LOGS/WEB/three.Log", "2024/Logs/App/One.LOG", "2024/logs/app/two.log"}, page)
+
+	// matching is case-sensitive by default.
+	c, err = NewMemoryClient(NullLogger{}, files)
+	assert.NoError(t, err)
+	matches, err = c.ListFiles(ctx, "bucket", "2024/logs/app/*.log")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2024/logs/app/two.log"}, matches)
+
+	t.Run("listed prefix", func(t *testing.T) {
+		client := ifaces.ClientMock{
+			ListObjectsV2Func: func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+				return &s3.ListObjectsV2Output{}, nil
+			},
+		}
+		c := DefaultClient{Svc: &client, Logger: NullLogger{}, opts: ClientOpts{CaseInsensitiveMatch: true}}
+
+		_, err := c.ListFiles(ctx, "bucket", "2024/logs/*.log")
+		assert.NoError(t, err)
+		assert.Equal(t, "2024/", aws.StringValue(client.ListObjectsV2Calls()[0].Params.Prefix))
+
+		_, err = c.ListFiles(ctx, "bucket", "logs/*.log")
+		assert.NoError(t, err)
+		assert.Zero(t, client.ListObjectsV2Calls()[1].Params.Prefix)
+	})
+}
+
+func TestDefaultClient_ListFilesWithMetadata(t *testing.T) {
+	c, err := NewMemoryClient(NullLogger{}, map[string][]byte{
+		"logs/a.log":  []byte("a"),
+		"logs/b.log":  []byte("bb"),
+		"logs/c.txt":  []byte("c"),
+		"other/d.log": []byte("d"),
+	})
+	assert.NoError(t, err)
+
+	objects, err := c.ListFilesWithMetadata(context.TODO(), "bucket", "logs/*.log")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(objects))
+	assert.Equal(t, "logs/a.log", objects[0].Key)
+	assert.Equal(t, int64(1), objects[0].Size)
+	assert.Equal(t, "logs/b.log", objects[1].Key)
+	assert.Equal(t, int64(2), objects[1].Size)
+	assert.NotZero(t, objects[1].ETag)
+
+	// objects whose ETag is known are left out.
+	newObjects := FilterNewETags(objects, map[string]bool{objects[0].ETag: true})
+	assert.Equal(t, []ObjectInfo{objects[1]}, newObjects)
+}
+
+func TestDefaultClient_WriteFileMultipart_Checksum(t *testing.T) {
+	client := &ifaces.ClientMock{
+		CreateMultipartUploadFunc: func(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+			return &s3.CreateMultipartUploadOutput{UploadId: aws.String("upload-id")}, nil
+		},
+		UploadPartFunc: func(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+			return &s3.UploadPartOutput{
+				ETag:          aws.String(fmt.Sprintf(`"etag-%d"`, *params.PartNumber)),
+				ChecksumCRC32: aws.String(fmt.Sprintf("crc-%d", *params.PartNumber)),
+			}, nil
+		},
+		CompleteMultipartUploadFunc: func(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+			return &s3.CompleteMultipartUploadOutput{}, nil
+		},
+	}
+	c := DefaultClient{Svc: client, Logger: NullLogger{}}
+	assert.NoError(t, WithRequestChecksumCalculation("CRC32")(&c.opts))
+
+	body := bytes.Repeat([]byte("x"), minPartSize+10)
+	assert.NoError(t, c.WriteFileMultipart(context.TODO(), "bucket", "file.log", bytes.NewReader(body), minPartSize))
+
+	assert.Equal(t, types.ChecksumAlgorithmCrc32, client.CreateMultipartUploadCalls()[0].Params.ChecksumAlgorithm)
+	for _, call := range client.UploadPartCalls() {
+		assert.Equal(t, types.ChecksumAlgorithmCrc32, call.Params.ChecksumAlgorithm)
+	}
+	assert.Equal(t, []types.CompletedPart{
+		{ETag: aws.String(`"etag-1"`), PartNumber: aws.Int32(1), ChecksumCRC32: aws.String("crc-1")},
+		{ETag: aws.String(`"etag-2"`), PartNumber: aws.Int32(2), ChecksumCRC32: aws.String("crc-2")},
+	}, client.CompleteMultipartUploadCalls()[0].Params.MultipartUpload.Parts)
+}
@@ -0,0 +1,102 @@
+package s3client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// DefaultCredentialsExpiryWindow is how long before they expire shared credentials are refreshed
+// when not set with WithSharedCredentialsCache.
+const DefaultCredentialsExpiryWindow = 5 * time.Minute
+
+// sharedCredentials holds the credentials providers shared by the clients created with
+// WithSharedCredentialsCache, by the key of the options they are resolved with.
+var sharedCredentials = struct {
+	sync.Mutex
+	m map[string]*sharedProvider
+}{m: map[string]*sharedProvider{}}
+
+// sharedProvider is a credentials provider shared by clients, along with the number of
+// clients not closed yet using it.
+type sharedProvider struct {
+	provider aws.CredentialsProvider
+	clients  int
+}
+
+// shareCredentials replaces the credentials provider of cfg with the one of the first client
+// created with the same credentials options, so they all use the same cached credentials.
+// Custom providers and anonymous credentials are left as they are.
+// The returned function, nil when the provider isn't shared, releases the client's hold on the
+// shared provider, which is dropped once released by all of its clients. It is safe to call
+// more than once.
+func (o *ClientOpts) shareCredentials(cfg *aws.Config) (release func()) {
+	if !o.SharedCredentials || o.CredentialsProvider != nil || o.Anonymous || cfg.Credentials == nil {
+		return nil
+	}
+
+	key := o.credentialsKey()
+
+	sharedCredentials.Lock()
+	defer sharedCredentials.Unlock()
+
+	shared, ok := sharedCredentials.m[key]
+	if !ok {
+		shared = &sharedProvider{provider: cfg.Credentials}
+		sharedCredentials.m[key] = shared
+	}
+	shared.clients++
+	cfg.Credentials = shared.provider
+
+	return sync.OnceFunc(func() {
+		sharedCredentials.Lock()
+		defer sharedCredentials.Unlock()
+
+		shared.clients--
+		if shared.clients == 0 && sharedCredentials.m[key] == shared {
+			delete(sharedCredentials.m, key)
+		}
+	})
+}
+
+// credentialsKey returns the key of the options credentials are resolved with. It is a digest,
+// so that the secret key isn't held in the clear by the key itself.
+func (o *ClientOpts) credentialsKey() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%q %q %q %q\n", o.Region, o.Endpoint, o.AccessKey, o.SecretKey)
+	fmt.Fprintf(h, "%q %q %q %v %q\n", o.AssumeRoleARN, o.AssumeRoleSessionName, o.AssumeRoleExternalID, o.AssumeRoleDuration, o.AssumeRoleMFASerial)
+	for _, step := range o.AssumeRoleChain {
+		fmt.Fprintf(h, "%q %q %q %v\n", step.RoleARN, step.ExternalID, step.SessionName, step.Duration)
+	}
+	fmt.Fprintf(h, "%q %q %q\n", o.RolesAnywhereTrustAnchorARN, o.RolesAnywhereProfileARN, o.RolesAnywhereRoleARN)
+	if o.RolesAnywhereCertificate != nil {
+		for _, der := range o.RolesAnywhereCertificate.Certificate {
+			h.Write(der)
+		}
+	}
+	fmt.Fprintf(h, "%q %q %q %v\n", o.WebIdentityRoleARN, o.WebIdentityTokenFile, o.WebIdentitySessionName, o.credentialsExpiryWindow())
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// credentialsExpiryWindow returns how long before they expire credentials are refreshed.
+// Credentials are only refreshed early when shared, to keep the default behavior of the SDK otherwise.
+func (o *ClientOpts) credentialsExpiryWindow() time.Duration {
+	if !o.SharedCredentials {
+		return 0
+	}
+	if o.CredentialsExpiryWindow > 0 {
+		return o.CredentialsExpiryWindow
+	}
+	return DefaultCredentialsExpiryWindow
+}
+
+// credentialsCacheOptions sets the refresh window of the credentials caches of the client.
+func (o *ClientOpts) credentialsCacheOptions(options *aws.CredentialsCacheOptions) {
+	if window := o.credentialsExpiryWindow(); window > 0 {
+		options.ExpiryWindow = window
+	}
+}
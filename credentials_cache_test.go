@@ -0,0 +1,151 @@
+package s3client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+func TestWithSharedCredentialsCache(t *testing.T) {
+	ctx := context.TODO()
+
+	var calls int32
+	expiresIn := time.Hour
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		_, _ = fmt.Fprintf(w, `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleResult>
+    <Credentials>
+      <AccessKeyId>assumed-%d</AccessKeyId>
+      <SecretAccessKey>secret</SecretAccessKey>
+      <SessionToken>token</SessionToken>
+      <Expiration>%s</Expiration>
+    </Credentials>
+  </AssumeRoleResult>
+</AssumeRoleResponse>`, n, time.Now().Add(expiresIn).UTC().Format(time.RFC3339))
+	}))
+	defer srv.Close()
+
+	// newProvider returns the credentials provider of a client created with the given options.
+	newProvider := func(optFns ...ClientOptsFunc) aws.CredentialsProvider {
+		var opts ClientOpts
+		for _, fn := range append([]ClientOptsFunc{WithStaticCredentials("source", "secret")}, optFns...) {
+			assert.NoError(t, fn(&opts))
+		}
+
+		cfg := aws.Config{
+			Region:       "us-east-1",
+			BaseEndpoint: aws.String(srv.URL),
+			Credentials:  credentials.NewStaticCredentialsProvider(opts.AccessKey, opts.SecretKey, ""),
+		}
+		opts.applyCredentials(&cfg)
+		opts.shareCredentials(&cfg)
+		return cfg.Credentials
+	}
+
+	retrieve := func(provider aws.CredentialsProvider) string {
+		creds, err := provider.Retrieve(ctx)
+		assert.NoError(t, err)
+		return creds.AccessKeyID
+	}
+
+	t.Run("shared", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		role := WithAssumeRoleCredentialOptions("arn:aws:iam::123456789012:role/shared", "", "", nil)
+
+		first := retrieve(newProvider(role, WithSharedCredentialsCache(0)))
+		second := retrieve(newProvider(role, WithSharedCredentialsCache(0)))
+		assert.Equal(t, first, second)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+		// another role gets credentials of its own.
+		other := WithAssumeRoleCredentialOptions("arn:aws:iam::123456789012:role/other", "", "", nil)
+		assert.NotEqual(t, first, retrieve(newProvider(other, WithSharedCredentialsCache(0))))
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("not shared", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		role := WithAssumeRoleCredentialOptions("arn:aws:iam::123456789012:role/own", "", "", nil)
+
+		retrieve(newProvider(role))
+		retrieve(newProvider(role))
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("refreshed within the expiry window", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		expiresIn = 2 * time.Minute
+		defer func() { expiresIn = time.Hour }()
+		role := WithAssumeRoleCredentialOptions("arn:aws:iam::123456789012:role/expiring", "", "", nil)
+
+		provider := newProvider(role, WithSharedCredentialsCache(5*time.Minute))
+		retrieve(provider)
+		retrieve(provider)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+		provider = newProvider(role, WithSharedCredentialsCache(time.Minute))
+		retrieve(provider)
+		retrieve(provider)
+		assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("released on close", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		role := WithAssumeRoleCredentialOptions("arn:aws:iam::123456789012:role/released", "", "", nil)
+
+		share := func() (aws.CredentialsProvider, func()) {
+			var opts ClientOpts
+			for _, fn := range []ClientOptsFunc{WithStaticCredentials("source", "secret"), role, WithSharedCredentialsCache(0)} {
+				assert.NoError(t, fn(&opts))
+			}
+			cfg := aws.Config{
+				Region:       "us-east-1",
+				BaseEndpoint: aws.String(srv.URL),
+				Credentials:  credentials.NewStaticCredentialsProvider(opts.AccessKey, opts.SecretKey, ""),
+			}
+			opts.applyCredentials(&cfg)
+			release := opts.shareCredentials(&cfg)
+			return cfg.Credentials, release
+		}
+
+		entries := func() int {
+			sharedCredentials.Lock()
+			defer sharedCredentials.Unlock()
+			return len(sharedCredentials.m)
+		}
+		before := entries()
+
+		first, releaseFirst := share()
+		second, releaseSecond := share()
+		assert.Equal(t, retrieve(first), retrieve(second))
+		assert.Equal(t, before+1, entries())
+
+		// the provider is kept as long as a client holds it, however often the others release it.
+		releaseFirst()
+		releaseFirst()
+		assert.Equal(t, before+1, entries())
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+		releaseSecond()
+		assert.Equal(t, before, entries())
+
+		third, releaseThird := share()
+		defer releaseThird()
+		retrieve(third)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		var opts ClientOpts
+		assert.EqualError(t, WithSharedCredentialsCache(-time.Second)(&opts), "credentials expiry window must not be negative, got -1s")
+	})
+}
@@ -0,0 +1,93 @@
+package s3client
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/text/transform"
+)
+
+// ReadCSV reads the specified CSV file from the given S3 bucket and sends each of its rows
+// through a channel as a map from the column names in the file's header row to the row's fields.
+// Quoted fields may span several lines. The delimiter defaults to a comma when zero, use '\t'
+// for TSV files. Reading stops at the first error, which is sent through the error channel.
+func (c *DefaultClient) ReadCSV(ctx context.Context, bucket string, file string, delimiter rune) (<-chan map[string]string, <-chan error) {
+	out := make(chan map[string]string)
+	errChan := make(chan error)
+
+	go func() {
+		defer close(errChan)
+		defer close(out)
+
+		c.logger().Info("Started processing file: %s from bucket: %s", file, bucket)
+
+		resp, err := c.getObject(ctx, c.getObjectInput(bucket, file))
+		if err != nil {
+			errChan <- mapError(err)
+			return
+		}
+		defer func(Body io.ReadCloser) {
+			err := Body.Close()
+			if err != nil {
+				errChan <- err
+			}
+		}(resp.Body)
+
+		info := newObjectInfoFromGetObject(file, resp)
+		reader, err := c.newFileReader(info, resp.Body)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		defer func(reader io.ReadCloser) {
+			err := reader.Close()
+			if err != nil {
+				errChan <- err
+			}
+		}(reader)
+
+		var decoded io.Reader = reader
+		if enc := charsetFor(file, info.ContentType); enc != nil {
+			decoded = transform.NewReader(reader, enc.NewDecoder())
+		}
+
+		r := csv.NewReader(decoded)
+		if delimiter != 0 {
+			r.Comma = delimiter
+		}
+
+		header, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			c.logger().Info("Completed processing of file: %s on bucket: %s", file, bucket)
+			return
+		}
+		if err != nil {
+			errChan <- fmt.Errorf("error reading header of file %s: %w", file, err)
+			return
+		}
+
+		for {
+			record, err := r.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				errChan <- fmt.Errorf("error reading file %s: %w", file, err)
+				return
+			}
+
+			row := make(map[string]string, len(header))
+			for i, name := range header {
+				row[name] = record[i]
+			}
+			out <- row
+		}
+
+		c.logger().Info("Completed processing of file: %s on bucket: %s", file, bucket)
+	}()
+
+	return observeStream(c, "ReadCSV", out, errChan)
+}
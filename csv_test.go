@@ -0,0 +1,82 @@
+package s3client
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/calyptia/go-s3-client/ifaces"
+)
+
+func TestDefaultClient_ReadCSV(t *testing.T) {
+	ctx := context.TODO()
+
+	newClient := func(content string) *DefaultClient {
+		return &DefaultClient{
+			Svc: &ifaces.ClientMock{
+				GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+					return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(content))}, nil
+				},
+			},
+			Logger: NullLogger{},
+		}
+	}
+
+	readAll := func(t *testing.T, outCh <-chan map[string]string, errCh <-chan error) []map[string]string {
+		t.Helper()
+
+		var rows []map[string]string
+		for row := range outCh {
+			rows = append(rows, row)
+		}
+		if err := <-errCh; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return rows
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		c := newClient("id,message\n1,\"hello, world\"\n2,\"multi\nline\"\n")
+
+		outCh, errCh := c.ReadCSV(ctx, "bucket", "file.csv", 0)
+		assert.Equal(t, []map[string]string{
+			{"id": "1", "message": "hello, world"},
+			{"id": "2", "message": "multi\nline"},
+		}, readAll(t, outCh, errCh))
+	})
+
+	t.Run("tsv", func(t *testing.T) {
+		c := newClient("id\tmessage\n1\thello, world\n")
+
+		outCh, errCh := c.ReadCSV(ctx, "bucket", "file.tsv", '\t')
+		assert.Equal(t, []map[string]string{
+			{"id": "1", "message": "hello, world"},
+		}, readAll(t, outCh, errCh))
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		c := newClient("")
+
+		outCh, errCh := c.ReadCSV(ctx, "bucket", "file.csv", 0)
+		assert.Zero(t, readAll(t, outCh, errCh))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		c := newClient("id,message\n1,hello,world\n")
+
+		outCh, errCh := c.ReadCSV(ctx, "bucket", "file.csv", 0)
+		for {
+			select {
+			case <-outCh:
+				continue
+			case err := <-errCh:
+				assert.EqualError(t, err, "error reading file file.csv: record on line 2: wrong number of fields")
+			}
+			break
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package s3client
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/smithy-go"
+)
+
+var (
+	// ErrNoSuchKey is returned when the requested object does not exist.
+	ErrNoSuchKey = errors.New("no such key")
+	// ErrNoSuchBucket is returned when the requested bucket does not exist.
+	ErrNoSuchBucket = errors.New("no such bucket")
+	// ErrAccessDenied is returned when the credentials are not allowed to perform the request.
+	ErrAccessDenied = errors.New("access denied")
+	// ErrPreconditionFailed is returned when a conditional operation is not applied
+	// because the object no longer matches the expected state.
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrNotModified is returned when a conditional read is not performed
+	// because the object has not changed.
+	ErrNotModified = errors.New("not modified")
+	// ErrTooLarge is returned when an object is larger than the size a read is capped at.
+	ErrTooLarge = errors.New("too large")
+	// ErrNoMatchingFiles is returned when no object matches the pattern looked up.
+	ErrNoMatchingFiles = errors.New("no matching files")
+	// ErrWaitTimeout is returned when an awaited object doesn't appear in time.
+	ErrWaitTimeout = errors.New("timed out waiting for object")
+	// ErrBinaryContent is returned when an object read line by line turns out to hold binary content.
+	ErrBinaryContent = errors.New("binary content")
+	// ErrListThrottled is returned when a listing is aborted by the circuit breaker set with
+	// WithListCircuitBreaker, after too many consecutive throttle errors.
+	ErrListThrottled = errors.New("listing throttled")
+	// ErrNotGzip is returned when an object read as gzipped isn't.
+	ErrNotGzip = errors.New("not gzipped")
+	// ErrMemberNotFound is returned when an archive holds no member with the name looked up.
+	ErrMemberNotFound = errors.New("member not found")
+	// ErrNotImplemented is returned when S3, or the MemoryClient, doesn't implement the request.
+	ErrNotImplemented = errors.New("not implemented")
+	// ErrInvalidBucketARN is returned when a bucket given as an ARN doesn't name an access point.
+	ErrInvalidBucketARN = errors.New("invalid bucket ARN")
+)
+
+// PartialListError is returned, wrapped, when listing objects fails after some pages have been
+// listed already. The objects of those pages are returned along with the error, so callers that
+// can work with an incomplete listing can use them, and others must discard them.
+type PartialListError struct {
+	// Pages is the number of pages listed before the failure.
+	Pages int
+	// Err is the error the listing of the next page failed with.
+	Err error
+}
+
+func (e *PartialListError) Error() string {
+	return fmt.Sprintf("listing failed after %d page(s): %v", e.Pages, e.Err)
+}
+
+func (e *PartialListError) Unwrap() error {
+	return e.Err
+}
+
+// apiErrorCodes maps the S3 API error codes onto the sentinel errors of this package.
+// HEAD requests carry no response body, so their errors only expose the HTTP status text.
+var apiErrorCodes = map[string]error{
+	"NoSuchKey":          ErrNoSuchKey,
+	"NotFound":           ErrNoSuchKey,
+	"NoSuchBucket":       ErrNoSuchBucket,
+	"AccessDenied":       ErrAccessDenied,
+	"Forbidden":          ErrAccessDenied,
+	"PreconditionFailed": ErrPreconditionFailed,
+	"NotModified":        ErrNotModified,
+	"NotImplemented":     ErrNotImplemented,
+}
+
+// mapError wraps err with the sentinel error matching its S3 API error code, if any,
+// so callers can check it with errors.Is while still being able to unwrap the original error.
+func mapError(err error) error {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+
+	sentinel, ok := apiErrorCodes[apiErr.ErrorCode()]
+	if !ok || errors.Is(err, sentinel) {
+		return err
+	}
+
+	return fmt.Errorf("%w: %w", sentinel, err)
+}
+
+// RequestIDs returns the request ID and host ID S3 assigned to the failed request that caused
+// err, which AWS support asks for when investigating an issue. Either is empty if err doesn't
+// carry it, e.g. when the request never reached S3.
+// Errors returned by this package keep the response error of the SDK in their chain, so both IDs
+// are also part of their message.
+func RequestIDs(err error) (requestID string, hostID string) {
+	var withRequestID interface{ ServiceRequestID() string }
+	if errors.As(err, &withRequestID) {
+		requestID = withRequestID.ServiceRequestID()
+	}
+
+	var withHostID interface{ ServiceHostID() string }
+	if errors.As(err, &withHostID) {
+		hostID = withHostID.ServiceHostID()
+	}
+
+	return requestID, hostID
+}
+
+// BucketRegion returns the region S3 reported the bucket lives in when err is caused by a
+// request sent to another region, e.g. to create a client for it. Clients created with
+// WithAutoRegion retry such requests in the reported region on their own.
+func BucketRegion(err error) (string, bool) {
+	return bucketRegionFromError(err)
+}
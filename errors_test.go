@@ -0,0 +1,83 @@
+package s3client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+func TestMapError(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected error
+	}{
+		{"NoSuchKey", ErrNoSuchKey},
+		{"NotFound", ErrNoSuchKey},
+		{"NoSuchBucket", ErrNoSuchBucket},
+		{"AccessDenied", ErrAccessDenied},
+		{"Forbidden", ErrAccessDenied},
+		{"PreconditionFailed", ErrPreconditionFailed},
+		{"NotModified", ErrNotModified},
+	}
+	for _, tc := range tests {
+		t.Run(tc.code, func(t *testing.T) {
+			apiErr := &smithy.GenericAPIError{Code: tc.code, Message: "message"}
+			err := mapError(fmt.Errorf("operation error: %w", apiErr))
+			assert.IsError(t, err, tc.expected)
+
+			// the original error must still be reachable.
+			var got smithy.APIError
+			assert.True(t, errors.As(err, &got))
+			assert.Equal(t, tc.code, got.ErrorCode())
+		})
+	}
+
+	t.Run("unknown code", func(t *testing.T) {
+		err := &smithy.GenericAPIError{Code: "SlowDown"}
+		assert.Equal(t, error(err), mapError(err))
+	})
+
+	t.Run("not an api error", func(t *testing.T) {
+		err := errors.New("boom")
+		assert.Equal(t, err, mapError(err))
+	})
+}
+
+// hostIDError mimics the response errors of the S3 client, which also carry a host ID.
+type hostIDError struct {
+	*awshttp.ResponseError
+	hostID string
+}
+
+func (e *hostIDError) ServiceHostID() string { return e.hostID }
+
+func TestRequestIDs(t *testing.T) {
+	err := &smithy.OperationError{
+		ServiceID:     "S3",
+		OperationName: "GetObject",
+		Err: &hostIDError{
+			ResponseError: &awshttp.ResponseError{
+				ResponseError: &smithyhttp.ResponseError{
+					Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusNotFound}},
+					Err:      &smithy.GenericAPIError{Code: "NoSuchKey"},
+				},
+				RequestID: "request-id",
+			},
+			hostID: "host-id",
+		},
+	}
+
+	requestID, hostID := RequestIDs(fmt.Errorf("error reading file from s3: %w", mapError(err)))
+	assert.Equal(t, "request-id", requestID)
+	assert.Equal(t, "host-id", hostID)
+
+	requestID, hostID = RequestIDs(errors.New("connection refused"))
+	assert.Zero(t, requestID)
+	assert.Zero(t, hostID)
+}
@@ -0,0 +1,318 @@
+package s3client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// S3FS is a read-only fs.FS over the objects of a bucket under a prefix, treating "/" in
+// their keys as the directory separator. Files are read decompressed, as ReadFile does,
+// while their size is the one stored on S3.
+// As fs.FS methods take no context, every request is made with the context S3FS is created with.
+type S3FS struct {
+	ctx    context.Context
+	client *DefaultClient
+	bucket string
+	prefix string
+}
+
+var (
+	// ErrNotDir is returned, wrapped in an *fs.PathError, when a file is read as a directory.
+	ErrNotDir = fmt.Errorf("not a directory: %w", fs.ErrInvalid)
+	// ErrIsDir is returned, wrapped in an *fs.PathError, when a directory is read as a file.
+	ErrIsDir = fmt.Errorf("is a directory: %w", fs.ErrInvalid)
+)
+
+var (
+	_ fs.FS        = (*S3FS)(nil)
+	_ fs.ReadDirFS = (*S3FS)(nil)
+	_ fs.StatFS    = (*S3FS)(nil)
+	_ fs.GlobFS    = (*S3FS)(nil)
+)
+
+// NewS3FS returns an S3FS over the objects of the bucket under the given prefix,
+// which is the root of the file system. An empty prefix is the bucket root.
+func NewS3FS(ctx context.Context, client *DefaultClient, bucket, prefix string) *S3FS {
+	return &S3FS{
+		ctx:    ctx,
+		client: client,
+		bucket: bucket,
+		prefix: strings.TrimSuffix(prefix, "/"),
+	}
+}
+
+// key returns the object key of the given file system path.
+func (f *S3FS) key(name string) string {
+	if name == "." {
+		return f.prefix
+	}
+	if f.prefix == "" {
+		return name
+	}
+	return f.prefix + "/" + name
+}
+
+// Open opens the named file or directory.
+func (f *S3FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if name != "." {
+		info, reader, err := f.client.openReader(f.ctx, f.bucket, f.key(name))
+		if err == nil {
+			return &s3File{info: newFileInfo(name, info), ReadCloser: reader}, nil
+		}
+		if !errors.Is(err, ErrNoSuchKey) {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+	}
+
+	info, err := f.statDir("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return &s3Dir{fs: f, name: name, info: info}, nil
+}
+
+// ReadDir reads the named directory and returns its entries sorted by name.
+func (f *S3FS) ReadDir(name string) (_ []fs.DirEntry, err error) {
+	defer f.client.observe("ReadDir", time.Now(), &err)
+
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	dirs, files, err := f.client.ListDir(f.ctx, f.bucket, f.key(name))
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+
+	entries := make([]fs.DirEntry, 0, len(dirs)+len(files))
+	for _, dir := range dirs {
+		entries = append(entries, &fileInfo{name: path.Base(dir), dir: true})
+	}
+	for _, file := range files {
+		if strings.HasSuffix(file.Key, "/") {
+			// markers created by consoles to show empty directories are not files.
+			continue
+		}
+		entries = append(entries, newFileInfo(file.Key, file))
+	}
+
+	if len(entries) == 0 && name != "." {
+		// nothing is listed under a file nor a missing directory, only the object tells them apart.
+		_, err := f.client.headObject(f.ctx, f.bucket, f.key(name))
+		if err == nil {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: ErrNotDir}
+		}
+		if err = mapError(err); errors.Is(err, ErrNoSuchKey) {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+		}
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fmt.Errorf("error checking file on s3: %w", err)}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// Stat returns the fs.FileInfo describing the named file or directory.
+func (f *S3FS) Stat(name string) (_ fs.FileInfo, err error) {
+	defer f.client.observe("Stat", time.Now(), &err)
+
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if name != "." {
+		head, err := f.client.headObject(f.ctx, f.bucket, f.key(name))
+		if err == nil {
+			return newFileInfo(name, newObjectInfoFromHeadObject(f.key(name), head)), nil
+		}
+		if err = mapError(err); !errors.Is(err, ErrNoSuchKey) {
+			return nil, &fs.PathError{Op: "stat", Path: name, Err: fmt.Errorf("error checking file on s3: %w", err)}
+		}
+	}
+
+	return f.statDir("stat", name)
+}
+
+// Glob returns the names of the files and directories matching pattern, or nil if there is
+// none. Patterns are matched as ListFiles matches them, with the doublestar syntax rather than
+// the one of path.Match: "*" doesn't cross a "/" but "**" matches any number of directories,
+// and braces give alternatives, as in "*.{html,tmpl}". Patterns path.Match rejects, such as an
+// unclosed "[", fail with path.ErrBadPattern as fs.Glob requires.
+// The files and directories are found with a single listing, of the objects matching pattern
+// or under the directories matching it.
+func (f *S3FS) Glob(pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	if !IsGlobPattern(pattern) {
+		if _, err := f.Stat(pattern); err != nil {
+			return nil, nil
+		}
+		return []string{pattern}, nil
+	}
+
+	root := ""
+	if f.prefix != "" {
+		root = escapeGlob(f.prefix) + "/"
+	}
+
+	// directories only exist through the objects below them.
+	files, err := f.client.ListFilesMulti(f.ctx, f.bucket, []string{root + pattern, root + pattern + "/**"})
+	if err != nil {
+		return nil, err
+	}
+
+	match := f.client.matchFunc(pattern)
+	seen := make(map[string]bool, len(files))
+	var matches []string
+	for _, file := range files {
+		// the file itself or any of the directories it is under can match.
+		segments := strings.Split(f.name(file), "/")
+		for i := range segments {
+			name := strings.Join(segments[:i+1], "/")
+			if !seen[name] && match(name) {
+				seen[name] = true
+				matches = append(matches, name)
+			}
+		}
+	}
+
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// name returns the file system path of the given object key.
+func (f *S3FS) name(key string) string {
+	if f.prefix == "" {
+		return key
+	}
+	return strings.TrimPrefix(key, f.prefix+"/")
+}
+
+// statDir returns the fs.FileInfo of the named directory, which exists if any object has
+// its key under it.
+func (f *S3FS) statDir(op, name string) (*fileInfo, error) {
+	info := &fileInfo{name: path.Base(name), dir: true}
+	if name == "." {
+		return info, nil
+	}
+
+	// a single key is enough to tell the directory exists.
+	resp, err := listPage(f.client, f.bucket, func(optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+		return f.client.Svc.ListObjectsV2(f.ctx, &s3.ListObjectsV2Input{
+			Bucket:       &f.bucket,
+			Prefix:       aws.String(f.key(name) + "/"),
+			MaxKeys:      aws.Int32(1),
+			RequestPayer: f.client.requestPayer(),
+		}, optFns...)
+	})
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fmt.Errorf("error listing directory from s3: %w", mapError(err))}
+	}
+	if len(resp.Contents) == 0 {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return info, nil
+}
+
+// fileInfo describes a file or directory of an S3FS, as both fs.FileInfo and fs.DirEntry.
+type fileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func newFileInfo(name string, info ObjectInfo) *fileInfo {
+	return &fileInfo{
+		name:    path.Base(name),
+		size:    info.Size,
+		modTime: info.LastModified,
+	}
+}
+
+func (i *fileInfo) Name() string               { return i.name }
+func (i *fileInfo) Size() int64                { return i.size }
+func (i *fileInfo) ModTime() time.Time         { return i.modTime }
+func (i *fileInfo) IsDir() bool                { return i.dir }
+func (i *fileInfo) Sys() any                   { return nil }
+func (i *fileInfo) Type() fs.FileMode          { return i.Mode().Type() }
+func (i *fileInfo) Info() (fs.FileInfo, error) { return i, nil }
+
+func (i *fileInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
+
+// s3File is an open file of an S3FS.
+type s3File struct {
+	io.ReadCloser
+	info *fileInfo
+}
+
+func (f *s3File) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+// s3Dir is an open directory of an S3FS, its entries are listed on the first read.
+type s3Dir struct {
+	fs      *S3FS
+	name    string
+	info    *fileInfo
+	entries []fs.DirEntry
+	listed  bool
+}
+
+func (d *s3Dir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *s3Dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: ErrIsDir}
+}
+
+func (d *s3Dir) Close() error {
+	return nil
+}
+
+func (d *s3Dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.listed {
+		entries, err := d.fs.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries = entries
+		d.listed = true
+	}
+
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	n = min(n, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
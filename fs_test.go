@@ -0,0 +1,268 @@
+package s3client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/smithy-go"
+
+	"github.com/calyptia/go-s3-client/ifaces"
+)
+
+// objectsMock returns a mock serving the given objects, listing them with prefixes and delimiters.
+func objectsMock(objects map[string]string) *ifaces.ClientMock {
+	lastModified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	return &ifaces.ClientMock{
+		GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			content, ok := objects[*params.Key]
+			if !ok {
+				return nil, &types.NoSuchKey{}
+			}
+			return &s3.GetObjectOutput{
+				Body:          io.NopCloser(strings.NewReader(content)),
+				ContentLength: aws.Int64(int64(len(content))),
+				LastModified:  aws.Time(lastModified),
+			}, nil
+		},
+		HeadObjectFunc: func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			content, ok := objects[*params.Key]
+			if !ok {
+				return nil, &smithy.GenericAPIError{Code: "NotFound"}
+			}
+			return &s3.HeadObjectOutput{
+				ContentLength: aws.Int64(int64(len(content))),
+				LastModified:  aws.Time(lastModified),
+			}, nil
+		},
+		ListObjectsV2Func: func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+			prefix := aws.StringValue(params.Prefix)
+			keys := make([]string, 0, len(objects))
+			for key := range objects {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+
+			out := &s3.ListObjectsV2Output{}
+			seen := map[string]bool{}
+			for _, key := range keys {
+				if !strings.HasPrefix(key, prefix) {
+					continue
+				}
+				if params.Delimiter != nil {
+					if i := strings.Index(key[len(prefix):], *params.Delimiter); i >= 0 {
+						dir := key[:len(prefix)+i+1]
+						if !seen[dir] {
+							seen[dir] = true
+							out.CommonPrefixes = append(out.CommonPrefixes, types.CommonPrefix{Prefix: aws.String(dir)})
+						}
+						continue
+					}
+				}
+				out.Contents = append(out.Contents, types.Object{
+					Key:          aws.String(key),
+					Size:         aws.Int64(int64(len(objects[key]))),
+					LastModified: aws.Time(lastModified),
+				})
+				if params.MaxKeys != nil && int32(len(out.Contents)) >= *params.MaxKeys {
+					break
+				}
+			}
+			return out, nil
+		},
+	}
+}
+
+func TestS3FS(t *testing.T) {
+	ctx := context.TODO()
+
+	objects := map[string]string{
+		"root/index.html":          "<html></html>",
+		"root/logs/2024/one.log":   "one",
+		"root/logs/2024/two.log":   "two",
+		"root/logs/readme.txt":     "readme",
+		"root/templates/base.tmpl": "{{ . }}",
+		"other/file.txt":           "outside of the root",
+	}
+
+	c := &DefaultClient{
+		Svc:    objectsMock(objects),
+		Logger: NullLogger{},
+	}
+	fsys := NewS3FS(ctx, c, "bucket", "root/")
+
+	t.Run("fstest", func(t *testing.T) {
+		err := fstest.TestFS(fsys, "index.html", "logs/2024/one.log", "logs/2024/two.log", "logs/readme.txt", "templates/base.tmpl")
+		assert.NoError(t, err)
+	})
+
+	t.Run("walk", func(t *testing.T) {
+		var paths []string
+		err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			paths = append(paths, path)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			".",
+			"index.html",
+			"logs",
+			"logs/2024",
+			"logs/2024/one.log",
+			"logs/2024/two.log",
+			"logs/readme.txt",
+			"templates",
+			"templates/base.tmpl",
+		}, paths)
+	})
+
+	t.Run("glob", func(t *testing.T) {
+		matches, err := fs.Glob(fsys, "logs/*")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"logs/2024", "logs/readme.txt"}, matches)
+
+		matches, err = fs.Glob(fsys, "logs/*/*.log")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"logs/2024/one.log", "logs/2024/two.log"}, matches)
+
+		matches, err = fs.Glob(fsys, "*.{html,tmpl}")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"index.html"}, matches)
+
+		matches, err = fs.Glob(fsys, "templates")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"templates"}, matches)
+
+		matches, err = fs.Glob(fsys, "**/*.log")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"logs/2024/one.log", "logs/2024/two.log"}, matches)
+
+		matches, err = fs.Glob(fsys, "logs/**")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"logs/2024", "logs/2024/one.log", "logs/2024/two.log", "logs/readme.txt"}, matches)
+
+		_, err = fs.Glob(fsys, "logs/[")
+		assert.IsError(t, err, path.ErrBadPattern)
+	})
+
+	t.Run("glob lists once", func(t *testing.T) {
+		svc := objectsMock(objects)
+		fsys := NewS3FS(ctx, &DefaultClient{Svc: svc, Logger: NullLogger{}}, "bucket", "root")
+
+		matches, err := fs.Glob(fsys, "logs/*")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"logs/2024", "logs/readme.txt"}, matches)
+		assert.Equal(t, 1, len(svc.ListObjectsV2Calls()))
+	})
+
+	t.Run("read file", func(t *testing.T) {
+		content, err := fs.ReadFile(fsys, "logs/readme.txt")
+		assert.NoError(t, err)
+		assert.Equal(t, "readme", string(content))
+	})
+
+	t.Run("not exist", func(t *testing.T) {
+		_, err := fsys.Open("missing.txt")
+		assert.True(t, errors.Is(err, fs.ErrNotExist))
+
+		_, err = fsys.Stat("logs/missing")
+		assert.True(t, errors.Is(err, fs.ErrNotExist))
+
+		_, err = fsys.ReadDir("missing")
+		assert.True(t, errors.Is(err, fs.ErrNotExist))
+	})
+
+	t.Run("not a directory", func(t *testing.T) {
+		client := objectsMock(objects)
+		fsys := NewS3FS(ctx, &DefaultClient{Svc: client, Logger: NullLogger{}}, "bucket", "root/")
+
+		_, err := fsys.ReadDir("index.html")
+		assert.IsError(t, err, ErrNotDir)
+		assert.IsError(t, err, fs.ErrInvalid)
+
+		// a missing directory is told apart from a file with a single HEAD request.
+		_, err = fsys.ReadDir("missing")
+		assert.IsError(t, err, fs.ErrNotExist)
+		assert.Equal(t, 2, len(client.ListObjectsV2Calls()))
+		assert.Equal(t, 2, len(client.HeadObjectCalls()))
+	})
+
+	t.Run("is a directory", func(t *testing.T) {
+		f, err := fsys.Open("logs")
+		assert.NoError(t, err)
+		defer f.Close()
+
+		_, err = f.Read(make([]byte, 1))
+		assert.IsError(t, err, ErrIsDir)
+		assert.IsError(t, err, fs.ErrInvalid)
+	})
+
+	t.Run("invalid path", func(t *testing.T) {
+		_, err := fsys.Open("../other/file.txt")
+		assert.True(t, errors.Is(err, fs.ErrInvalid))
+	})
+}
+
+func TestS3FS_AutoRegion(t *testing.T) {
+	ctx := context.TODO()
+
+	objects := map[string]string{
+		"index.html":          "<html></html>",
+		"logs/2024/one.log":   "one",
+		"templates/base.tmpl": "{{ . }}",
+	}
+
+	client := objectsMock(objects)
+	headObject, listObjectsV2 := client.HeadObjectFunc, client.ListObjectsV2Func
+	client.HeadObjectFunc = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+		if regionOf(optFns) != "eu-west-1" {
+			return nil, newRedirectError("eu-west-1")
+		}
+		return headObject(ctx, params, optFns...)
+	}
+	client.ListObjectsV2Func = func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+		if regionOf(optFns) != "eu-west-1" {
+			return nil, newRedirectError("eu-west-1")
+		}
+		return listObjectsV2(ctx, params, optFns...)
+	}
+
+	recorder := &opsRecorder{}
+	c := &DefaultClient{
+		Svc:    client,
+		Logger: NullLogger{},
+		opts:   ClientOpts{AutoRegion: true, MetricsRecorder: recorder},
+	}
+	fsys := NewS3FS(ctx, c, "bucket", "")
+
+	info, err := fs.Stat(fsys, "index.html")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(13), info.Size())
+
+	info, err = fs.Stat(fsys, "logs")
+	assert.NoError(t, err)
+	assert.True(t, info.IsDir())
+
+	_, err = fs.ReadDir(fsys, "index.html")
+	assert.IsError(t, err, ErrNotDir)
+
+	var ops []string
+	for _, op := range recorder.observed() {
+		ops = append(ops, op.op)
+	}
+	assert.Equal(t, []string{"Stat", "Stat", "ListDir", "ReadDir"}, ops)
+}
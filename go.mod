@@ -10,8 +10,12 @@ require (
 	github.com/aws/aws-sdk-go-v2/credentials v1.17.22
 	github.com/aws/aws-sdk-go-v2/feature/ec2/imds v1.16.8
 	github.com/aws/aws-sdk-go-v2/service/s3 v1.57.0
+	github.com/aws/aws-sdk-go-v2/service/sts v1.30.0
 	github.com/aws/smithy-go v1.20.2
 	github.com/bmatcuk/doublestar v1.3.4
+	github.com/golang/snappy v0.0.4
+	github.com/pierrec/lz4/v4 v4.1.21
+	golang.org/x/text v0.16.0
 )
 
 require (
@@ -27,6 +31,5 @@ require (
 	github.com/aws/aws-sdk-go-v2/service/internal/s3shared v1.17.12 // indirect
 	github.com/aws/aws-sdk-go-v2/service/sso v1.22.0 // indirect
 	github.com/aws/aws-sdk-go-v2/service/ssooidc v1.26.0 // indirect
-	github.com/aws/aws-sdk-go-v2/service/sts v1.30.0 // indirect
 	github.com/hexops/gotextdiff v1.0.3 // indirect
 )
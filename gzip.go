@@ -0,0 +1,36 @@
+package s3client
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// OpenGzipFile gets the specified gzipped file from the S3 bucket and returns the header of its
+// first gzip member, holding the original name, comment and modification time of the compressed
+// file, along with a reader over its decompressed contents, left undecoded otherwise. It fails with
+// an error wrapping ErrNotGzip if the object isn't gzipped, whatever its name. Closing the returned
+// reader closes the underlying object body as well.
+func (c *DefaultClient) OpenGzipFile(ctx context.Context, bucket string, file string) (_ gzip.Header, _ io.ReadCloser, err error) {
+	defer c.observe("OpenGzipFile", time.Now(), &err)
+
+	resp, err := c.getObject(ctx, c.getObjectInput(bucket, file))
+	if err != nil {
+		return gzip.Header{}, nil, fmt.Errorf("error reading file from s3: %w", mapError(err))
+	}
+
+	br := newSniffReader(resp.Body)
+	if !isGzip(br) {
+		resp.Body.Close()
+		return gzip.Header{}, nil, fmt.Errorf("file %s: %w", file, ErrNotGzip)
+	}
+
+	gr, err := gzip.NewReader(br)
+	if err != nil {
+		resp.Body.Close()
+		return gzip.Header{}, nil, fmt.Errorf("error reading gzip header of file %s: %w", file, err)
+	}
+	return gr.Header, &layeredReader{ReadCloser: gr, inner: resp.Body}, nil
+}
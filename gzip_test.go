@@ -0,0 +1,48 @@
+package s3client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestDefaultClient_OpenGzipFile(t *testing.T) {
+	ctx := context.TODO()
+	modTime := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	gw.Name = "app-2024-03-01.log"
+	gw.Comment = "rotated"
+	gw.ModTime = modTime
+	_, _ = gw.Write([]byte("a\nb\n"))
+	assert.NoError(t, gw.Close())
+
+	c, err := NewMemoryClient(NullLogger{}, map[string][]byte{
+		"logs/3f2a.gz": buf.Bytes(),
+		"logs/app.log": []byte("a\nb\n"),
+	})
+	assert.NoError(t, err)
+
+	header, reader, err := c.OpenGzipFile(ctx, "bucket", "logs/3f2a.gz")
+	assert.NoError(t, err)
+	defer reader.Close()
+	assert.Equal(t, "app-2024-03-01.log", header.Name)
+	assert.Equal(t, "rotated", header.Comment)
+	assert.True(t, modTime.Equal(header.ModTime))
+
+	data, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, "a\nb\n", string(data))
+
+	_, _, err = c.OpenGzipFile(ctx, "bucket", "logs/app.log")
+	assert.IsError(t, err, ErrNotGzip)
+
+	_, _, err = c.OpenGzipFile(ctx, "bucket", "logs/missing.gz")
+	assert.IsError(t, err, ErrNoSuchKey)
+}
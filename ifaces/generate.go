@@ -0,0 +1,15 @@
+// Package ifaces holds the interface of the S3 client used by s3client and its mock for tests.
+//
+// Client is generated from every method of *s3.Client, so both files only need to be
+// regenerated, with ifacemaker and moq, when the S3 service module is upgraded.
+package ifaces
+
+import "github.com/aws/aws-sdk-go-v2/service/s3"
+
+//go:generate sh -c "ifacemaker -f \"$(go list -m -f '{{.Dir}}' github.com/aws/aws-sdk-go-v2/service/s3)/api_*.go\" -s Client -i Client -p ifaces -y \"Client ...\" -c \"Code generated by ifacemaker; DO NOT EDIT.\" -o s3_client_interface.go"
+//go:generate moq -out s3_client_mock.go . Client
+
+// The real client must keep implementing the whole interface, which also guarantees that the
+// mock covers every S3 operation, e.g. HeadObject, PutObject, DeleteObjects, CopyObject,
+// tagging and multipart uploads.
+var _ Client = (*s3.Client)(nil)
@@ -0,0 +1,61 @@
+package s3client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ReadJSONLines reads the specified newline-delimited JSON file from the given S3 bucket
+// through the client's ReadFileLines, unmarshalling every line into a value of type T and sending it through
+// a channel. Blank lines are skipped.
+// A malformed line sends an error holding its line number through the error channel, and
+// stops reading unless skipMalformed is set, in which case reading carries on with the next line.
+func ReadJSONLines[T any](ctx context.Context, c Client, bucket string, file string, initialBufferSize int, maxBufferSize int, skipMalformed bool) (<-chan T, <-chan error) {
+	out := make(chan T)
+	errChan := make(chan error)
+
+	go func() {
+		defer close(errChan)
+		defer close(out)
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		lines, lineErrChan := c.ReadFileLines(ctx, bucket, file, initialBufferSize, maxBufferSize)
+		for {
+			select {
+			case line, ok := <-lines:
+				if !ok {
+					return
+				}
+				if strings.TrimSpace(line.Text) == "" {
+					continue
+				}
+
+				var v T
+				if err := json.Unmarshal([]byte(line.Text), &v); err != nil {
+					errChan <- fmt.Errorf("error parsing line %d of file %s: %w", line.LineNum, file, err)
+					if skipMalformed {
+						continue
+					}
+					go drain(lines, lineErrChan)
+					return
+				}
+
+				out <- v
+			case err, ok := <-lineErrChan:
+				if !ok {
+					lineErrChan = nil
+					continue
+				}
+				go drain(lines, lineErrChan)
+				errChan <- err
+				return
+			}
+		}
+	}()
+
+	return out, errChan
+}
@@ -0,0 +1,124 @@
+package s3client
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/calyptia/go-s3-client/ifaces"
+)
+
+func TestReadJSONLines(t *testing.T) {
+	ctx := context.TODO()
+
+	type record struct {
+		Level   string `json:"level"`
+		Message string `json:"msg"`
+	}
+
+	newClient := func(content string) *DefaultClient {
+		return &DefaultClient{
+			Svc: &ifaces.ClientMock{
+				GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+					return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(content))}, nil
+				},
+			},
+			Logger: NullLogger{},
+		}
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		c := newClient("{\"level\":\"info\",\"msg\":\"one\"}\n\n  \n{\"level\":\"warn\",\"msg\":\"two\"}\n")
+
+		outCh, errCh := ReadJSONLines[record](ctx, c, "bucket", "file.jsonl", 64*1024, 10*1024*1024, false)
+
+		var records []record
+		for r := range outCh {
+			records = append(records, r)
+		}
+		if err := <-errCh; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		assert.Equal(t, []record{{"info", "one"}, {"warn", "two"}}, records)
+	})
+
+	content := "{\"level\":\"info\",\"msg\":\"one\"}\nnot json\n{\"level\":\"warn\",\"msg\":\"two\"}"
+
+	t.Run("malformed", func(t *testing.T) {
+		c := newClient(content)
+
+		outCh, errCh := ReadJSONLines[record](ctx, c, "bucket", "file.jsonl", 64*1024, 10*1024*1024, false)
+
+		var records []record
+		for {
+			select {
+			case r := <-outCh:
+				records = append(records, r)
+				continue
+			case err := <-errCh:
+				assert.EqualError(t, err, "error parsing line 2 of file file.jsonl: invalid character 'o' in literal null (expecting 'u')")
+			}
+			break
+		}
+		_, ok := <-outCh
+		assert.False(t, ok)
+		assert.Equal(t, []record{{"info", "one"}}, records)
+	})
+
+	t.Run("skip malformed", func(t *testing.T) {
+		c := newClient(content)
+
+		outCh, errCh := ReadJSONLines[record](ctx, c, "bucket", "file.jsonl", 64*1024, 10*1024*1024, true)
+
+		var records []record
+		var errs []error
+		for done := false; !done; {
+			select {
+			case r, ok := <-outCh:
+				if !ok {
+					done = true
+					break
+				}
+				records = append(records, r)
+			case err, ok := <-errCh:
+				if ok {
+					errs = append(errs, err)
+				}
+			}
+		}
+
+		assert.Equal(t, []record{{"info", "one"}, {"warn", "two"}}, records)
+		assert.Equal(t, 1, len(errs))
+	})
+
+	t.Run("line numbers count skipped long lines", func(t *testing.T) {
+		c := newClient("{\"level\":\"info\",\"msg\":\"" + strings.Repeat("x", 64) + "\"}\nnot json\n")
+		c.opts.SkipLongLines = true
+
+		outCh, errCh := ReadJSONLines[record](ctx, c, "bucket", "file.jsonl", 16, 32, false)
+
+		var errs []error
+		for outCh != nil || errCh != nil {
+			select {
+			case _, ok := <-outCh:
+				if !ok {
+					outCh = nil
+				}
+			case err, ok := <-errCh:
+				if !ok {
+					errCh = nil
+					continue
+				}
+				errs = append(errs, err)
+			}
+		}
+
+		assert.Equal(t, 1, len(errs))
+		assert.EqualError(t, errs[0], "error parsing line 2 of file file.jsonl: invalid character 'o' in literal null (expecting 'u')")
+	})
+}
@@ -0,0 +1,295 @@
+package s3client
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+
+	"github.com/calyptia/go-s3-client/ifaces"
+)
+
+// MemoryClient is a Client serving objects held in memory instead of stored on S3, for
+// testing code using a Client without mocking the AWS SDK. It is a DefaultClient backed by
+// an in-memory implementation of the S3 operations it needs to list, read, write, copy and
+// delete objects, so patterns are matched and objects decoded as they are by New's clients.
+// Objects are shared by all the buckets. Other operations, such as multipart uploads or
+// tagging, are not supported and fail with an error wrapping ErrNotImplemented.
+type MemoryClient struct {
+	*DefaultClient
+	store *memoryStore
+}
+
+// NewMemoryClient returns a MemoryClient holding a copy of files, by object key, configured
+// with the given options. Options configuring how S3 is reached, such as credentials, have no effect.
+func NewMemoryClient(logger Logger, files map[string][]byte, opts ...ClientOptsFunc) (*MemoryClient, error) {
+	clientOpts := ClientOpts{Logger: logger}
+	for _, opt := range opts {
+		if err := opt(&clientOpts); err != nil {
+			return nil, err
+		}
+	}
+	if err := clientOpts.validate(); err != nil {
+		return nil, fmt.Errorf("invalid client options: %w", err)
+	}
+
+	store := &memoryStore{objects: make(map[string]memoryObject, len(files))}
+	for key, data := range files {
+		store.put(key, bytes.Clone(data))
+	}
+
+	svc := &ifaces.ClientMock{
+		GetObjectFunc:     store.getObject,
+		HeadObjectFunc:    store.headObject,
+		ListObjectsV2Func: store.listObjectsV2,
+		PutObjectFunc:     store.putObject,
+		CopyObjectFunc:    store.copyObject,
+		DeleteObjectFunc:  store.deleteObject,
+	}
+	notImplemented(svc)
+	return &MemoryClient{
+		DefaultClient: &DefaultClient{Svc: svc, Logger: clientOpts.logger(), opts: clientOpts},
+		store:         store,
+	}, nil
+}
+
+// notImplemented sets the operations of svc left unset to fail with a NotImplemented API error,
+// which the client maps onto ErrNotImplemented, rather than panic as the mock does.
+func notImplemented(svc *ifaces.ClientMock) {
+	v := reflect.ValueOf(svc).Elem()
+	for i := range v.NumField() {
+		field := v.Field(i)
+		if field.Kind() != reflect.Func || !field.IsNil() {
+			continue
+		}
+
+		op := strings.TrimSuffix(v.Type().Field(i).Name, "Func")
+		out := field.Type().Out(0)
+		err := reflect.ValueOf(error(&smithy.GenericAPIError{
+			Code:    "NotImplemented",
+			Message: op + " is not implemented by MemoryClient",
+		}))
+		field.Set(reflect.MakeFunc(field.Type(), func([]reflect.Value) []reflect.Value {
+			return []reflect.Value{reflect.Zero(out), err}
+		}))
+	}
+}
+
+// Files returns a copy of the objects held by the client, by key, including those written through it.
+func (c *MemoryClient) Files() map[string][]byte {
+	c.store.mu.RLock()
+	defer c.store.mu.RUnlock()
+
+	files := make(map[string][]byte, len(c.store.objects))
+	for key, obj := range c.store.objects {
+		files[key] = bytes.Clone(obj.data)
+	}
+	return files
+}
+
+// memoryStore holds the objects of a MemoryClient and implements the S3 operations on them.
+type memoryStore struct {
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+}
+
+type memoryObject struct {
+	data         []byte
+	etag         string
+	lastModified time.Time
+}
+
+func (s *memoryStore) put(key string, data []byte) {
+	sum := md5.Sum(data)
+	s.objects[key] = memoryObject{
+		data:         data,
+		etag:         strconv.Quote(hex.EncodeToString(sum[:])),
+		lastModified: time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+func (s *memoryStore) get(key string) (memoryObject, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	obj, ok := s.objects[key]
+	return obj, ok
+}
+
+func (s *memoryStore) getObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	key := aws.ToString(params.Key)
+	obj, ok := s.get(key)
+	if !ok {
+		return nil, &types.NoSuchKey{Message: aws.String("The specified key does not exist.")}
+	}
+	if params.IfMatch != nil && *params.IfMatch != obj.etag {
+		return nil, &smithy.GenericAPIError{Code: "PreconditionFailed", Message: "At least one of the pre-conditions you specified did not hold"}
+	}
+	if params.IfNoneMatch != nil && *params.IfNoneMatch == obj.etag {
+		return nil, &smithy.GenericAPIError{Code: "NotModified", Message: "Not Modified"}
+	}
+
+	data := obj.data
+	if params.Range != nil {
+		start, end, err := parseRange(*params.Range, int64(len(data)))
+		if err != nil {
+			return nil, err
+		}
+		data = data[start : end+1]
+	}
+
+	return &s3.GetObjectOutput{
+		Body:          io.NopCloser(bytes.NewReader(data)),
+		ContentLength: aws.Int64(int64(len(data))),
+		ETag:          aws.String(obj.etag),
+		LastModified:  aws.Time(obj.lastModified),
+	}, nil
+}
+
+// parseRange returns the first and last offsets of the single range of an HTTP Range header,
+// such as "bytes=0-1023" or "bytes=1024-", within an object of the given size.
+func parseRange(header string, size int64) (int64, int64, error) {
+	invalid := &smithy.GenericAPIError{Code: "InvalidRange", Message: "The requested range is not satisfiable"}
+
+	first, last, ok := strings.Cut(strings.TrimPrefix(header, "bytes="), "-")
+	if !ok {
+		return 0, 0, invalid
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start >= size {
+		return 0, 0, invalid
+	}
+	end := size - 1
+	if last != "" {
+		if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+			return 0, 0, invalid
+		}
+	}
+	return start, min(end, size-1), nil
+}
+
+func (s *memoryStore) headObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	obj, ok := s.get(aws.ToString(params.Key))
+	if !ok {
+		return nil, &types.NotFound{Message: aws.String("Not Found")}
+	}
+	return &s3.HeadObjectOutput{
+		ContentLength: aws.Int64(int64(len(obj.data))),
+		ETag:          aws.String(obj.etag),
+		LastModified:  aws.Time(obj.lastModified),
+	}, nil
+}
+
+// listObjectsV2 lists the keys in lexicographical order, a thousand at most per page
+// as S3 does, the continuation token being the last key of the previous page.
+func (s *memoryStore) listObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	prefix, delimiter := aws.ToString(params.Prefix), aws.ToString(params.Delimiter)
+	after := aws.ToString(params.StartAfter)
+	if params.ContinuationToken != nil {
+		after = *params.ContinuationToken
+	}
+	maxKeys := maxListPageSize
+	if params.MaxKeys != nil && *params.MaxKeys > 0 && *params.MaxKeys < maxListPageSize {
+		maxKeys = int(*params.MaxKeys)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]string, 0, len(s.objects))
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) && key > after {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	out := &s3.ListObjectsV2Output{Prefix: params.Prefix, Delimiter: params.Delimiter}
+	seen := make(map[string]bool)
+	count := 0
+	for _, key := range keys {
+		if count == maxKeys {
+			out.IsTruncated = aws.Bool(true)
+			out.NextContinuationToken = aws.String(after)
+			break
+		}
+
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				common := key[:len(prefix)+i+len(delimiter)]
+				after = key
+				if !seen[common] {
+					seen[common] = true
+					out.CommonPrefixes = append(out.CommonPrefixes, types.CommonPrefix{Prefix: aws.String(common)})
+					count++
+				}
+				continue
+			}
+		}
+
+		obj := s.objects[key]
+		out.Contents = append(out.Contents, types.Object{
+			Key:          aws.String(key),
+			Size:         aws.Int64(int64(len(obj.data))),
+			ETag:         aws.String(obj.etag),
+			LastModified: aws.Time(obj.lastModified),
+		})
+		after = key
+		count++
+	}
+	out.KeyCount = aws.Int32(int32(count))
+	return out, nil
+}
+
+func (s *memoryStore) putObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	var data []byte
+	if params.Body != nil {
+		var err error
+		if data, err = io.ReadAll(params.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.put(aws.ToString(params.Key), data)
+	return &s3.PutObjectOutput{ETag: aws.String(s.objects[aws.ToString(params.Key)].etag)}, nil
+}
+
+func (s *memoryStore) copyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	source, err := url.PathUnescape(aws.ToString(params.CopySource))
+	if err != nil {
+		return nil, err
+	}
+	// the source is the escaped key prefixed with its bucket.
+	_, key, _ := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.objects[key]
+	if !ok {
+		return nil, &types.NoSuchKey{Message: aws.String("The specified key does not exist.")}
+	}
+	s.put(aws.ToString(params.Key), obj.data)
+	return &s3.CopyObjectOutput{}, nil
+}
+
+func (s *memoryStore) deleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, aws.ToString(params.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
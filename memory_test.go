@@ -0,0 +1,154 @@
+package s3client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestMemoryClient(t *testing.T) {
+	ctx := context.TODO()
+
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	_, _ = gw.Write([]byte("compressed\nlines\n"))
+	assert.NoError(t, gw.Close())
+
+	files := map[string][]byte{
+		"logs/2024/01/app.log":    []byte("first\nsecond\n"),
+		"logs/2024/02/app.log.gz": gz.Bytes(),
+		"logs/2024/02/app.json":   []byte("{}"),
+		"other/app.log":           []byte("other\n"),
+	}
+	c, err := NewMemoryClient(NullLogger{}, files)
+	assert.NoError(t, err)
+
+	// the client holds a copy of the files.
+	files["other/app.log"][0] = 'O'
+
+	readLines := func(file string) ([]string, error) {
+		outCh, errCh := c.ReadFile(ctx, "bucket", file, 64*1024, 10*1024*1024)
+		var lines []string
+		var err error
+		for outCh != nil || errCh != nil {
+			select {
+			case line, ok := <-outCh:
+				if !ok {
+					outCh = nil
+					continue
+				}
+				lines = append(lines, line)
+			case e, ok := <-errCh:
+				if !ok {
+					errCh = nil
+					continue
+				}
+				err = e
+			}
+		}
+		return lines, err
+	}
+
+	t.Run("list", func(t *testing.T) {
+		got, err := c.ListFiles(ctx, "bucket", "logs/**/*.{log,gz}")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"logs/2024/01/app.log", "logs/2024/02/app.log.gz"}, got)
+
+		dirs, err := c.ListDirs(ctx, "bucket", "logs/2024/")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"logs/2024/01/", "logs/2024/02/"}, dirs)
+	})
+
+	t.Run("read", func(t *testing.T) {
+		lines, err := readLines("logs/2024/02/app.log.gz")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"compressed", "lines"}, lines)
+
+		lines, err = readLines("other/app.log")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"other"}, lines)
+
+		_, err = readLines("missing.log")
+		assert.IsError(t, err, ErrNoSuchKey)
+
+		err = c.DeleteFileIfMatch(ctx, "bucket", "missing.log", `"etag"`)
+		assert.IsError(t, err, ErrNoSuchKey)
+	})
+
+	t.Run("write", func(t *testing.T) {
+		assert.NoError(t, c.WriteFile(ctx, "bucket", "out/new.log", strings.NewReader("written\n")))
+		assert.NoError(t, c.CopyFile(ctx, "bucket", "out/new.log", "bucket", "out/copy.log"))
+		assert.NoError(t, c.MoveFile(ctx, "bucket", "logs/2024/02/app.json", "bucket", "out/app.json"))
+
+		got := c.Files()
+		assert.Equal(t, []byte("written\n"), got["out/new.log"])
+		assert.Equal(t, []byte("written\n"), got["out/copy.log"])
+		assert.Equal(t, []byte("{}"), got["out/app.json"])
+		_, ok := got["logs/2024/02/app.json"]
+		assert.False(t, ok)
+
+		lines, err := readLines("out/copy.log")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"written"}, lines)
+	})
+
+	t.Run("not implemented", func(t *testing.T) {
+		_, err := c.GetObjectTags(ctx, "bucket", "other/app.log")
+		assert.IsError(t, err, ErrNotImplemented)
+		assert.Contains(t, err.Error(), "GetObjectTagging is not implemented by MemoryClient")
+
+		_, err = c.ListFileVersions(ctx, "bucket", "other/app.log")
+		assert.IsError(t, err, ErrNotImplemented)
+	})
+
+	t.Run("pages", func(t *testing.T) {
+		many := make(map[string][]byte)
+		for i := range 2500 {
+			many[fmt.Sprintf("many/%04d.log", i)] = nil
+		}
+		c, err := NewMemoryClient(NullLogger{}, many)
+		assert.NoError(t, err)
+
+		got, err := c.ListFiles(ctx, "bucket", "many/*.log")
+		assert.NoError(t, err)
+		assert.Equal(t, 2500, len(got))
+		assert.Equal(t, "many/2499.log", got[2499])
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		_, err := NewMemoryClient(NullLogger{}, nil, WithStaticCredentials("access", ""))
+		assert.EqualError(t, err, "invalid client options: static credentials require both an access key and a secret key")
+	})
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		header     string
+		start, end int64
+		wantErr    bool
+	}{
+		{header: "bytes=0-3", start: 0, end: 3},
+		{header: "bytes=4-", start: 4, end: 9},
+		{header: "bytes=5-100", start: 5, end: 9},
+		{header: "bytes=10-", wantErr: true},
+		{header: "bytes=5-2", wantErr: true},
+		{header: "bytes", wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.header, func(t *testing.T) {
+			start, end, err := parseRange(tc.header, 10)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.start, start)
+			assert.Equal(t, tc.end, end)
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package s3client
+
+import (
+	"container/heap"
+	"context"
+	"math"
+)
+
+// mergeStream is a line stream from a single file taking part in a merge.
+type mergeStream struct {
+	out     <-chan string
+	errChan <-chan error
+	// line and key hold the stream's next line, the only one buffered per file.
+	line string
+	key  int64
+	// index is the stream's position within the list of files, breaking ties between equal keys.
+	index int
+}
+
+// next reads the next line of the stream, returning false once the stream is exhausted.
+// Lines for which extract returns false keep the key of the previous line of the stream.
+func (s *mergeStream) next(extract func(line []byte) (int64, bool)) (bool, error) {
+	for {
+		select {
+		case line, ok := <-s.out:
+			if !ok {
+				return false, nil
+			}
+			if key, ok := extract([]byte(line)); ok {
+				s.key = key
+			}
+			s.line = line
+			return true, nil
+		case err, ok := <-s.errChan:
+			if !ok {
+				// the error channel is only closed once the output channel is.
+				return false, nil
+			}
+			return false, err
+		}
+	}
+}
+
+// drain consumes the remainder of the stream so the goroutine producing it can finish.
+func (s *mergeStream) drain() {
+	drain(s.out, s.errChan)
+}
+
+// drain consumes the remainder of a stream until its error channel is closed.
+func drain[T any](out <-chan T, errChan <-chan error) {
+	for out != nil || errChan != nil {
+		select {
+		case _, ok := <-out:
+			if !ok {
+				out = nil
+			}
+		case _, ok := <-errChan:
+			if !ok {
+				errChan = nil
+			}
+		}
+	}
+}
+
+// mergeHeap orders streams by the key of their next line.
+type mergeHeap []*mergeStream
+
+func (h mergeHeap) Len() int { return len(h) }
+func (h mergeHeap) Less(i, j int) bool {
+	if h[i].key != h[j].key {
+		return h[i].key < h[j].key
+	}
+	return h[i].index < h[j].index
+}
+func (h mergeHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x any)   { *h = append(*h, x.(*mergeStream)) }
+func (h *mergeHeap) Pop() any {
+	old := *h
+	n := len(old)
+	s := old[n-1]
+	*h = old[:n-1]
+	return s
+}
+
+// ReadFilesMerged reads the specified files from the given S3 bucket concurrently and
+// sends their lines through a single channel ordered by the key extract returns for each
+// line, performing a k-way merge that assumes every file is already sorted by that key.
+// Lines for which extract returns false, such as continuation lines, keep the key of the
+// line before them in the same file. Ties are broken by the order of files.
+// Only one line per file is buffered at any time, so memory stays bounded regardless of
+// the size of the files. Reading stops at the first error, which is sent through the
+// error channel, and the reads of the other files are canceled.
+func (c *DefaultClient) ReadFilesMerged(ctx context.Context, bucket string, files []string, initialBufferSize int, maxBufferSize int, extract func(line []byte) (int64, bool)) (<-chan string, <-chan error) {
+	out := make(chan string)
+	errChan := make(chan error)
+
+	go func() {
+		defer close(errChan)
+		defer close(out)
+
+		// the streams still being read are canceled as soon as the merge ends.
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		streams := make([]*mergeStream, 0, len(files))
+		for i, file := range files {
+			fileOut, fileErrChan := c.ReadFile(ctx, bucket, file, initialBufferSize, maxBufferSize)
+			streams = append(streams, &mergeStream{out: fileOut, errChan: fileErrChan, key: math.MinInt64, index: i})
+		}
+
+		fail := func(err error) {
+			cancel()
+			for _, s := range streams {
+				go s.drain()
+			}
+			errChan <- err
+		}
+
+		h := make(mergeHeap, 0, len(streams))
+		for _, s := range streams {
+			ok, err := s.next(extract)
+			if err != nil {
+				fail(err)
+				return
+			}
+			if ok {
+				h = append(h, s)
+			}
+		}
+		heap.Init(&h)
+
+		for h.Len() > 0 {
+			s := h[0]
+			out <- s.line
+
+			ok, err := s.next(extract)
+			if err != nil {
+				fail(err)
+				return
+			}
+			if ok {
+				heap.Fix(&h, 0)
+			} else {
+				heap.Pop(&h)
+			}
+		}
+	}()
+
+	return observeStream(c, "ReadFilesMerged", out, errChan)
+}
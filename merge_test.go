@@ -0,0 +1,156 @@
+package s3client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/calyptia/go-s3-client/ifaces"
+)
+
+func TestDefaultClient_ReadFilesMerged(t *testing.T) {
+	ctx := context.TODO()
+
+	// extractKey parses the leading timestamp of lines shaped as "<ts> <message>".
+	extractKey := func(line []byte) (int64, bool) {
+		ts, _, ok := strings.Cut(string(line), " ")
+		if !ok {
+			return 0, false
+		}
+		key, err := strconv.ParseInt(ts, 10, 64)
+		return key, err == nil
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		contents := map[string]string{
+			"one.log": "1 a\n4 b\n  continuation of b\n6 c",
+			"two.log": "2 d\n3 e\n4 f\n9 g",
+		}
+		client := ifaces.ClientMock{
+			GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				return &s3.GetObjectOutput{
+					Body: io.NopCloser(strings.NewReader(contents[*params.Key])),
+				}, nil
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		outCh, errCh := c.ReadFilesMerged(ctx, "bucket", []string{"one.log", "two.log"}, 64*1024, 10*1024*1024, extractKey)
+
+		var lines []string
+		for line := range outCh {
+			lines = append(lines, line)
+		}
+		if err := <-errCh; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		assert.Equal(t, []string{
+			"1 a",
+			"2 d",
+			"3 e",
+			"4 b",
+			"  continuation of b",
+			"4 f",
+			"6 c",
+			"9 g",
+		}, lines)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		client := ifaces.ClientMock{
+			GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				if *params.Key == "two.log" {
+					return nil, fmt.Errorf("cannot get object")
+				}
+				return &s3.GetObjectOutput{
+					Body: io.NopCloser(strings.NewReader("1 a\n2 b")),
+				}, nil
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		outCh, errCh := c.ReadFilesMerged(ctx, "bucket", []string{"one.log", "two.log"}, 64*1024, 10*1024*1024, extractKey)
+		for {
+			select {
+			case <-outCh:
+				continue
+			case err := <-errCh:
+				assert.EqualError(t, err, "cannot get object")
+			}
+			break
+		}
+	})
+
+	t.Run("error cancels other files", func(t *testing.T) {
+		canceled := make(chan struct{})
+		client := ifaces.ClientMock{
+			GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				if *params.Key == "two.log" {
+					return nil, fmt.Errorf("cannot get object")
+				}
+				// one.log never ends unless its read is canceled.
+				return &s3.GetObjectOutput{
+					Body: io.NopCloser(&ctxReader{ctx: ctx, canceled: canceled}),
+				}, nil
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		outCh, errCh := c.ReadFilesMerged(ctx, "bucket", []string{"one.log", "two.log"}, 64*1024, 10*1024*1024, extractKey)
+		for {
+			select {
+			case <-outCh:
+				continue
+			case err := <-errCh:
+				assert.EqualError(t, err, "cannot get object")
+			}
+			break
+		}
+
+		select {
+		case <-canceled:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the read of one.log was not canceled")
+		}
+	})
+}
+
+// ctxReader is an endless stream of lines, failing once its context is done.
+type ctxReader struct {
+	ctx      context.Context
+	canceled chan struct{}
+}
+
+func (r *ctxReader) Read(p []byte) (int, error) {
+	select {
+	case <-r.ctx.Done():
+		select {
+		case <-r.canceled:
+		default:
+			close(r.canceled)
+		}
+		return 0, r.ctx.Err()
+	default:
+		return copy(p, "1 a\n"), nil
+	}
+}
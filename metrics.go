@@ -0,0 +1,69 @@
+package s3client
+
+import "time"
+
+// MetricsRecorder is notified of every operation the client performs, to instrument it with
+// metrics such as Prometheus histograms.
+// Operations are named after the DefaultClient method performing them, e.g. "ListFiles", their
+// duration runs until their last result is returned, the whole stream for those streaming it,
+// and err is the error they failed with, or nil on success.
+// Methods built on top of others, such as MoveFile on CopyFile, also report the calls they make.
+// ObserveOp is called from the goroutine running the operation, so it must return quickly.
+type MetricsRecorder interface {
+	ObserveOp(op string, dur time.Duration, err error)
+}
+
+// observe reports the operation started at start, failed with *err if any, to the metrics
+// recorder, if one is configured. It is meant to be deferred with a pointer to the named error
+// result of the method.
+func (c *DefaultClient) observe(op string, start time.Time, err *error) {
+	if c.opts.MetricsRecorder == nil {
+		return
+	}
+	c.opts.MetricsRecorder.ObserveOp(op, time.Since(start), *err)
+}
+
+// observeStream reports the operation streaming its results through out and errChan to the
+// metrics recorder, if one is configured, once both channels are closed.
+// The returned channels forward the ones given, preserving the order in which the values and
+// errors are sent and closing them in the same order, so the channel lifecycle is unchanged.
+func observeStream[T any](c *DefaultClient, op string, out <-chan T, errChan <-chan error) (<-chan T, <-chan error) {
+	if c.opts.MetricsRecorder == nil {
+		return out, errChan
+	}
+
+	start := time.Now()
+	observedOut := make(chan T)
+	observedErrChan := make(chan error)
+
+	go func() {
+		var firstErr error
+		defer func() {
+			c.observe(op, start, &firstErr)
+			close(observedErrChan)
+		}()
+
+		for out != nil || errChan != nil {
+			select {
+			case v, ok := <-out:
+				if !ok {
+					out = nil
+					close(observedOut)
+					continue
+				}
+				observedOut <- v
+			case err, ok := <-errChan:
+				if !ok {
+					errChan = nil
+					continue
+				}
+				if firstErr == nil {
+					firstErr = err
+				}
+				observedErrChan <- err
+			}
+		}
+	}()
+
+	return observedOut, observedErrChan
+}
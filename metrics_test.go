@@ -0,0 +1,122 @@
+package s3client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go/aws"
+
+	"github.com/calyptia/go-s3-client/ifaces"
+)
+
+type observedOp struct {
+	op  string
+	err error
+}
+
+// opsRecorder is a MetricsRecorder keeping the operations it is notified of.
+type opsRecorder struct {
+	mu  sync.Mutex
+	ops []observedOp
+}
+
+func (r *opsRecorder) ObserveOp(op string, dur time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ops = append(r.ops, observedOp{op: op, err: err})
+}
+
+func (r *opsRecorder) observed() []observedOp {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]observedOp(nil), r.ops...)
+}
+
+func TestWithMetricsRecorder(t *testing.T) {
+	ctx := context.TODO()
+
+	client := ifaces.ClientMock{
+		ListObjectsV2Func: func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+			return &s3.ListObjectsV2Output{
+				Contents: []types.Object{{Key: aws.String("one.log")}},
+			}, nil
+		},
+		GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			if *params.Key == "missing.log" {
+				return nil, fmt.Errorf("cannot get object")
+			}
+			return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("a\nb"))}, nil
+		},
+		PutObjectFunc: func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			return nil, fmt.Errorf("cannot put object")
+		},
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		recorder := &opsRecorder{}
+		var opts ClientOpts
+		assert.NoError(t, WithMetricsRecorder(recorder)(&opts))
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+			opts:   opts,
+		}
+
+		_, err := c.ListFiles(ctx, "bucket", "*.log")
+		assert.NoError(t, err)
+
+		outCh, errCh := c.ReadFile(ctx, "bucket", "one.log", 64*1024, 10*1024*1024)
+		var lines []string
+		for line := range outCh {
+			lines = append(lines, line)
+		}
+		if err := <-errCh; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		assert.Equal(t, []string{"a", "b"}, lines)
+
+		assert.Equal(t, []observedOp{{op: "ListFiles"}, {op: "ReadFile"}}, recorder.observed())
+	})
+
+	t.Run("error", func(t *testing.T) {
+		recorder := &opsRecorder{}
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+			opts:   ClientOpts{MetricsRecorder: recorder},
+		}
+
+		err := c.WriteFile(ctx, "bucket", "file.log", strings.NewReader("data"))
+		assert.Error(t, err)
+
+		outCh, errCh := c.ReadFile(ctx, "bucket", "missing.log", 64*1024, 10*1024*1024)
+		for {
+			select {
+			case <-outCh:
+				continue
+			case err := <-errCh:
+				assert.EqualError(t, err, "cannot get object")
+			}
+			break
+		}
+		// the stream is reported once its error channel is closed.
+		_, ok := <-errCh
+		assert.False(t, ok)
+
+		ops := recorder.observed()
+		assert.Equal(t, 2, len(ops))
+		assert.Equal(t, "WriteFile", ops[0].op)
+		assert.EqualError(t, ops[0].err, "error writing file to s3: cannot put object")
+		assert.Equal(t, "ReadFile", ops[1].op)
+		assert.EqualError(t, ops[1].err, "cannot get object")
+	})
+}
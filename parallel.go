@@ -0,0 +1,220 @@
+package s3client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// isCompressed reports whether the object is compressed, either by its extension or by its
+// Content-Encoding, in which case its content can only be decoded from the beginning.
+// Every extension with a registered reader is compressed but tar, which is only an archive.
+func isCompressed(info ObjectInfo) bool {
+	ext := strings.ToLower(filepath.Ext(info.Key))
+	if _, ok := fileReaderFor(ext); ok && ext != ".tar" {
+		return true
+	}
+	return info.ContentEncoding != "" && info.ContentEncoding != "identity"
+}
+
+// part is the result of downloading a single range of an object.
+type part struct {
+	data []byte
+	err  error
+}
+
+// parallelBody is the reassembled stream of an object downloaded in ranges.
+// Closing it stops any download still in flight.
+type parallelBody struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+}
+
+func (b *parallelBody) Close() error {
+	b.cancel()
+	return b.PipeReader.Close()
+}
+
+// ReadFileParallel reads the specified file from the S3 bucket line by line, as ReadFile does,
+// downloading it with up to concurrency ranged requests of partSize bytes each.
+// Parts are written into the stream in order, and at most concurrency of them are held in
+// memory at a time. Compressed objects can't be decoded from the middle, so they are
+// downloaded with a single request instead.
+func (c *DefaultClient) ReadFileParallel(ctx context.Context, bucket string, file string, partSize int64, concurrency int, initialBufferSize int, maxBufferSize int) (<-chan string, <-chan error) {
+	out := make(chan string)
+	errChan := make(chan error)
+
+	go func() {
+		defer close(errChan)
+		defer close(out)
+
+		if partSize <= 0 {
+			errChan <- fmt.Errorf("invalid part size: %d", partSize)
+			return
+		}
+		if concurrency <= 0 {
+			errChan <- fmt.Errorf("invalid concurrency: %d", concurrency)
+			return
+		}
+
+		c.logger().Info("Started processing file: %s from bucket: %s", file, bucket)
+
+		head, err := c.headObject(ctx, bucket, file)
+		if err != nil {
+			errChan <- mapError(err)
+			return
+		}
+
+		info := newObjectInfoFromHeadObject(file, head)
+		if isCompressed(info) || info.Size <= partSize {
+			if isCompressed(info) {
+				c.logger().Warn("file: %s from bucket: %s is compressed, downloading it with a single request", file, bucket)
+			}
+
+			resp, err := c.getObject(ctx, c.getObjectInput(bucket, file))
+			if err != nil {
+				errChan <- mapError(err)
+				return
+			}
+
+			c.scanLines(bucket, newObjectInfoFromGetObject(file, resp), resp.Body, initialBufferSize, maxBufferSize, out, errChan)
+			return
+		}
+
+		c.scanLines(bucket, info, c.getObjectParts(ctx, bucket, info, partSize, concurrency), initialBufferSize, maxBufferSize, out, errChan)
+	}()
+
+	return observeStream(c, "ReadFileParallel", out, errChan)
+}
+
+// getObjectParts downloads the object in ranges of partSize bytes with up to concurrency
+// requests in flight, and returns the parts reassembled in order as a single stream.
+// Every range is requested with the object's ETag so that parts of different versions
+// of the object are never mixed.
+func (c *DefaultClient) getObjectParts(ctx context.Context, bucket string, info ObjectInfo, partSize int64, concurrency int) io.ReadCloser {
+	ctx, cancel := context.WithCancel(ctx)
+	pr, pw := io.Pipe()
+
+	count := int((info.Size + partSize - 1) / partSize)
+	parts := make([]chan part, count)
+	for i := range parts {
+		parts[i] = make(chan part, 1)
+	}
+
+	// sem bounds both the requests in flight and the parts waiting to be written.
+	sem := make(chan struct{}, concurrency)
+
+	go func() {
+		for i := range parts {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				parts[i] <- part{err: ctx.Err()}
+				continue
+			}
+
+			go func(i int) {
+				data, err := c.getObjectRange(ctx, bucket, info, int64(i)*partSize, partSize)
+				parts[i] <- part{data: data, err: err}
+			}(i)
+		}
+	}()
+
+	go func() {
+		defer cancel()
+
+		for i := range parts {
+			p := <-parts[i]
+			if p.err != nil {
+				pw.CloseWithError(p.err)
+				return
+			}
+			<-sem
+
+			if _, err := pw.Write(p.data); err != nil {
+				// the reader side has been closed.
+				return
+			}
+		}
+		pw.Close()
+	}()
+
+	return &parallelBody{PipeReader: pr, cancel: cancel}
+}
+
+// getObjectRange downloads length bytes of the object starting at offset.
+func (c *DefaultClient) getObjectRange(ctx context.Context, bucket string, info ObjectInfo, offset, length int64) ([]byte, error) {
+	end := min(offset+length, info.Size) - 1
+
+	input := c.getObjectInput(bucket, info.Key)
+	input.Range = aws.String(fmt.Sprintf("bytes=%d-%d", offset, end))
+	if info.ETag != "" {
+		input.IfMatch = aws.String(info.ETag)
+	}
+
+	resp, err := c.getObject(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("error reading range %d-%d of file from s3: %w", offset, end, mapError(err))
+	}
+	defer resp.Body.Close()
+
+	data := make([]byte, end-offset+1)
+	if _, err := io.ReadFull(resp.Body, data); err != nil {
+		return nil, fmt.Errorf("error reading range %d-%d of file from s3: %w", offset, end, err)
+	}
+	return data, nil
+}
+
+// OpenReaderAt returns a reader with random access to the specified file of the S3 bucket, such
+// as archive/zip needs to read an archive's central directory, without holding the object in
+// memory: every ReadAt, or Read after a Seek, downloads the range it reads with a GetObject request
+// of its own, so callers reading the object sequentially should buffer their reads. Ranges are read
+// as the object is stored, without decoding it, and requested with the ETag it has when opened,
+// so reads fail with an error wrapping ErrPreconditionFailed once the object is overwritten.
+// Every read is bound to ctx. Its Size is the object's size, e.g. to pass to zip.NewReader.
+func (c *DefaultClient) OpenReaderAt(ctx context.Context, bucket string, file string) (_ *io.SectionReader, err error) {
+	defer c.observe("OpenReaderAt", time.Now(), &err)
+
+	head, err := c.headObject(ctx, bucket, file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file from s3: %w", mapError(err))
+	}
+
+	info := newObjectInfoFromHeadObject(file, head)
+	return io.NewSectionReader(&objectReaderAt{ctx: ctx, c: c, bucket: bucket, info: info}, 0, info.Size), nil
+}
+
+// objectReaderAt reads the ranges of an object with ranged GetObject requests.
+type objectReaderAt struct {
+	ctx    context.Context
+	c      *DefaultClient
+	bucket string
+	info   ObjectInfo
+}
+
+func (r *objectReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("invalid offset: %d", off)
+	}
+	if off >= r.info.Size {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	data, err := r.c.getObjectRange(r.ctx, r.bucket, r.info, off, int64(len(p)))
+	if err != nil {
+		return 0, err
+	}
+	n := copy(p, data)
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
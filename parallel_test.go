@@ -0,0 +1,198 @@
+package s3client
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go/aws"
+
+	"github.com/calyptia/go-s3-client/ifaces"
+)
+
+// rangeMock returns a mock serving content, honoring the Range of GetObject requests.
+// Earlier ranges are served slower than later ones so that they complete out of order.
+func rangeMock(content []byte, inFlight, maxInFlight *int32) *ifaces.ClientMock {
+	return &ifaces.ClientMock{
+		HeadObjectFunc: func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			return &s3.HeadObjectOutput{
+				ContentLength: aws.Int64(int64(len(content))),
+				ETag:          aws.String(`"etag"`),
+			}, nil
+		},
+		GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			if params.Range == nil {
+				return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(content))}, nil
+			}
+
+			n := atomic.AddInt32(inFlight, 1)
+			defer atomic.AddInt32(inFlight, -1)
+			for {
+				m := atomic.LoadInt32(maxInFlight)
+				if n <= m || atomic.CompareAndSwapInt32(maxInFlight, m, n) {
+					break
+				}
+			}
+
+			var start, end int
+			if _, err := fmt.Sscanf(*params.Range, "bytes=%d-%d", &start, &end); err != nil {
+				return nil, err
+			}
+			time.Sleep(time.Duration(len(content)-start) * time.Microsecond)
+
+			return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(content[start : end+1]))}, nil
+		},
+	}
+}
+
+func TestDefaultClient_ReadFileParallel(t *testing.T) {
+	ctx := context.TODO()
+
+	var lines []string
+	for i := 0; i < 200; i++ {
+		lines = append(lines, fmt.Sprintf("line number %d", i))
+	}
+	content := []byte(strings.Join(lines, "\n"))
+
+	t.Run("ok", func(t *testing.T) {
+		var inFlight, maxInFlight int32
+		client := rangeMock(content, &inFlight, &maxInFlight)
+
+		c := DefaultClient{
+			Svc:    client,
+			Logger: NullLogger{},
+		}
+
+		outCh, errCh := c.ReadFileParallel(ctx, "bucket", "file.log", 100, 4, 64*1024, 10*1024*1024)
+
+		var got []string
+		for line := range outCh {
+			got = append(got, line)
+		}
+		if err := <-errCh; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		assert.Equal(t, lines, got)
+		assert.Equal(t, (len(content)+99)/100, len(client.GetObjectCalls()))
+		assert.True(t, atomic.LoadInt32(&maxInFlight) <= 4)
+		for _, call := range client.GetObjectCalls() {
+			assert.Equal(t, `"etag"`, aws.StringValue(call.Params.IfMatch))
+		}
+	})
+
+	t.Run("compressed", func(t *testing.T) {
+		var b bytes.Buffer
+		w := gzip.NewWriter(&b)
+		_, err := w.Write(content)
+		assert.NoError(t, err)
+		assert.NoError(t, w.Close())
+
+		var inFlight, maxInFlight int32
+		client := rangeMock(b.Bytes(), &inFlight, &maxInFlight)
+
+		c := DefaultClient{
+			Svc:    client,
+			Logger: NullLogger{},
+		}
+
+		outCh, errCh := c.ReadFileParallel(ctx, "bucket", "file.log.gz", 100, 4, 64*1024, 10*1024*1024)
+
+		var got []string
+		for line := range outCh {
+			got = append(got, line)
+		}
+		if err := <-errCh; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		assert.Equal(t, lines, got)
+		assert.Equal(t, 1, len(client.GetObjectCalls()))
+		assert.Zero(t, client.GetObjectCalls()[0].Params.Range)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		client := ifaces.ClientMock{
+			HeadObjectFunc: func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+				return &s3.HeadObjectOutput{ContentLength: aws.Int64(1000)}, nil
+			},
+			GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				if *params.Range == "bytes=500-599" {
+					return nil, fmt.Errorf("cannot get object")
+				}
+				return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(strings.Repeat("x", 100)))}, nil
+			},
+		}
+
+		c := DefaultClient{
+			Svc:    &client,
+			Logger: NullLogger{},
+		}
+
+		outCh, errCh := c.ReadFileParallel(ctx, "bucket", "file.log", 100, 2, 64*1024, 10*1024*1024)
+		for {
+			select {
+			case <-outCh:
+				continue
+			case err := <-errCh:
+				assert.EqualError(t, err, "error reading range 500-599 of file from s3: cannot get object")
+			}
+			break
+		}
+	})
+}
+
+func TestDefaultClient_OpenReaderAt(t *testing.T) {
+	ctx := context.TODO()
+
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for _, name := range []string{"a.log", "b.log"} {
+		w, err := zw.Create(name)
+		assert.NoError(t, err)
+		_, _ = w.Write([]byte(strings.Repeat(name+"\n", 1000)))
+	}
+	assert.NoError(t, zw.Close())
+
+	c, err := NewMemoryClient(NullLogger{}, map[string][]byte{"archive.zip": archive.Bytes()})
+	assert.NoError(t, err)
+
+	r, err := c.OpenReaderAt(ctx, "bucket", "archive.zip")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(archive.Len()), r.Size())
+
+	zr, err := zip.NewReader(r, r.Size())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(zr.File))
+	assert.Equal(t, "b.log", zr.File[1].Name)
+
+	f, err := zr.File[1].Open()
+	assert.NoError(t, err)
+	data, err := io.ReadAll(f)
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Repeat("b.log\n", 1000), string(data))
+
+	// reads past the end of the object are short.
+	buf := make([]byte, 8)
+	n, err := r.ReadAt(buf, r.Size()-4)
+	assert.Equal(t, 4, n)
+	assert.IsError(t, err, io.EOF)
+	assert.Equal(t, archive.Bytes()[archive.Len()-4:], buf[:n])
+
+	// the object can't change under the reader.
+	assert.NoError(t, c.WriteFile(ctx, "bucket", "archive.zip", strings.NewReader("overwritten")))
+	_, err = r.ReadAt(buf, 0)
+	assert.IsError(t, err, ErrPreconditionFailed)
+
+	_, err = c.OpenReaderAt(ctx, "bucket", "missing.zip")
+	assert.IsError(t, err, ErrNoSuchKey)
+}
@@ -0,0 +1,150 @@
+package s3client
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/pierrec/lz4/v4"
+)
+
+// fileReaders holds the reader factories GetFileReader selects by file extension,
+// starting with the built-in ones.
+var fileReaders = struct {
+	sync.RWMutex
+	m map[string]func(io.Reader) (io.ReadCloser, error)
+}{m: map[string]func(io.Reader) (io.ReadCloser, error){
+	".gz":     newGzipReader,
+	".gzip":   newGzipReader,
+	".sz":     newSnappyReader,
+	".snappy": newSnappyReader,
+	".lz4":    newLZ4Reader,
+	".tar":    newTarReader,
+}}
+
+// RegisterReader registers the factory GetFileReader, and so ReadFile and the other reads,
+// uses to decode files with the given extension, such as ".zst", replacing the built-in or
+// previously registered one, if any. The factory is given the object body and must not close it.
+// Registered formats are assumed to be compressed, so objects using them are never read in ranges.
+func RegisterReader(ext string, factory func(io.Reader) (io.ReadCloser, error)) error {
+	if !strings.HasPrefix(ext, ".") || len(ext) < 2 {
+		return fmt.Errorf("invalid file extension %q", ext)
+	}
+	if factory == nil {
+		return errors.New("reader factory must not be nil")
+	}
+
+	fileReaders.Lock()
+	defer fileReaders.Unlock()
+	fileReaders.m[strings.ToLower(ext)] = factory
+	return nil
+}
+
+// fileReaderFor returns the reader factory registered for the given lower-cased extension.
+func fileReaderFor(ext string) (func(io.Reader) (io.ReadCloser, error), bool) {
+	fileReaders.RLock()
+	defer fileReaders.RUnlock()
+	factory, ok := fileReaders.m[ext]
+	return factory, ok
+}
+
+func newGzipReader(r io.Reader) (io.ReadCloser, error) {
+	// peek into the beginning of the body instead of reading the entire
+	// body, so the rest of the object is streamed straight into the
+	// decompressor. Only the magic bytes are checked, as the full header
+	// can carry extra fields larger than any reasonable window.
+	br := newSniffReader(r)
+	if !isGzip(br) {
+		// See https://github.com/aws/aws-sdk-go/issues/1292
+		// The default HTTP transports that the AWS SDK uses will decompress objects transparently
+		// if the Content Encoding is gzip. Not everyone or everything properly sets the Content-Encoding
+		// header on their S3 objects, so we could be trying to process gzipped objects and not know it.
+		return io.NopCloser(br), nil
+	}
+
+	// the reader is left in multistream mode, the default, so objects other files were
+	// appended to, made of several gzip members, are read through to their last member.
+	gr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, err
+	}
+	return gr, nil
+}
+
+func newSnappyReader(r io.Reader) (io.ReadCloser, error) {
+	// snappy.NewReader decodes the streaming framing format, not raw snappy blocks.
+	return io.NopCloser(snappy.NewReader(r)), nil
+}
+
+func newLZ4Reader(r io.Reader) (io.ReadCloser, error) {
+	// as with gzip, objects that are not lz4 frames despite their extension
+	// are passed through as they are.
+	br := newSniffReader(r)
+	if !isLZ4(br) {
+		return io.NopCloser(br), nil
+	}
+	return io.NopCloser(lz4.NewReader(br)), nil
+}
+
+func newTarReader(r io.Reader) (io.ReadCloser, error) {
+	tr := io.NopCloser(tar.NewReader(r))
+	return tr, nil
+}
+
+// newDetectedTarReader returns a reader over the contents of the files of the tar archive read
+// through r if it is one, or over r as it is otherwise. Closing the returned reader closes r.
+func newDetectedTarReader(r io.ReadCloser) io.ReadCloser {
+	br := bufio.NewReaderSize(r, tarBlockSize)
+	if !isTar(br) {
+		return &layeredReader{ReadCloser: io.NopCloser(br), inner: r}
+	}
+	return &layeredReader{ReadCloser: io.NopCloser(&tarContentsReader{tr: tar.NewReader(br)}), inner: r}
+}
+
+// tarContentsReader reads the contents of the regular files of a tar archive one after the
+// other, ending each of them with a newline if it doesn't, so their lines are kept apart.
+type tarContentsReader struct {
+	tr *tar.Reader
+	// inFile is set while the contents of a file are being read.
+	inFile bool
+	// unterminated is set when the last byte read from a file isn't a newline.
+	unterminated bool
+}
+
+func (r *tarContentsReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	for {
+		if r.inFile {
+			n, err := r.tr.Read(p)
+			if n > 0 {
+				r.unterminated = p[n-1] != '\n'
+			}
+			if !errors.Is(err, io.EOF) {
+				return n, err
+			}
+			r.inFile = false
+			if n > 0 {
+				return n, nil
+			}
+		}
+		if r.unterminated {
+			r.unterminated = false
+			p[0] = '\n'
+			return 1, nil
+		}
+
+		hdr, err := r.tr.Next()
+		if err != nil {
+			return 0, err
+		}
+		r.inFile = hdr.Typeflag == tar.TypeReg
+	}
+}
@@ -0,0 +1,200 @@
+package s3client
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go/aws"
+
+	"github.com/calyptia/go-s3-client/ifaces"
+)
+
+// rot13Reader decodes the made-up ".rot13" format used to test RegisterReader.
+type rot13Reader struct {
+	r io.Reader
+}
+
+func (r rot13Reader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	for i, b := range p[:n] {
+		switch {
+		case b >= 'a' && b <= 'z':
+			p[i] = 'a' + (b-'a'+13)%26
+		case b >= 'A' && b <= 'Z':
+			p[i] = 'A' + (b-'A'+13)%26
+		}
+	}
+	return n, err
+}
+
+func TestRegisterReader(t *testing.T) {
+	t.Cleanup(func() {
+		fileReaders.Lock()
+		defer fileReaders.Unlock()
+		delete(fileReaders.m, ".rot13")
+	})
+
+	assert.NoError(t, RegisterReader(".ROT13", func(r io.Reader) (io.ReadCloser, error) {
+		return io.NopCloser(rot13Reader{r: r}), nil
+	}))
+	assert.EqualError(t, RegisterReader("rot13", nil), `invalid file extension "rot13"`)
+	assert.EqualError(t, RegisterReader(".rot13", nil), "reader factory must not be nil")
+
+	client := ifaces.ClientMock{
+		GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader([]byte("uryyb\njbeyq")))}, nil
+		},
+	}
+
+	c := DefaultClient{
+		Svc:    &client,
+		Logger: NullLogger{},
+	}
+
+	outCh, errCh := c.ReadFile(context.TODO(), "bucket", "file.rot13", 64*1024, 10*1024*1024)
+
+	var lines []string
+	for line := range outCh {
+		lines = append(lines, line)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assert.Equal(t, []string{"hello", "world"}, lines)
+	assert.True(t, isCompressed(ObjectInfo{Key: "file.rot13"}))
+	assert.False(t, isCompressed(ObjectInfo{Key: "file.tar"}))
+}
+
+func TestDefaultClient_ReadFile_GzipMultistream(t *testing.T) {
+	// appending to a gzip file produces an object made of several gzip members,
+	// each of them has to be read and not just the first one.
+	member := func(lines ...string) []byte {
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		for _, line := range lines {
+			_, _ = gw.Write([]byte(line + "\n"))
+		}
+		assert.NoError(t, gw.Close())
+		return buf.Bytes()
+	}
+
+	// the last member is compressed from more than the read buffers hold at once.
+	long := make([]string, 20000)
+	for i := range long {
+		long[i] = fmt.Sprintf("line %d", i)
+	}
+	body := bytes.Join([][]byte{member("first"), member("second", "third"), member(long...)}, nil)
+	want := append([]string{"first", "second", "third"}, long...)
+
+	for _, contentEncoding := range []string{"", "gzip"} {
+		t.Run(fmt.Sprintf("content encoding %q", contentEncoding), func(t *testing.T) {
+			client := ifaces.ClientMock{
+				GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+					out := &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(body))}
+					if contentEncoding != "" {
+						out.ContentEncoding = aws.String(contentEncoding)
+					}
+					return out, nil
+				},
+			}
+
+			c := DefaultClient{
+				Svc:    &client,
+				Logger: NullLogger{},
+			}
+
+			outCh, errCh := c.ReadFile(context.TODO(), "bucket", "file.log.gz", 64*1024, 10*1024*1024)
+
+			var lines []string
+			for line := range outCh {
+				lines = append(lines, line)
+			}
+			if err := <-errCh; err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assert.Equal(t, want, lines)
+		})
+	}
+}
+
+func TestDefaultClient_ReadFile_DetectGzippedTar(t *testing.T) {
+	gz := func(data []byte) []byte {
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		_, _ = gw.Write(data)
+		assert.NoError(t, gw.Close())
+		return buf.Bytes()
+	}
+
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	assert.NoError(t, tw.WriteHeader(&tar.Header{Name: "logs/", Mode: 0o755, Typeflag: tar.TypeDir}))
+	for _, file := range []struct{ name, data string }{
+		{"logs/a.log", "a 1\na 2\n"},
+		{"logs/b.log", "b 1\nb 2"},
+		{"logs/c.log", "c 1\n"},
+	} {
+		assert.NoError(t, tw.WriteHeader(&tar.Header{Name: file.name, Mode: 0o644, Size: int64(len(file.data)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(file.data))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tw.Close())
+
+	files := map[string][]byte{
+		"archive.gz":     gz(archive.Bytes()),
+		"archive.tar.gz": gz(archive.Bytes()),
+		"plain.gz":       gz([]byte("x\ny\n")),
+	}
+
+	read := func(c *MemoryClient, key string) []string {
+		outCh, errCh := c.ReadFile(context.TODO(), "bucket", key, 64*1024, 10*1024*1024)
+		var lines []string
+		for outCh != nil || errCh != nil {
+			select {
+			case line, ok := <-outCh:
+				if !ok {
+					outCh = nil
+					continue
+				}
+				lines = append(lines, line)
+			case err, ok := <-errCh:
+				if !ok {
+					errCh = nil
+					continue
+				}
+				assert.NoError(t, err)
+			}
+		}
+		return lines
+	}
+
+	c, err := NewMemoryClient(NullLogger{}, files, WithDetectGzippedTar(true))
+	assert.NoError(t, err)
+	want := []string{"a 1", "a 2", "b 1", "b 2", "c 1"}
+	assert.Equal(t, want, read(c, "archive.gz"))
+	assert.Equal(t, want, read(c, "archive.tar.gz"))
+	assert.Equal(t, []string{"x", "y"}, read(c, "plain.gz"))
+
+	// archives read through ReadArchiveMember are walked as they are.
+	outCh, errCh := c.ReadArchiveMember(context.TODO(), "bucket", "archive.tar.gz", "logs/b.log", 64*1024, 10*1024*1024)
+	var lines []string
+	for line := range outCh {
+		lines = append(lines, line)
+	}
+	assert.NoError(t, <-errCh)
+	assert.Equal(t, []string{"b 1", "b 2"}, lines)
+
+	// without the option, the tar framing is read as it is.
+	c, err = NewMemoryClient(NullLogger{}, files)
+	assert.NoError(t, err)
+	assert.NotEqual(t, want, read(c, "archive.gz"))
+	assert.Equal(t, []string{"x", "y"}, read(c, "plain.gz"))
+}
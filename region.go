@@ -0,0 +1,91 @@
+package s3client
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// bucketRegionHeader is the response header S3 uses to report the region of a bucket.
+const bucketRegionHeader = "X-Amz-Bucket-Region"
+
+// bucketRegionFromError returns the actual region of the bucket when err reports
+// that the request was sent to the wrong region.
+func bucketRegionFromError(err error) (string, bool) {
+	var respErr interface {
+		HTTPResponse() *smithyhttp.Response
+	}
+	if !errors.As(err, &respErr) {
+		return "", false
+	}
+
+	resp := respErr.HTTPResponse()
+	if resp == nil || resp.Response == nil {
+		return "", false
+	}
+
+	region := resp.Header.Get(bucketRegionHeader)
+	if region == "" {
+		return "", false
+	}
+
+	if resp.StatusCode == http.StatusMovedPermanently {
+		return region, true
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "PermanentRedirect", "AuthorizationHeaderMalformed":
+			return region, true
+		}
+	}
+
+	return "", false
+}
+
+// withRegion returns an S3 option function that sends the request to the given region.
+func withRegion(region string) func(*s3.Options) {
+	return func(o *s3.Options) {
+		o.Region = region
+		// the default resolver pins the configured region, so it needs to be replaced as well.
+		if resolver, ok := o.EndpointResolverV2.(*resolverV2); ok {
+			o.EndpointResolverV2 = &resolverV2{
+				BaseEndpoint:  aws.ToString(o.BaseEndpoint),
+				Region:        region,
+				SigningRegion: resolver.SigningRegion,
+			}
+		}
+	}
+}
+
+// optFns returns the per-request S3 option functions to use for requests to bucket.
+func (c *DefaultClient) optFns(bucket string) []func(*s3.Options) {
+	if region, ok := c.regions.Load(bucket); ok {
+		return []func(*s3.Options){withRegion(region.(string))}
+	}
+	return nil
+}
+
+// inBucketRegion runs call with the per-request options for bucket. When auto region is
+// enabled and the call fails because the bucket lives in a different region, the region
+// is remembered for the bucket and the call is retried once against it.
+func inBucketRegion[T any](c *DefaultClient, bucket string, call func(optFns ...func(*s3.Options)) (T, error)) (T, error) {
+	out, err := call(c.optFns(bucket)...)
+	if err == nil || !c.opts.AutoRegion {
+		return out, err
+	}
+
+	region, ok := bucketRegionFromError(err)
+	if !ok {
+		return out, err
+	}
+
+	c.logger().Warn("bucket: %s is in region: %s, retrying the request there", bucket, region)
+	c.regions.Store(bucket, region)
+	return call(c.optFns(bucket)...)
+}
@@ -0,0 +1,174 @@
+package s3client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+
+	"github.com/calyptia/go-s3-client/ifaces"
+)
+
+// newRedirectError returns an error shaped like the one the SDK returns when a
+// request is sent to the wrong region.
+func newRedirectError(region string) error {
+	return &awshttp.ResponseError{
+		ResponseError: &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{
+				StatusCode: http.StatusMovedPermanently,
+				Header:     http.Header{bucketRegionHeader: []string{region}},
+			}},
+			Err: &smithy.GenericAPIError{Code: "PermanentRedirect"},
+		},
+	}
+}
+
+// regionOf returns the region the given S3 option functions send requests to.
+func regionOf(optFns []func(*s3.Options)) string {
+	o := s3.Options{Region: "us-east-1", EndpointResolverV2: &resolverV2{Region: "us-east-1"}}
+	for _, fn := range optFns {
+		fn(&o)
+	}
+	return o.Region
+}
+
+func TestBucketRegionFromError(t *testing.T) {
+	region, ok := bucketRegionFromError(fmt.Errorf("operation error: %w", newRedirectError("eu-west-1")))
+	assert.True(t, ok)
+	assert.Equal(t, "eu-west-1", region)
+
+	_, ok = bucketRegionFromError(&smithy.GenericAPIError{Code: "PermanentRedirect"})
+	assert.False(t, ok)
+
+	_, ok = bucketRegionFromError(fmt.Errorf("boom"))
+	assert.False(t, ok)
+}
+
+func TestDefaultClient_ReadFile_AutoRegion(t *testing.T) {
+	ctx := context.TODO()
+
+	newClient := func() *ifaces.ClientMock {
+		return &ifaces.ClientMock{
+			GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				if regionOf(optFns) != "eu-west-1" {
+					return nil, newRedirectError("eu-west-1")
+				}
+				return &s3.GetObjectOutput{
+					Body: io.NopCloser(strings.NewReader("line")),
+				}, nil
+			},
+		}
+	}
+
+	readAll := func(c *DefaultClient) ([]string, error) {
+		outCh, errCh := c.ReadFile(ctx, "bucket", "file.txt", 64*1024, 10*1024*1024)
+		var lines []string
+		for {
+			select {
+			case line, ok := <-outCh:
+				if !ok {
+					return lines, nil
+				}
+				lines = append(lines, line)
+			case err := <-errCh:
+				return lines, err
+			}
+		}
+	}
+
+	t.Run("enabled", func(t *testing.T) {
+		client := newClient()
+		c := &DefaultClient{
+			Svc:    client,
+			Logger: NullLogger{},
+			opts:   ClientOpts{AutoRegion: true},
+		}
+
+		lines, err := readAll(c)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"line"}, lines)
+		assert.Equal(t, 2, len(client.GetObjectCalls()))
+
+		// the resolved region is cached for the bucket.
+		lines, err = readAll(c)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"line"}, lines)
+		assert.Equal(t, 3, len(client.GetObjectCalls()))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		client := newClient()
+		c := &DefaultClient{
+			Svc:    client,
+			Logger: NullLogger{},
+		}
+
+		_, err := readAll(c)
+		assert.Error(t, err)
+		assert.Equal(t, 1, len(client.GetObjectCalls()))
+	})
+}
+
+func TestDefaultClient_ListFiles_AutoRegion(t *testing.T) {
+	ctx := context.TODO()
+
+	newClient := func() *ifaces.ClientMock {
+		return &ifaces.ClientMock{
+			ListObjectsV2Func: func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+				if regionOf(optFns) != "eu-west-1" {
+					return nil, newRedirectError("eu-west-1")
+				}
+				return &s3.ListObjectsV2Output{
+					Contents: []types.Object{{Key: aws.String("logs/app.log")}},
+				}, nil
+			},
+		}
+	}
+
+	t.Run("enabled", func(t *testing.T) {
+		client := newClient()
+		c := &DefaultClient{
+			Svc:    client,
+			Logger: NullLogger{},
+			opts:   ClientOpts{AutoRegion: true},
+		}
+
+		files, err := c.ListFiles(ctx, "bucket", "logs/*.log")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"logs/app.log"}, files)
+		assert.Equal(t, 2, len(client.ListObjectsV2Calls()))
+
+		// the resolved region is cached for the bucket, and used by the other requests to it.
+		dirs, infos, err := c.ListDir(ctx, "bucket", "logs/")
+		assert.NoError(t, err)
+		assert.Zero(t, dirs)
+		assert.Equal(t, 1, len(infos))
+		assert.Equal(t, 3, len(client.ListObjectsV2Calls()))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		client := newClient()
+		c := &DefaultClient{
+			Svc:    client,
+			Logger: NullLogger{},
+		}
+
+		_, err := c.ListFiles(ctx, "bucket", "logs/*.log")
+		assert.Error(t, err)
+		assert.Equal(t, 1, len(client.ListObjectsV2Calls()))
+
+		region, ok := BucketRegion(err)
+		assert.True(t, ok)
+		assert.Equal(t, "eu-west-1", region)
+	})
+}
@@ -0,0 +1,119 @@
+package s3client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+var (
+	// resumeBaseDelay is the delay before the first resume of a body read, doubled on every
+	// following one.
+	resumeBaseDelay = 100 * time.Millisecond
+	// resumeMaxDelay caps the delay between resumes of a body read.
+	resumeMaxDelay = 10 * time.Second
+)
+
+// resumableBody is the body of an object that, when a read fails, requests the object
+// again from the last byte read and carries on reading from there.
+type resumableBody struct {
+	ctx   context.Context
+	c     *DefaultClient
+	input *s3.GetObjectInput
+	// etag pins the resumed requests to the version of the object first read.
+	etag string
+
+	body    io.ReadCloser
+	offset  int64
+	resumes int
+	// err is the read error waiting to be recovered from.
+	err error
+}
+
+func (b *resumableBody) Read(p []byte) (int, error) {
+	for {
+		if b.err == nil {
+			n, err := b.body.Read(p)
+			b.offset += int64(n)
+			if err == nil || errors.Is(err, io.EOF) || b.ctx.Err() != nil {
+				return n, err
+			}
+
+			b.err = err
+			if n > 0 {
+				return n, nil
+			}
+		}
+
+		if err := b.resume(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// resumeDelay returns the delay before the resume following the given number of resumes,
+// checked against resumeMaxDelay before shifting so that the doubling never overflows.
+func resumeDelay(resumes int) time.Duration {
+	if resumes >= 63 || resumeBaseDelay > resumeMaxDelay>>resumes {
+		return resumeMaxDelay
+	}
+	return resumeBaseDelay << resumes
+}
+
+// resume requests the rest of the object after backing off, returning the read error it
+// was recovering from once the resumes are exhausted.
+func (b *resumableBody) resume() error {
+	file := aws.ToString(b.input.Key)
+	bucket := aws.ToString(b.input.Bucket)
+
+	for b.resumes < b.c.opts.MaxReadResumes {
+		delay := resumeDelay(b.resumes)
+		b.resumes++
+
+		b.c.logger().Warn("error reading file: %s from bucket: %s at offset: %d, resuming in %s (%d/%d): %v",
+			file, bucket, b.offset, delay, b.resumes, b.c.opts.MaxReadResumes, b.err)
+
+		select {
+		case <-time.After(delay):
+		case <-b.ctx.Done():
+			return b.ctx.Err()
+		}
+
+		_ = b.body.Close()
+		b.body = http.NoBody
+
+		input := *b.input
+		input.Range = aws.String(fmt.Sprintf("bytes=%d-", b.offset))
+		if b.etag != "" {
+			input.IfMatch = aws.String(b.etag)
+		}
+
+		resp, err := b.c.getObject(b.ctx, &input)
+		if err != nil {
+			// errors returned by S3, such as the object having changed, won't go away.
+			var apiErr smithy.APIError
+			if errors.As(err, &apiErr) {
+				return fmt.Errorf("error resuming file read from s3: %w", mapError(err))
+			}
+			b.err = err
+			continue
+		}
+
+		b.body = resp.Body
+		b.err = nil
+		return nil
+	}
+
+	return fmt.Errorf("error reading file from s3 after %d resume(s): %w", b.resumes, b.err)
+}
+
+func (b *resumableBody) Close() error {
+	return b.body.Close()
+}
@@ -0,0 +1,132 @@
+package s3client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go/aws"
+
+	"github.com/calyptia/go-s3-client/ifaces"
+)
+
+// failingReader reads from r, failing with a connection reset once n bytes have been read.
+type failingReader struct {
+	r io.Reader
+	n int
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	if f.n <= 0 {
+		return 0, errors.New("connection reset by peer")
+	}
+	if len(p) > f.n {
+		p = p[:f.n]
+	}
+	n, err := f.r.Read(p)
+	f.n -= n
+	return n, err
+}
+
+func TestDefaultClient_ReadFile_Resume(t *testing.T) {
+	ctx := context.TODO()
+
+	defer func(delay time.Duration) { resumeBaseDelay = delay }(resumeBaseDelay)
+	resumeBaseDelay = time.Millisecond
+
+	var lines []string
+	for i := 0; i < 100; i++ {
+		lines = append(lines, fmt.Sprintf("line number %d", i))
+	}
+	content := []byte(strings.Join(lines, "\n"))
+
+	// flakyMock serves content, failing every response after failAfter bytes.
+	flakyMock := func(failAfter int) *ifaces.ClientMock {
+		return &ifaces.ClientMock{
+			GetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				var start int
+				if params.Range != nil {
+					if _, err := fmt.Sscanf(*params.Range, "bytes=%d-", &start); err != nil {
+						return nil, err
+					}
+				}
+				return &s3.GetObjectOutput{
+					Body: io.NopCloser(&failingReader{r: bytes.NewReader(content[start:]), n: failAfter}),
+					ETag: aws.String(`"etag"`),
+				}, nil
+			},
+		}
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		client := flakyMock(400)
+
+		c := DefaultClient{
+			Svc:    client,
+			Logger: NullLogger{},
+			opts: ClientOpts{
+				MaxReadResumes: 5,
+			},
+		}
+
+		outCh, errCh := c.ReadFile(ctx, "bucket", "file.log", 64*1024, 10*1024*1024)
+
+		var got []string
+		for line := range outCh {
+			got = append(got, line)
+		}
+		if err := <-errCh; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		assert.Equal(t, lines, got)
+
+		calls := client.GetObjectCalls()
+		assert.Equal(t, 4, len(calls))
+		assert.Zero(t, calls[0].Params.Range)
+		assert.Equal(t, "bytes=400-", aws.StringValue(calls[1].Params.Range))
+		assert.Equal(t, `"etag"`, aws.StringValue(calls[1].Params.IfMatch))
+	})
+
+	t.Run("exhausted", func(t *testing.T) {
+		client := flakyMock(100)
+
+		c := DefaultClient{
+			Svc:    client,
+			Logger: NullLogger{},
+			opts: ClientOpts{
+				MaxReadResumes: 2,
+			},
+		}
+
+		outCh, errCh := c.ReadFile(ctx, "bucket", "file.log", 64*1024, 10*1024*1024)
+		for {
+			select {
+			case <-outCh:
+				continue
+			case err := <-errCh:
+				assert.EqualError(t, err, "error reading file from s3 after 2 resume(s): connection reset by peer")
+			}
+			break
+		}
+		assert.Equal(t, 3, len(client.GetObjectCalls()))
+	})
+}
+
+func TestResumeDelay(t *testing.T) {
+	assert.Equal(t, 100*time.Millisecond, resumeDelay(0))
+	assert.Equal(t, 200*time.Millisecond, resumeDelay(1))
+	assert.Equal(t, 6400*time.Millisecond, resumeDelay(6))
+	assert.Equal(t, 10*time.Second, resumeDelay(7))
+	// shifting the base delay this far would overflow, wrapping to a negative or zero delay.
+	assert.Equal(t, 10*time.Second, resumeDelay(40))
+	assert.Equal(t, 10*time.Second, resumeDelay(64))
+	assert.Equal(t, 10*time.Second, resumeDelay(1000))
+}
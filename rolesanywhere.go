@@ -0,0 +1,202 @@
+package s3client
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+)
+
+// rolesAnywhereProvider retrieves the credentials of a role through IAM Roles Anywhere, by
+// creating a session signed with an X.509 certificate issued by a trust anchor of the account.
+// See https://docs.aws.amazon.com/rolesanywhere/latest/userguide/authentication-sign-process.html
+type rolesAnywhereProvider struct {
+	client         aws.HTTPClient
+	endpoint       string
+	region         string
+	trustAnchorARN string
+	profileARN     string
+	roleARN        string
+	cert           tls.Certificate
+	now            func() time.Time
+}
+
+// newRolesAnywhereProvider returns the provider of the credentials of the role set on the
+// options, creating sessions in the region of the trust anchor.
+func newRolesAnywhereProvider(client aws.HTTPClient, o *ClientOpts) *rolesAnywhereProvider {
+	// the ARN is checked by WithRolesAnywhere already.
+	anchor, _ := arn.Parse(o.RolesAnywhereTrustAnchorARN)
+	return &rolesAnywhereProvider{
+		client:         client,
+		endpoint:       fmt.Sprintf("https://rolesanywhere.%s.amazonaws.com", anchor.Region),
+		region:         anchor.Region,
+		trustAnchorARN: o.RolesAnywhereTrustAnchorARN,
+		profileARN:     o.RolesAnywhereProfileARN,
+		roleARN:        o.RolesAnywhereRoleARN,
+		cert:           *o.RolesAnywhereCertificate,
+		now:            time.Now,
+	}
+}
+
+// Retrieve creates a Roles Anywhere session and returns its credentials.
+func (p *rolesAnywhereProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	body, err := json.Marshal(struct {
+		ProfileARN     string `json:"profileArn"`
+		RoleARN        string `json:"roleArn"`
+		TrustAnchorARN string `json:"trustAnchorArn"`
+	}{p.profileARN, p.roleARN, p.trustAnchorARN})
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint+"/sessions", bytes.NewReader(body))
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := signRolesAnywhereRequest(req, body, p.cert, p.region, p.now()); err != nil {
+		return aws.Credentials{}, fmt.Errorf("error signing roles anywhere session request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return aws.Credentials{}, fmt.Errorf("error creating roles anywhere session: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return aws.Credentials{}, fmt.Errorf("error creating roles anywhere session: %w", err)
+	}
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return aws.Credentials{}, fmt.Errorf("error creating roles anywhere session: %s: %s", resp.Status, bytes.TrimSpace(data))
+	}
+
+	var session struct {
+		CredentialSet []struct {
+			Credentials struct {
+				AccessKeyID     string    `json:"accessKeyId"`
+				SecretAccessKey string    `json:"secretAccessKey"`
+				SessionToken    string    `json:"sessionToken"`
+				Expiration      time.Time `json:"expiration"`
+			} `json:"credentials"`
+		} `json:"credentialSet"`
+	}
+	if err := json.Unmarshal(data, &session); err != nil {
+		return aws.Credentials{}, fmt.Errorf("error parsing roles anywhere session: %w", err)
+	}
+	if len(session.CredentialSet) == 0 {
+		return aws.Credentials{}, errors.New("error parsing roles anywhere session: no credentials returned")
+	}
+
+	creds := session.CredentialSet[0].Credentials
+	return aws.Credentials{
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+		Source:          "RolesAnywhereProvider",
+		CanExpire:       true,
+		Expires:         creds.Expiration,
+	}, nil
+}
+
+// signRolesAnywhereRequest signs req, whose body is body, with the private key of cert, as
+// SigV4 does with a secret key, but for the certificate identified by its serial number.
+func signRolesAnywhereRequest(req *http.Request, body []byte, cert tls.Certificate, region string, now time.Time) error {
+	leaf, err := leafCertificate(cert)
+	if err != nil {
+		return err
+	}
+
+	var algorithm string
+	switch cert.PrivateKey.(type) {
+	case *rsa.PrivateKey:
+		algorithm = "AWS4-X509-RSA-SHA256"
+	case *ecdsa.PrivateKey:
+		algorithm = "AWS4-X509-ECDSA-SHA256"
+	default:
+		return fmt.Errorf("unsupported private key type %T, only RSA and ECDSA keys are", cert.PrivateKey)
+	}
+
+	amzDate := now.UTC().Format("20060102T150405Z")
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-X509", base64.StdEncoding.EncodeToString(leaf.Raw))
+	if len(cert.Certificate) > 1 {
+		chain := make([]string, 0, len(cert.Certificate)-1)
+		for _, der := range cert.Certificate[1:] {
+			chain = append(chain, base64.StdEncoding.EncodeToString(der))
+		}
+		req.Header.Set("X-Amz-X509-Chain", strings.Join(chain, ","))
+	}
+
+	canonicalRequest, signedHeaders := rolesAnywhereCanonicalRequest(req, body)
+	scope := fmt.Sprintf("%s/%s/rolesanywhere/aws4_request", amzDate[:8], region)
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{algorithm, amzDate, scope, hex.EncodeToString(hash[:])}, "\n")
+
+	digest := sha256.Sum256([]byte(stringToSign))
+	signature, err := cert.PrivateKey.(crypto.Signer).Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		algorithm, leaf.SerialNumber.String(), scope, signedHeaders, hex.EncodeToString(signature)))
+	return nil
+}
+
+// rolesAnywhereCanonicalRequest returns the canonical form of req signed by
+// signRolesAnywhereRequest, along with the list of the headers it signs.
+func rolesAnywhereCanonicalRequest(req *http.Request, body []byte) (string, string) {
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+
+	var headers strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&headers, "%s:%s\n", name, strings.TrimSpace(req.Header.Get(name)))
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	hash := sha256.Sum256(body)
+	return strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		headers.String(),
+		signedHeaders,
+		hex.EncodeToString(hash[:]),
+	}, "\n"), signedHeaders
+}
+
+// leafCertificate returns the parsed leaf of cert.
+func leafCertificate(cert tls.Certificate) (*x509.Certificate, error) {
+	if cert.Leaf != nil {
+		return cert.Leaf, nil
+	}
+	if len(cert.Certificate) == 0 {
+		return nil, errors.New("certificate is empty")
+	}
+	return x509.ParseCertificate(cert.Certificate[0])
+}
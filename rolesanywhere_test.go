@@ -0,0 +1,173 @@
+package s3client
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+const (
+	testTrustAnchorARN = "arn:aws:rolesanywhere:eu-west-1:123456789012:trust-anchor/anchor"
+	testProfileARN     = "arn:aws:rolesanywhere:eu-west-1:123456789012:profile/profile"
+	testRoleARN        = "arn:aws:iam::123456789012:role/workload"
+)
+
+// newTestCertificate returns a self-signed certificate holding the given private key.
+func newTestCertificate(t *testing.T, key crypto.Signer) tls.Certificate {
+	t.Helper()
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1234567890),
+		Subject:      pkix.Name{CommonName: "workload"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	assert.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestRolesAnywhereProvider(t *testing.T) {
+	ctx := context.TODO()
+	now := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	expires := now.Add(time.Hour)
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	authorization := regexp.MustCompile(`^(AWS4-X509-(?:RSA|ECDSA)-SHA256) Credential=1234567890/20240501/eu-west-1/rolesanywhere/aws4_request, SignedHeaders=content-type;host;x-amz-date;x-amz-x509, Signature=([0-9a-f]+)$`)
+
+	for name, key := range map[string]crypto.Signer{"ecdsa": ecdsaKey, "rsa": rsaKey} {
+		t.Run(name, func(t *testing.T) {
+			cert := newTestCertificate(t, key)
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				assert.Equal(t, "/sessions", r.URL.Path)
+				assert.Equal(t, `{"profileArn":"`+testProfileARN+`","roleArn":"`+testRoleARN+`","trustAnchorArn":"`+testTrustAnchorARN+`"}`, string(body))
+				assert.Equal(t, base64.StdEncoding.EncodeToString(cert.Certificate[0]), r.Header.Get("X-Amz-X509"))
+
+				match := authorization.FindStringSubmatch(r.Header.Get("Authorization"))
+				if match == nil {
+					t.Errorf("unexpected authorization header: %q", r.Header.Get("Authorization"))
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+
+				bodyHash := sha256.Sum256(body)
+				canonicalRequest := strings.Join([]string{
+					"POST",
+					"/sessions",
+					"",
+					"content-type:application/json",
+					"host:" + r.Host,
+					"x-amz-date:20240501T123000Z",
+					"x-amz-x509:" + r.Header.Get("X-Amz-X509"),
+					"",
+					"content-type;host;x-amz-date;x-amz-x509",
+					hex.EncodeToString(bodyHash[:]),
+				}, "\n")
+				requestHash := sha256.Sum256([]byte(canonicalRequest))
+				digest := sha256.Sum256([]byte(match[1] + "\n20240501T123000Z\n20240501/eu-west-1/rolesanywhere/aws4_request\n" + hex.EncodeToString(requestHash[:])))
+				signature, _ := hex.DecodeString(match[2])
+
+				switch pub := key.Public().(type) {
+				case *ecdsa.PublicKey:
+					assert.Equal(t, "AWS4-X509-ECDSA-SHA256", match[1])
+					assert.True(t, ecdsa.VerifyASN1(pub, digest[:], signature))
+				case *rsa.PublicKey:
+					assert.Equal(t, "AWS4-X509-RSA-SHA256", match[1])
+					assert.NoError(t, rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature))
+				}
+
+				w.WriteHeader(http.StatusCreated)
+				_, _ = fmt.Fprintf(w, `{"credentialSet":[{"credentials":{"accessKeyId":"access","secretAccessKey":"secret","sessionToken":"token","expiration":%q}}]}`, expires.Format(time.RFC3339))
+			}))
+			defer srv.Close()
+
+			var opts ClientOpts
+			assert.NoError(t, WithRolesAnywhere(testTrustAnchorARN, testProfileARN, testRoleARN, cert)(&opts))
+
+			provider := newRolesAnywhereProvider(srv.Client(), &opts)
+			assert.Equal(t, "https://rolesanywhere.eu-west-1.amazonaws.com", provider.endpoint)
+			provider.endpoint = srv.URL
+			provider.now = func() time.Time { return now }
+
+			creds, err := provider.Retrieve(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, aws.Credentials{
+				AccessKeyID:     "access",
+				SecretAccessKey: "secret",
+				SessionToken:    "token",
+				Source:          "RolesAnywhereProvider",
+				CanExpire:       true,
+				Expires:         expires,
+			}, creds)
+		})
+	}
+
+	t.Run("rejected", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = io.WriteString(w, `{"message":"Untrusted certificate. Insufficient certificate"}`)
+		}))
+		defer srv.Close()
+
+		var opts ClientOpts
+		assert.NoError(t, WithRolesAnywhere(testTrustAnchorARN, testProfileARN, testRoleARN, newTestCertificate(t, ecdsaKey))(&opts))
+
+		provider := newRolesAnywhereProvider(srv.Client(), &opts)
+		provider.endpoint = srv.URL
+
+		_, err := provider.Retrieve(ctx)
+		assert.EqualError(t, err, `error creating roles anywhere session: 403 Forbidden: {"message":"Untrusted certificate. Insufficient certificate"}`)
+	})
+}
+
+func TestWithRolesAnywhere(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	cert := newTestCertificate(t, key)
+
+	var opts ClientOpts
+	assert.EqualError(t, WithRolesAnywhere("anchor", testProfileARN, testRoleARN, cert)(&opts), `roles anywhere trust anchor must be an arn, got "anchor"`)
+	assert.EqualError(t, WithRolesAnywhere(testTrustAnchorARN, testProfileARN, "role", cert)(&opts), `roles anywhere role must be an arn, got "role"`)
+	assert.EqualError(t, WithRolesAnywhere("arn:aws:rolesanywhere::123456789012:trust-anchor/anchor", testProfileARN, testRoleARN, cert)(&opts),
+		`roles anywhere trust anchor arn "arn:aws:rolesanywhere::123456789012:trust-anchor/anchor" has no region`)
+	assert.EqualError(t, WithRolesAnywhere(testTrustAnchorARN, testProfileARN, testRoleARN, tls.Certificate{})(&opts), "invalid roles anywhere certificate: certificate is empty")
+	assert.EqualError(t, WithRolesAnywhere(testTrustAnchorARN, testProfileARN, testRoleARN, tls.Certificate{Certificate: cert.Certificate})(&opts),
+		"roles anywhere certificate must hold an RSA or ECDSA private key, got <nil>")
+
+	assert.NoError(t, WithRolesAnywhere(testTrustAnchorARN, testProfileARN, testRoleARN, cert)(&opts))
+	assert.NoError(t, opts.validate())
+
+	cfg := aws.Config{HTTPClient: http.DefaultClient}
+	opts.applyCredentials(&cfg)
+	assert.True(t, cfg.Credentials.(*aws.CredentialsCache).IsCredentialsProvider(&rolesAnywhereProvider{}))
+
+	assert.NoError(t, WithStaticCredentials("access", "secret")(&opts))
+	assert.EqualError(t, opts.validate(), "conflicting authentication methods: static credentials, roles anywhere")
+}
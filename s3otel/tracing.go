@@ -0,0 +1,110 @@
+// Package s3otel traces the S3 API calls made by s3client with OpenTelemetry.
+//
+// It is a module of its own, apart from s3client, so that only the users tracing their calls
+// depend on OpenTelemetry.
+// Every S3 API call, e.g. the GetObject call behind ReadFile or each ListObjectsV2 page listed by
+// ListFiles, gets a client span that is a child of the span found in the context of the call.
+package s3otel
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	s3client "github.com/calyptia/go-s3-client"
+)
+
+// Attributes set on the spans of S3 API calls.
+const (
+	BucketKey           = attribute.Key("aws.s3.bucket")
+	ObjectKey           = attribute.Key("aws.s3.key")
+	RequestBodySizeKey  = attribute.Key("http.request.body.size")
+	ResponseBodySizeKey = attribute.Key("http.response.body.size")
+	StatusCodeKey       = attribute.Key("http.response.status_code")
+)
+
+// WithTracing returns a s3client.ClientOptsFunc that creates a span with tracer for every S3 API
+// call made by the client. Spans are named after the operation, e.g. "S3.GetObject", and record
+// the bucket, the key, the size of the request and response bodies and the error, if any.
+func WithTracing(tracer trace.Tracer) s3client.ClientOptsFunc {
+	return func(opts *s3client.ClientOpts) error {
+		if tracer == nil {
+			return errors.New("tracer must not be nil")
+		}
+		return s3client.WithAPIOptions(func(stack *middleware.Stack) error {
+			return addMiddleware(stack, tracer)
+		})(opts)
+	}
+}
+
+// addMiddleware adds to stack the middleware starting the span of the call, and the one
+// recording the sizes and status of each of its attempts on it.
+func addMiddleware(stack *middleware.Stack, tracer trace.Tracer) error {
+	// after the service metadata, which names the operation, is registered.
+	err := stack.Initialize.Add(middleware.InitializeMiddlewareFunc("S3ClientTracing", func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+		op := awsmiddleware.GetOperationName(ctx)
+
+		attrs := []attribute.KeyValue{
+			attribute.String("rpc.system", "aws-api"),
+			attribute.String("rpc.service", "S3"),
+			attribute.String("rpc.method", op),
+		}
+		if bucket := stringField(in.Parameters, "Bucket"); bucket != "" {
+			attrs = append(attrs, BucketKey.String(bucket))
+		}
+		if key := stringField(in.Parameters, "Key"); key != "" {
+			attrs = append(attrs, ObjectKey.String(key))
+		}
+
+		ctx, span := tracer.Start(ctx, "S3."+op, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+		defer span.End()
+
+		out, metadata, err := next.HandleInitialize(ctx, in)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return out, metadata, err
+	}), middleware.After)
+	if err != nil {
+		return err
+	}
+
+	return stack.Deserialize.Add(middleware.DeserializeMiddlewareFunc("S3ClientTracingAttempt", func(ctx context.Context, in middleware.DeserializeInput, next middleware.DeserializeHandler) (middleware.DeserializeOutput, middleware.Metadata, error) {
+		out, metadata, err := next.HandleDeserialize(ctx, in)
+
+		span := trace.SpanFromContext(ctx)
+		if req, ok := in.Request.(*smithyhttp.Request); ok && req.ContentLength > 0 {
+			span.SetAttributes(RequestBodySizeKey.Int64(req.ContentLength))
+		}
+		if resp, ok := out.RawResponse.(*smithyhttp.Response); ok && resp.Response != nil {
+			span.SetAttributes(StatusCodeKey.Int(resp.StatusCode))
+			if resp.ContentLength >= 0 {
+				span.SetAttributes(ResponseBodySizeKey.Int64(resp.ContentLength))
+			}
+		}
+		return out, metadata, err
+	}), middleware.After)
+}
+
+// stringField returns the value of the *string field with the given name of the input struct
+// pointed to by params, or an empty string if there is none.
+func stringField(params interface{}, name string) string {
+	v := reflect.ValueOf(params)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return ""
+	}
+
+	field := v.Elem().FieldByName(name)
+	if !field.IsValid() || field.Kind() != reflect.Pointer || field.IsNil() || field.Elem().Kind() != reflect.String {
+		return ""
+	}
+	return field.Elem().String()
+}
@@ -0,0 +1,98 @@
+package s3otel
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	s3client "github.com/calyptia/go-s3-client"
+)
+
+type nullLogger struct{}
+
+func (nullLogger) Error(format string, a ...any) {}
+func (nullLogger) Warn(format string, a ...any)  {}
+func (nullLogger) Info(format string, a ...any)  {}
+func (nullLogger) Debug(format string, a ...any) {}
+
+func TestWithTracing(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/missing.log") {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`))
+			return
+		}
+		_, _ = w.Write([]byte("one\ntwo"))
+	}))
+	defer srv.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	c, err := s3client.New(context.TODO(), nullLogger{},
+		s3client.WithRegion("us-east-1"),
+		s3client.WithS3Compatible(srv.URL),
+		s3client.WithStaticCredentials("access", "secret"),
+		WithTracing(tracer),
+	)
+	assert.NoError(t, err)
+	defer c.Close()
+
+	read := func(file string) error {
+		outCh, errCh := c.ReadFile(context.TODO(), "bucket", file, 64*1024, 10*1024*1024)
+		for {
+			select {
+			case _, ok := <-outCh:
+				if !ok {
+					return <-errCh
+				}
+			case err := <-errCh:
+				return err
+			}
+		}
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		assert.NoError(t, read("file.log"))
+
+		spans := recorder.Ended()
+		assert.Equal(t, 1, len(spans))
+		span := spans[0]
+		assert.Equal(t, "S3.GetObject", span.Name())
+		assert.Equal(t, codes.Unset, span.Status().Code)
+
+		attrs := attribute.NewSet(span.Attributes()...)
+		bucket, _ := attrs.Value(BucketKey)
+		assert.Equal(t, "bucket", bucket.AsString())
+		key, _ := attrs.Value(ObjectKey)
+		assert.Equal(t, "file.log", key.AsString())
+		size, _ := attrs.Value(ResponseBodySizeKey)
+		assert.Equal(t, int64(len("one\ntwo")), size.AsInt64())
+	})
+
+	t.Run("error", func(t *testing.T) {
+		assert.IsError(t, read("missing.log"), s3client.ErrNoSuchKey)
+
+		spans := recorder.Ended()
+		span := spans[len(spans)-1]
+		assert.Equal(t, "S3.GetObject", span.Name())
+		assert.Equal(t, codes.Error, span.Status().Code)
+
+		attrs := attribute.NewSet(span.Attributes()...)
+		status, _ := attrs.Value(StatusCodeKey)
+		assert.Equal(t, int64(http.StatusNotFound), status.AsInt64())
+	})
+
+	t.Run("nil tracer", func(t *testing.T) {
+		var opts s3client.ClientOpts
+		assert.EqualError(t, WithTracing(nil)(&opts), "tracer must not be nil")
+	})
+}
@@ -0,0 +1,134 @@
+package s3client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ReadFileTail returns the last n lines of the specified file from the S3 bucket.
+// Uncompressed objects are read backwards with ranged requests, starting with
+// initialBufferSize bytes and doubling the amount read until n complete lines are found.
+// Compressed objects can't be decoded from the end, so they are read whole instead and
+// only their last n lines are kept.
+func (c *DefaultClient) ReadFileTail(ctx context.Context, bucket string, file string, n int, initialBufferSize int, maxBufferSize int) (_ []string, err error) {
+	defer c.observe("ReadFileTail", time.Now(), &err)
+
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid line count: %d", n)
+	}
+	if initialBufferSize <= 0 {
+		return nil, fmt.Errorf("invalid initial buffer size: %d", initialBufferSize)
+	}
+
+	head, err := c.headObject(ctx, bucket, file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file from s3: %w", mapError(err))
+	}
+
+	info := newObjectInfoFromHeadObject(file, head)
+	if isCompressed(info) {
+		c.logger().Warn("file: %s from bucket: %s is compressed, reading it whole to find its last lines", file, bucket)
+		out, errChan := c.ReadFile(ctx, bucket, file, initialBufferSize, maxBufferSize)
+		return lastLines(out, errChan, n)
+	}
+
+	data, err := c.getObjectTail(ctx, bucket, info, n, initialBufferSize, maxBufferSize)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan string)
+	errChan := make(chan error)
+	go func() {
+		defer close(errChan)
+		defer close(out)
+
+		// only the tail of the object is scanned.
+		tailInfo := info
+		tailInfo.Size = int64(len(data))
+		c.scanLines(bucket, tailInfo, io.NopCloser(bytes.NewReader(data)), initialBufferSize, maxBufferSize, out, errChan)
+	}()
+
+	return lastLines(out, errChan, n)
+}
+
+// getObjectTail reads the object backwards until its last n lines have been read, and
+// returns its content from the start of the first of them.
+func (c *DefaultClient) getObjectTail(ctx context.Context, bucket string, info ObjectInfo, n int, initialBufferSize int, maxBufferSize int) ([]byte, error) {
+	var data []byte
+	for end := info.Size; end > 0; {
+		offset := max(end-max(int64(len(data)), int64(initialBufferSize)), 0)
+
+		chunk, err := c.getObjectRange(ctx, bucket, info, offset, end-offset)
+		if err != nil {
+			return nil, err
+		}
+		data = append(chunk, data...)
+		end = offset
+
+		if end == 0 {
+			break
+		}
+		if i := lastLinesStart(data, n); i >= 0 {
+			return data[i:], nil
+		}
+
+		// without n line breaks in n times the max buffer size, one of the lines is too long.
+		if !c.opts.SkipLongLines && len(data) > n*maxBufferSize {
+			c.logger().Error("Encountered a line that was too long to read in file: %s from bucket: %s, exceeds > %d", info.Key, bucket, maxBufferSize)
+			return nil, bufio.ErrTooLong
+		}
+	}
+	return data, nil
+}
+
+// lastLinesStart returns the offset in data where its last n lines start, or -1 when
+// data doesn't hold n complete lines. A line break at the very end doesn't start a line.
+func lastLinesStart(data []byte, n int) int {
+	end := len(data)
+	if end > 0 && data[end-1] == '\n' {
+		end--
+	}
+
+	for i := end - 1; i >= 0; i-- {
+		if data[i] != '\n' {
+			continue
+		}
+		if n--; n == 0 {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// lastLines consumes a line stream and returns its last n lines.
+func lastLines(out <-chan string, errChan <-chan error, n int) ([]string, error) {
+	// ring holds the last n lines read, the oldest of them at next once it is full.
+	ring := make([]string, 0, n)
+	next := 0
+	for {
+		select {
+		case line, ok := <-out:
+			if !ok {
+				return append(ring[next:], ring[:next]...), nil
+			}
+			if len(ring) < n {
+				ring = append(ring, line)
+				continue
+			}
+			ring[next] = line
+			next = (next + 1) % n
+		case err, ok := <-errChan:
+			if !ok {
+				errChan = nil
+				continue
+			}
+			go drain(out, errChan)
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package s3client
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestDefaultClient_ReadFileTail(t *testing.T) {
+	ctx := context.TODO()
+
+	var lines []string
+	for i := 0; i < 200; i++ {
+		lines = append(lines, fmt.Sprintf("line number %d", i))
+	}
+	content := []byte(strings.Join(lines, "\n") + "\n")
+
+	t.Run("ok", func(t *testing.T) {
+		var inFlight, maxInFlight int32
+		client := rangeMock(content, &inFlight, &maxInFlight)
+
+		c := DefaultClient{
+			Svc:    client,
+			Logger: NullLogger{},
+		}
+
+		got, err := c.ReadFileTail(ctx, "bucket", "file.log", 3, 64, 1024)
+		assert.NoError(t, err)
+		assert.Equal(t, lines[197:], got)
+
+		// the first range holds 3 line breaks besides the trailing one.
+		assert.Equal(t, 1, len(client.GetObjectCalls()))
+		assert.Equal(t, fmt.Sprintf("bytes=%d-%d", len(content)-64, len(content)-1), *client.GetObjectCalls()[0].Params.Range)
+	})
+
+	t.Run("reads backwards", func(t *testing.T) {
+		var inFlight, maxInFlight int32
+		client := rangeMock(content, &inFlight, &maxInFlight)
+
+		c := DefaultClient{
+			Svc:    client,
+			Logger: NullLogger{},
+		}
+
+		got, err := c.ReadFileTail(ctx, "bucket", "file.log", 50, 64, 1024)
+		assert.NoError(t, err)
+		assert.Equal(t, lines[150:], got)
+		assert.True(t, len(client.GetObjectCalls()) > 1)
+	})
+
+	t.Run("fewer lines than requested", func(t *testing.T) {
+		var inFlight, maxInFlight int32
+		client := rangeMock([]byte("one\ntwo"), &inFlight, &maxInFlight)
+
+		c := DefaultClient{
+			Svc:    client,
+			Logger: NullLogger{},
+		}
+
+		got, err := c.ReadFileTail(ctx, "bucket", "file.log", 10, 64, 1024)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"one", "two"}, got)
+	})
+
+	t.Run("compressed", func(t *testing.T) {
+		var b bytes.Buffer
+		w := gzip.NewWriter(&b)
+		_, err := w.Write(content)
+		assert.NoError(t, err)
+		assert.NoError(t, w.Close())
+
+		var inFlight, maxInFlight int32
+		client := rangeMock(b.Bytes(), &inFlight, &maxInFlight)
+
+		c := DefaultClient{
+			Svc:    client,
+			Logger: NullLogger{},
+		}
+
+		got, err := c.ReadFileTail(ctx, "bucket", "file.log.gz", 3, 64, 1024)
+		assert.NoError(t, err)
+		assert.Equal(t, lines[197:], got)
+		assert.Equal(t, 1, len(client.GetObjectCalls()))
+		assert.Zero(t, client.GetObjectCalls()[0].Params.Range)
+	})
+
+	t.Run("line too long", func(t *testing.T) {
+		var inFlight, maxInFlight int32
+		client := rangeMock([]byte(strings.Repeat("x", 1000)+"\nlast"), &inFlight, &maxInFlight)
+
+		c := DefaultClient{
+			Svc:    client,
+			Logger: NullLogger{},
+		}
+
+		_, err := c.ReadFileTail(ctx, "bucket", "file.log", 2, 64, 128)
+		assert.IsError(t, err, bufio.ErrTooLong)
+	})
+
+	t.Run("invalid line count", func(t *testing.T) {
+		c := DefaultClient{Logger: NullLogger{}}
+
+		_, err := c.ReadFileTail(ctx, "bucket", "file.log", 0, 64, 1024)
+		assert.EqualError(t, err, "invalid line count: 0")
+	})
+}
@@ -1,16 +1,58 @@
 package s3client
 
 import (
-	"archive/tar"
+	"bufio"
 	"bytes"
-	"compress/gzip"
 	"errors"
 	"io"
 	"mime"
+	"net/http"
+	"net/url"
 	"path/filepath"
+	"sort"
 	"strings"
+	"unicode"
 )
 
+// DefaultSniffLen is the default number of bytes peeked from the beginning of an object
+// to detect its format.
+const DefaultSniffLen = 512
+
+// minSniffLen is the smallest sniff window supported, matching the minimum bufio.Reader size.
+const minSniffLen = 16
+
+// minPartSize is the smallest part, other than the last one, S3 accepts in a multipart upload.
+const minPartSize = 5 << 20
+
+// maxParts is the largest number of parts S3 accepts in a multipart upload.
+const maxParts = 10000
+
+// maxListPageSize is the largest number of keys S3 returns in a single listing page.
+const maxListPageSize = 1000
+
+// progressInterval is the number of bytes read between calls to a progress callback.
+const progressInterval = 1 << 20
+
+// progressReader counts the bytes read from r and reports the running total to fn
+// every interval bytes, and once more when r is exhausted.
+type progressReader struct {
+	r        io.Reader
+	fn       func(bytesRead int64)
+	interval int64
+	read     int64
+	reported int64
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += int64(n)
+	if p.read-p.reported >= p.interval || (errors.Is(err, io.EOF) && p.read != p.reported) {
+		p.reported = p.read
+		p.fn(p.read)
+	}
+	return n, err
+}
+
 // IsBinaryContentType returns true if the given content type is a binary content type,
 // and false otherwise.
 func IsBinaryContentType(contentType string) bool {
@@ -23,84 +65,437 @@ func IsBinaryContentType(contentType string) bool {
 
 	// Check if the media type is in the list of known binary media types
 	switch mediaType {
-	case "application/octet-stream", "application/gzip", "application/x-tar", "application/tar+gzip":
+	case "application/octet-stream", "application/gzip", "application/x-tar", "application/tar+gzip",
+		"application/zip", "application/x-bzip2", "application/zstd", "application/x-xz", "application/x-7z-compressed":
 		return true
 	default:
 		return false
 	}
 }
 
+// sniffLength is the number of bytes http.DetectContentType considers.
+const sniffLength = 512
+
+// isUnlabeledContentType reports whether contentType tells nothing about the content of an
+// object, as with the generic types S3 and most uploaders default to.
+func isUnlabeledContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err != nil || mediaType == "application/octet-stream" || mediaType == "binary/octet-stream"
+}
+
+// sniffContentType returns the content type http.DetectContentType detects from the start of r,
+// along with a reader over all of r, the sniffed bytes included.
+func sniffContentType(r io.Reader) (io.Reader, string) {
+	br := bufio.NewReaderSize(r, sniffLength)
+	// a read error is returned again by br once the peeked bytes are read.
+	data, _ := br.Peek(sniffLength)
+	return br, http.DetectContentType(data)
+}
+
 // GetFileReader returns a function that creates a reader for a given file,
-// based on the file's extension.
+// based on the file's extension and the readers registered with RegisterReader.
 // The returned function takes an io.Reader as input and returns an io.Reader
-// and an error, if any.
+// and an error, if any. Files whose extension has no registered reader are read as they are.
 func GetFileReader(filename string) func(io.Reader) (io.ReadCloser, error) {
 	// Get the file extension of the given file
 	extension := strings.ToLower(filepath.Ext(filename))
 
-	// Return the appropriate reader function depending on the file extension
-	switch {
-	case extension == ".gz" || extension == ".gzip":
-		return func(r io.Reader) (io.ReadCloser, error) {
-			// read the entire body from the reader.
-			// this should be buffered and with a seeker
-			body, err := io.ReadAll(r)
-			if err != nil {
-				return nil, err
-			}
+	if factory, ok := fileReaderFor(extension); ok {
+		return factory
+	}
 
-			orig := body
-			gr, err := gzip.NewReader(bytes.NewReader(body))
-			if err != nil {
-				// See https://github.com/aws/aws-sdk-go/issues/1292
-				// The default HTTP transports that the AWS SDK uses will decompress objects transparently
-				// if the Content Encoding is gzip. Not everyone or everything properly sets the Content-Encoding
-				// header on their S3 objects, so we could be trying to process gzipped objects and not know it.
-				if errors.Is(err, gzip.ErrHeader) {
-					rc := io.NopCloser(bytes.NewReader(orig))
-					return rc, nil
-				}
-				return nil, err
-			}
-			return gr, nil
+	return func(r io.Reader) (io.ReadCloser, error) {
+		rc := io.NopCloser(r)
+		return rc, nil
+	}
+}
+
+// contentTypeExtensions maps the media types of formats GetFileReader understands to the
+// extension it detects them by.
+var contentTypeExtensions = map[string]string{
+	"application/gzip":            ".gz",
+	"application/x-gzip":          ".gz",
+	"application/x-tar":           ".tar",
+	"application/x-snappy-framed": ".sz",
+	"application/x-lz4":           ".lz4",
+}
+
+// GetReaderForContentType returns a function that creates a reader for a file, as
+// GetFileReader does, but preferring the object's Content-Type and Content-Encoding
+// headers over the file's extension when they are present.
+// A gzip Content-Encoding is decoded first, and the result is then read based on the
+// Content-Type if it names a known format, or on the extension otherwise.
+func GetReaderForContentType(contentType, contentEncoding, filename string) func(io.Reader) (io.ReadCloser, error) {
+	fileReader := GetFileReader(filename)
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		if extension, ok := contentTypeExtensions[mediaType]; ok {
+			fileReader = GetFileReader(extension)
 		}
-	case extension == ".tar":
-		return func(r io.Reader) (io.ReadCloser, error) {
-			tr := io.NopCloser(tar.NewReader(r))
-			return tr, nil
+	}
+
+	if !isGzipEncoding(contentEncoding) {
+		return fileReader
+	}
+
+	gzipReader := GetFileReader(".gz")
+	return func(r io.Reader) (io.ReadCloser, error) {
+		gr, err := gzipReader(r)
+		if err != nil {
+			return nil, err
 		}
-	default:
-		return func(r io.Reader) (io.ReadCloser, error) {
-			rc := io.NopCloser(r)
-			return rc, nil
+
+		// objects named .gz are often served with a gzip Content-Encoding for a single layer
+		// of compression, the extension reader passes the decoded content through as is then.
+		rc, err := fileReader(gr)
+		if err != nil {
+			gr.Close()
+			return nil, err
+		}
+		return &layeredReader{ReadCloser: rc, inner: gr}, nil
+	}
+}
+
+// isGzipEncoding reports whether the given Content-Encoding is gzip.
+func isGzipEncoding(contentEncoding string) bool {
+	encoding := strings.TrimSpace(contentEncoding)
+	return strings.EqualFold(encoding, "gzip") || strings.EqualFold(encoding, "x-gzip")
+}
+
+// layeredReader is a reader decoding the output of another one, closing both when closed.
+type layeredReader struct {
+	io.ReadCloser
+	inner io.Closer
+}
+
+func (r *layeredReader) Close() error {
+	err := r.ReadCloser.Close()
+	if innerErr := r.inner.Close(); err == nil {
+		err = innerErr
+	}
+	return err
+}
+
+// newSniffReader returns r as a *bufio.Reader that can be used to peek into the
+// beginning of the stream. If r already is a *bufio.Reader its window is kept,
+// otherwise a new one of DefaultSniffLen bytes is created.
+func newSniffReader(r io.Reader) *bufio.Reader {
+	if br, ok := r.(*bufio.Reader); ok {
+		return br
+	}
+	return bufio.NewReaderSize(r, DefaultSniffLen)
+}
+
+// isGzip reports whether the stream peeked through br starts with the gzip magic bytes.
+func isGzip(br *bufio.Reader) bool {
+	magic, err := br.Peek(2)
+	return err == nil && magic[0] == 0x1f && magic[1] == 0x8b
+}
+
+// lz4Magic is the magic number at the beginning of every lz4 frame, in little endian.
+var lz4Magic = []byte{0x04, 0x22, 0x4d, 0x18}
+
+// isLZ4 reports whether the stream peeked through br starts with the lz4 frame magic number.
+func isLZ4(br *bufio.Reader) bool {
+	magic, err := br.Peek(len(lz4Magic))
+	return err == nil && bytes.Equal(magic, lz4Magic)
+}
+
+// tarBlockSize is the size of the blocks tar archives are made of, starting with a header block.
+const tarBlockSize = 512
+
+// tarMagic is the magic of the POSIX and GNU tar headers, at tarMagicOffset in the header block.
+var tarMagic = []byte("ustar")
+
+// tarMagicOffset is the offset of tarMagic in a tar header block.
+const tarMagicOffset = 257
+
+// isTar reports whether the stream peeked through br starts with a tar header block.
+func isTar(br *bufio.Reader) bool {
+	magic, err := br.Peek(tarMagicOffset + len(tarMagic))
+	return err == nil && bytes.Equal(magic[tarMagicOffset:], tarMagic)
+}
+
+// isGzipped reports whether the given object is gzipped, by its extension or its Content-Encoding.
+func isGzipped(info ObjectInfo) bool {
+	switch strings.ToLower(filepath.Ext(info.Key)) {
+	case ".gz", ".gzip":
+		return true
+	}
+	return isGzipEncoding(info.ContentEncoding)
+}
+
+// skipLongLines returns a bufio.SplitFunc that splits lines as bufio.ScanLines does, but drops
+// the lines that don't fit in maxLen bytes, calling skipped for each one of them.
+func skipLongLines(maxLen int, skipped func()) bufio.SplitFunc {
+	// skipping is set while the remainder of a dropped line is being discarded.
+	skipping := false
+	var split bufio.SplitFunc
+	split = func(data []byte, atEOF bool) (int, []byte, error) {
+		if skipping {
+			i := bytes.IndexByte(data, '\n')
+			if i < 0 {
+				return len(data), nil, nil
+			}
+			skipping = false
+			// the scanner stops at the end of the data without another token, so the
+			// lines after the dropped one are scanned right away.
+			advance, token, err := split(data[i+1:], atEOF)
+			return i + 1 + advance, token, err
+		}
+
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		if advance == 0 && token == nil && err == nil && len(data) >= maxLen {
+			skipping = true
+			skipped()
+			return len(data), nil, nil
 		}
+		return advance, token, err
 	}
+	return split
 }
 
 // IsGlobPattern returns true if the given string is a glob pattern.
+// A backslash on its own does not make a glob pattern, as it is a valid literal
+// character in S3 keys; it only acts as an escape within patterns that contain
+// other special characters, e.g. `dir/file\*.txt`.
 func IsGlobPattern(s string) bool {
-	// Check if the string contains any of the special glob characters: *, ?, [, or \
-	return strings.ContainsAny(s, "*?[\\")
+	// Check if the string contains any of the special glob characters: *, ?, [, or {
+	return strings.ContainsAny(s, "*?[{")
+}
+
+// escapeGlob returns s with its special glob characters escaped, so that it only matches itself.
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[{\`, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
 }
 
 // GetDirPrefix returns the directory prefix from a glob expression.
+// S3 keys always use "/" as separator, regardless of the operating system.
 func GetDirPrefix(glob string) string {
-	// Split the glob expression by the path separator
-	parts := strings.Split(glob, string(filepath.Separator))
+	// Split the glob expression by the key separator
+	parts := strings.Split(glob, "/")
 
 	// Find the index of the last wildcard
 	lastWildcardIndex := -1
 	for i := len(parts) - 1; i >= 0; i-- {
-		if strings.ContainsAny(parts[i], "*?[") {
+		if strings.ContainsAny(parts[i], "*?[{") {
 			lastWildcardIndex = i
 		}
 	}
 
 	// If there is a wildcard, return the part before it
 	if lastWildcardIndex >= 0 {
-		return strings.Join(parts[:lastWildcardIndex], string(filepath.Separator))
+		return strings.Join(parts[:lastWildcardIndex], "/")
 	}
 
 	// If there are no wildcards, the whole expression is the directory prefix
 	return glob
 }
+
+// listPrefix returns the longest literal prefix of the keys matched by pattern, to narrow
+// down the objects listed to match it. Unlike GetDirPrefix it keeps the trailing "/" and the
+// literal start of the segment holding the first wildcard, so "data/**/*.log" only lists
+// "data/" rather than "data", which would take in "database/" as well. Patterns starting
+// with a wildcard, such as "**/logs/*.log", can't be narrowed down and list the whole bucket.
+func listPrefix(pattern string) string {
+	// Patterns without wildcards name a single key, backslashes included.
+	if !IsGlobPattern(pattern) {
+		return pattern
+	}
+	return literalPrefix(pattern)
+}
+
+// literalPrefix returns the start of the glob pattern up to its first wildcard, unescaped.
+func literalPrefix(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		switch ch := pattern[i]; ch {
+		case '*', '?', '[', '{':
+			return b.String()
+		case '\\':
+			// an escaped character only matches itself.
+			if i+1 == len(pattern) {
+				return b.String()
+			}
+			i++
+			b.WriteByte(pattern[i])
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return b.String()
+}
+
+// coveringPrefixes returns the given prefixes in lexicographical order, leaving out those
+// another one is a prefix of, the smallest set of prefixes to list so each key is listed at most
+// once.
+func coveringPrefixes(prefixes []string) []string {
+	sort.Strings(prefixes)
+
+	// once sorted, a prefix covering others comes right before them.
+	var out []string
+	for _, prefix := range prefixes {
+		if len(out) > 0 && strings.HasPrefix(prefix, out[len(out)-1]) {
+			continue
+		}
+		out = append(out, prefix)
+	}
+	return out
+}
+
+// caseFoldPrefix returns the start of prefix up to its first character with another case, the
+// longest prefix listing all the keys a case-insensitive match of it can take in.
+func caseFoldPrefix(prefix string) string {
+	for i, r := range prefix {
+		if unicode.SimpleFold(r) != r {
+			return prefix[:i]
+		}
+	}
+	return prefix
+}
+
+// maxBraceExpansions bounds the number of patterns the brace alternatives of a pattern are
+// expanded to for listing, past which its prefix up to the first brace is listed instead.
+const maxBraceExpansions = 64
+
+// patternPrefixes returns the prefixes to list to match pattern, one per brace alternative of
+// its literal prefix.
+func patternPrefixes(pattern string) []string {
+	if !IsGlobPattern(pattern) {
+		return []string{pattern}
+	}
+
+	expanded, ok := expandBraces(pattern, maxBraceExpansions)
+	if !ok {
+		return []string{literalPrefix(pattern)}
+	}
+	prefixes := make([]string, 0, len(expanded))
+	for _, p := range expanded {
+		prefixes = append(prefixes, literalPrefix(p))
+	}
+	return prefixes
+}
+
+// expandBraces returns the patterns the brace alternatives of pattern expand to, e.g.
+// "{a,b}/*.log" to "a/*.log" and "b/*.log", nested ones included. It returns false if
+// there are more than limit of them. Escaped braces and commas are left as they are.
+func expandBraces(pattern string, limit int) ([]string, bool) {
+	start, end := -1, -1
+	var commas []int
+	depth := 0
+loop:
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '{':
+			if depth == 0 {
+				start = i
+				commas = commas[:0]
+			}
+			depth++
+		case ',':
+			if depth == 1 {
+				commas = append(commas, i)
+			}
+		case '}':
+			if depth == 0 {
+				continue
+			}
+			if depth--; depth == 0 {
+				end = i
+				break loop
+			}
+		}
+	}
+	if end < 0 {
+		// no braces, or unbalanced ones, which then match themselves.
+		return []string{pattern}, true
+	}
+
+	var alternatives []string
+	prev := start + 1
+	for _, comma := range append(commas, end) {
+		alternatives = append(alternatives, pattern[prev:comma])
+		prev = comma + 1
+	}
+
+	var out []string
+	for _, alternative := range alternatives {
+		expanded, ok := expandBraces(pattern[:start]+alternative+pattern[end+1:], limit-len(out))
+		if !ok || len(out)+len(expanded) > limit {
+			return nil, false
+		}
+		out = append(out, expanded...)
+	}
+	return out, true
+}
+
+// trimETag returns the given ETag without its surrounding double quotes,
+// as S3 returns them quoted but callers often store them bare.
+func trimETag(etag string) string {
+	return strings.Trim(etag, `"`)
+}
+
+// IsMultipartETag reports whether the given ETag is the composite one S3 assigns to objects
+// uploaded in parts, such as "d41d8cd98f00b204e9800998ecf8427e-3": the MD5 of the MD5s of the
+// parts followed by their count, rather than the MD5 of the object's contents.
+func IsMultipartETag(etag string) bool {
+	return strings.Contains(normalizeETag(etag), "-")
+}
+
+// FilterNewETags returns the objects whose ETag isn't among the known ones, in their order, such
+// as the objects listed by ListFilesWithMetadata that haven't been synced yet. ETags are compared
+// regardless of the quotes S3 surrounds them with, a weak "W/" prefix and case.
+// The composite ETags of multipart uploads, see IsMultipartETag, aren't MD5s of the contents: the
+// same contents uploaded with other part sizes, or in a single part, get another ETag, as do the
+// copies CopyObject makes. So that unchanged multipart objects aren't found new, the known ETags
+// must be the ones listed for the source objects when they were synced, not the ETags of their
+// copies nor MD5s computed from their contents.
+func FilterNewETags(objects []ObjectInfo, knownETags map[string]bool) []ObjectInfo {
+	known := make(map[string]bool, len(knownETags))
+	for etag, ok := range knownETags {
+		if ok {
+			known[normalizeETag(etag)] = true
+		}
+	}
+
+	var out []ObjectInfo
+	for _, obj := range objects {
+		if !known[normalizeETag(obj.ETag)] {
+			out = append(out, obj)
+		}
+	}
+	return out
+}
+
+// normalizeETag returns the given ETag without its weak prefix and quotes, lower-cased.
+func normalizeETag(etag string) string {
+	return strings.ToLower(trimETag(strings.TrimPrefix(strings.TrimSpace(etag), "W/")))
+}
+
+// dirPrefix returns the given prefix with a trailing "/" so that it only matches
+// keys inside that pseudo-directory. An empty prefix is returned as is.
+func dirPrefix(prefix string) string {
+	if prefix == "" || strings.HasSuffix(prefix, "/") {
+		return prefix
+	}
+	return prefix + "/"
+}
+
+// copySource returns the CopySource of a CopyObject request for the given object, the bucket and
+// the URL-escaped key. Every segment of the key is escaped on its own so that "/" is kept, and "+"
+// is escaped as well, otherwise S3 decodes it as a space.
+func copySource(bucket, key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = strings.ReplaceAll(url.PathEscape(segment), "+", "%2B")
+	}
+	return bucket + "/" + strings.Join(segments, "/")
+}
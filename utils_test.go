@@ -1,9 +1,17 @@
 package s3client
 
 import (
+	"bufio"
 	"bytes"
 	"compress/gzip"
+	"io"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/iotest"
+
+	"github.com/golang/snappy"
+	"github.com/pierrec/lz4/v4"
 )
 
 func TestIsGlobPattern(t *testing.T) {
@@ -19,8 +27,12 @@ func TestIsGlobPattern(t *testing.T) {
 		{"dir1/dir2/dir3/*/*/*.txt", true},
 		{"dir/[a-z]*.txt", true},
 		{"dir/subdir/file?.txt", true},
-		{"dir/subdir/file\\.txt", true},
+		// a lone backslash is a literal character in S3 keys.
+		{"dir/subdir/file\\.txt", false},
+		{"dir/subdir/file\\*.txt", true},
 		{"dir1/dir2/dir3", false},
+		{"file.{a,b}", true},
+		{"logs/*.{log,txt}", true},
 	}
 	for _, test := range tests {
 		isGlob := IsGlobPattern(test.s)
@@ -43,6 +55,11 @@ func TestGetDirPrefix(t *testing.T) {
 		{"dir1/dir2/dir3/*/*/*.txt", "dir1/dir2/dir3"},
 		{"dir/[a-z]*.txt", "dir"},
 		{"/file/test.txt", "/file/test.txt"},
+		{"logs/{app,web}/*.log", "logs"},
+		{"logs/file.{a,b}", "logs"},
+		// backslashes are regular characters in S3 keys, not separators.
+		{"dir\\sub/*.txt", "dir\\sub"},
+		{"dir\\sub\\*.txt", ""},
 	}
 	for _, test := range tests {
 		dirPrefix := GetDirPrefix(test.glob)
@@ -52,6 +69,87 @@ func TestGetDirPrefix(t *testing.T) {
 	}
 }
 
+func TestListPrefix(t *testing.T) {
+	tests := []struct {
+		pattern string
+		prefix  string
+	}{
+		{"data/**/*.log", "data/"},
+		{"data/2024/**", "data/2024/"},
+		{"**/*.log", ""},
+		{"**/logs/*.log", ""},
+		{"*.txt", ""},
+		{"dir/app-*.log", "dir/app-"},
+		{"logs/{app,web}/*.log", "logs/"},
+		{"logs/file.{a,b}", "logs/file."},
+		{"dir/[a-z]*.txt", "dir/"},
+		{"file.txt", "file.txt"},
+		// backslashes escape wildcards in patterns, but are literal in plain keys.
+		{"dir/file\\*/*.txt", "dir/file*/"},
+		{"dir\\sub\\file.txt", "dir\\sub\\file.txt"},
+	}
+	for _, test := range tests {
+		prefix := listPrefix(test.pattern)
+		if prefix != test.prefix {
+			t.Errorf("Expected listPrefix(%q) to return %q, but got %q", test.pattern, test.prefix, prefix)
+		}
+	}
+}
+
+func TestCoveringPrefixes(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		prefixes []string
+	}{
+		{[]string{"logs/*.log", "logs/*.json"}, []string{"logs/"}},
+		{[]string{"logs/app-*.log", "logs/*.log", "data/*.json"}, []string{"data/", "logs/"}},
+		{[]string{"logs/*.log", "**/*.json"}, []string{""}},
+		{[]string{"logs/file.txt", "logs/file.txt"}, []string{"logs/file.txt"}},
+		{[]string{"dir/*.log", "dir2/*.log"}, []string{"dir/", "dir2/"}},
+		{[]string{"logs/{web,app}/*.log"}, []string{"logs/app/", "logs/web/"}},
+		{[]string{"logs/{app,app-{1,2}}/*.log"}, []string{"logs/app-1/", "logs/app-2/", "logs/app/"}},
+		{[]string{"logs/{app,app/web}/*.log"}, []string{"logs/app/"}},
+		{[]string{"logs/*.{log,gz}"}, []string{"logs/"}},
+		{[]string{"logs/five.{gz,zip}"}, []string{"logs/five.gz", "logs/five.zip"}},
+		{[]string{"logs/\\{a,b}/*.log"}, []string{"logs/{a,b}/"}},
+		{nil, nil},
+	}
+	c := &DefaultClient{}
+	for _, test := range tests {
+		var patternPrefixes []string
+		for _, pattern := range test.patterns {
+			patternPrefixes = append(patternPrefixes, c.patternPrefixes(pattern)...)
+		}
+		prefixes := coveringPrefixes(patternPrefixes)
+		if !reflect.DeepEqual(prefixes, test.prefixes) {
+			t.Errorf("Expected the covering prefixes of %q to be %q, but got %q", test.patterns, test.prefixes, prefixes)
+		}
+	}
+}
+
+func TestExpandBraces(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		limit    int
+		expanded []string
+		ok       bool
+	}{
+		{"logs/*.log", 8, []string{"logs/*.log"}, true},
+		{"{a,b}/*.log", 8, []string{"a/*.log", "b/*.log"}, true},
+		{"{a,b{1,2}}/{x,y}", 8, []string{"a/x", "a/y", "b1/x", "b1/y", "b2/x", "b2/y"}, true},
+		{"{a,}.log", 8, []string{"a.log", ".log"}, true},
+		{"\\{a,b}", 8, []string{"\\{a,b}"}, true},
+		{"{a,b", 8, []string{"{a,b"}, true},
+		{"{a,b}{c,d}", 3, nil, false},
+	}
+	for _, test := range tests {
+		expanded, ok := expandBraces(test.pattern, test.limit)
+		if ok != test.ok || !reflect.DeepEqual(expanded, test.expanded) {
+			t.Errorf("Expected expandBraces(%q, %d) to return %q, %t, but got %q, %t", test.pattern, test.limit, test.expanded, test.ok, expanded, ok)
+		}
+	}
+}
+
 func TestIsBinaryContentType(t *testing.T) {
 	testCases := []struct {
 		contentType string
@@ -60,6 +158,13 @@ func TestIsBinaryContentType(t *testing.T) {
 		{"application/octet-stream", true},
 		{"application/gzip", true},
 		{"application/x-tar", true},
+		{"application/tar+gzip", true},
+		{"application/zip", true},
+		{"application/x-bzip2", true},
+		{"application/zstd", true},
+		{"application/x-xz", true},
+		{"application/x-7z-compressed", true},
+		{"application/zip; charset=binary", true},
 		{"application/pdf", false},
 		{"text/plain", false},
 		{"image/jpeg", false},
@@ -119,3 +224,308 @@ func TestGetFileReader(t *testing.T) {
 		t.Error("Expected default reader, got nil")
 	}
 }
+
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func TestGetFileReader_SniffLen(t *testing.T) {
+	content := strings.Repeat("some line of text\n", 1024)
+
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	if _, err := w.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	inputs := map[string][]byte{
+		"gzip":  b.Bytes(),
+		"plain": []byte(content),
+	}
+
+	for _, sniffLen := range []int{16, 64, 512, 4096} {
+		for name, input := range inputs {
+			src := &countingReader{r: bytes.NewReader(input)}
+			reader, err := GetFileReader("test.gz")(bufio.NewReaderSize(src, sniffLen))
+			if err != nil {
+				t.Fatalf("%s/%d: unexpected error: %v", name, sniffLen, err)
+			}
+
+			// detection must not consume more than the sniff window.
+			if src.n > sniffLen {
+				t.Errorf("%s/%d: read %d bytes before streaming, expected at most %d", name, sniffLen, src.n, sniffLen)
+			}
+
+			got, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatalf("%s/%d: unexpected error: %v", name, sniffLen, err)
+			}
+			if string(got) != content {
+				t.Errorf("%s/%d: decoded content does not match the source", name, sniffLen)
+			}
+		}
+	}
+}
+
+func TestGetFileReader_GzipLargeExtraField(t *testing.T) {
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	// an extra field much larger than the sniff window.
+	w.Extra = bytes.Repeat([]byte("x"), 8*1024)
+	if _, err := w.Write([]byte("test data")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := GetFileReader("test.gz")(bufio.NewReaderSize(bytes.NewReader(b.Bytes()), DefaultSniffLen))
+	if err != nil {
+		t.Fatalf("Unexpected error when reading gzip file: %v", err)
+	}
+
+	got, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Unexpected error when reading gzip file: %v", err)
+	}
+	if string(got) != "test data" {
+		t.Errorf("Expected %q, got %q", "test data", got)
+	}
+}
+
+func TestGetFileReader_Snappy(t *testing.T) {
+	content := strings.Repeat("some line of text\n", 1024)
+
+	var b bytes.Buffer
+	w := snappy.NewBufferedWriter(&b)
+	if _, err := w.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, filename := range []string{"test.sz", "test.snappy"} {
+		reader, err := GetFileReader(filename)(bytes.NewReader(b.Bytes()))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", filename, err)
+		}
+
+		got, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", filename, err)
+		}
+		if string(got) != content {
+			t.Errorf("%s: decoded content does not match the source", filename)
+		}
+		if err := reader.Close(); err != nil {
+			t.Errorf("%s: unexpected error when closing: %v", filename, err)
+		}
+	}
+}
+
+func TestGetFileReader_LZ4(t *testing.T) {
+	content := strings.Repeat("some line of text\n", 1024)
+
+	var b bytes.Buffer
+	w := lz4.NewWriter(&b)
+	if _, err := w.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	inputs := map[string][]byte{
+		"lz4": b.Bytes(),
+		// content that is not lz4 is read as is.
+		"plain": []byte(content),
+	}
+
+	for name, input := range inputs {
+		reader, err := GetFileReader("test.lz4")(bytes.NewReader(input))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+
+		got, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if string(got) != content {
+			t.Errorf("%s: decoded content does not match the source", name)
+		}
+		if err := reader.Close(); err != nil {
+			t.Errorf("%s: unexpected error when closing: %v", name, err)
+		}
+	}
+}
+
+func TestGetReaderForContentType(t *testing.T) {
+	gzipped := func(data []byte) []byte {
+		var b bytes.Buffer
+		w := gzip.NewWriter(&b)
+		if _, err := w.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return b.Bytes()
+	}
+
+	content := []byte("a,b,c\n1,2,3\n")
+
+	tests := []struct {
+		name            string
+		contentType     string
+		contentEncoding string
+		filename        string
+		input           []byte
+	}{
+		{"encoding without extension", "text/csv", "gzip", "test.csv", gzipped(content)},
+		{"encoding with extension", "", "gzip", "test.csv.gz", gzipped(content)},
+		{"encoding with gzipped content", "", "gzip", "test.csv.gz", gzipped(gzipped(content))},
+		{"content type", "application/gzip", "", "test.bin", gzipped(content)},
+		{"extension", "", "", "test.csv.gz", gzipped(content)},
+		{"plain", "text/csv", "", "test.csv", content},
+		{"unknown content type", "application/octet-stream", "", "test.csv.gz", gzipped(content)},
+	}
+	for _, test := range tests {
+		reader, err := GetReaderForContentType(test.contentType, test.contentEncoding, test.filename)(bytes.NewReader(test.input))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+
+		got, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if !bytes.Equal(got, content) {
+			t.Errorf("%s: expected %q, got %q", test.name, content, got)
+		}
+		if err := reader.Close(); err != nil {
+			t.Errorf("%s: unexpected error when closing: %v", test.name, err)
+		}
+	}
+}
+
+func TestCopySource(t *testing.T) {
+	tests := []struct {
+		bucket   string
+		key      string
+		expected string
+	}{
+		{"bucket", "file.txt", "bucket/file.txt"},
+		{"bucket", "pending/dir/file.txt", "bucket/pending/dir/file.txt"},
+		{"bucket", "pending/my file.txt", "bucket/pending/my%20file.txt"},
+		{"bucket", "pending/a+b.txt", "bucket/pending/a%2Bb.txt"},
+		{"bucket", "pending/100%.txt", "bucket/pending/100%25.txt"},
+		{"bucket", "pending/q?x=1#y", "bucket/pending/q%3Fx=1%23y"},
+	}
+	for _, test := range tests {
+		source := copySource(test.bucket, test.key)
+		if source != test.expected {
+			t.Errorf("Expected copySource(%q, %q) to return %q, but got %q", test.bucket, test.key, test.expected, source)
+		}
+	}
+}
+
+func TestEscapeGlob(t *testing.T) {
+	tests := []struct {
+		s       string
+		escaped string
+	}{
+		{"logs/2024", "logs/2024"},
+		{"logs/*", "logs/\\*"},
+		{"a?b[c]{d}", "a\\?b\\[c]\\{d}"},
+		{"dir\\sub", "dir\\\\sub"},
+	}
+	for _, test := range tests {
+		escaped := escapeGlob(test.s)
+		if escaped != test.escaped {
+			t.Errorf("Expected escapeGlob(%q) to return %q, but got %q", test.s, test.escaped, escaped)
+		}
+	}
+}
+
+func TestSkipLongLines(t *testing.T) {
+	content := "first\n" + strings.Repeat("x", 100) + "\nsecond\n" + strings.Repeat("y", 100) + "\nthird"
+
+	// DataErrReader returns the end of the stream along with the last bytes, as gzip readers
+	// do, so the end of a dropped line and the lines after it are only scanned at EOF.
+	for name, r := range map[string]func() io.Reader{
+		"reader":       func() io.Reader { return strings.NewReader(content) },
+		"data err eof": func() io.Reader { return iotest.DataErrReader(strings.NewReader(content)) },
+		"one byte":     func() io.Reader { return iotest.OneByteReader(strings.NewReader(content)) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			skipped := 0
+			scanner := bufio.NewScanner(r())
+			scanner.Buffer(make([]byte, 0, 16), 32)
+			scanner.Split(skipLongLines(32, func() { skipped++ }))
+
+			var lines []string
+			for scanner.Scan() {
+				lines = append(lines, scanner.Text())
+			}
+			if err := scanner.Err(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if want := []string{"first", "second", "third"}; !reflect.DeepEqual(lines, want) {
+				t.Errorf("Expected lines %q, but got %q", want, lines)
+			}
+			if skipped != 2 {
+				t.Errorf("Expected 2 skipped lines, but got %d", skipped)
+			}
+		})
+	}
+}
+
+func TestFilterNewETags(t *testing.T) {
+	objects := []ObjectInfo{
+		{Key: "synced.log", ETag: `"5d41402abc4b2a76b9719d911017c592"`},
+		{Key: "changed.log", ETag: `"7d793037a0760186574b0282f2f435e7"`},
+		{Key: "multipart.log", ETag: `"7B0E3E3D8E8B5E1C6F3E2D4A5B6C7D8E-3"`},
+		{Key: "new-multipart.log", ETag: `"0cc175b9c0f1b6a831c399e269772661-2"`},
+	}
+	known := map[string]bool{
+		"5d41402abc4b2a76b9719d911017c592":         true,
+		"W/\"7b0e3e3d8e8b5e1c6f3e2d4a5b6c7d8e-3\"": true,
+		"7d793037a0760186574b0282f2f435e7":         false,
+	}
+
+	var keys []string
+	for _, obj := range FilterNewETags(objects, known) {
+		keys = append(keys, obj.Key)
+	}
+	expected := []string{"changed.log", "new-multipart.log"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Expected FilterNewETags to return %q, but got %q", expected, keys)
+	}
+
+	tests := []struct {
+		etag      string
+		multipart bool
+	}{
+		{`"5d41402abc4b2a76b9719d911017c592"`, false},
+		{`"0cc175b9c0f1b6a831c399e269772661-2"`, true},
+		{"0cc175b9c0f1b6a831c399e269772661-12", true},
+		{"", false},
+	}
+	for _, test := range tests {
+		if multipart := IsMultipartETag(test.etag); multipart != test.multipart {
+			t.Errorf("Expected IsMultipartETag(%q) to return %t, but got %t", test.etag, test.multipart, multipart)
+		}
+	}
+}
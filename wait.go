@@ -0,0 +1,127 @@
+package s3client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// waitMaxDelay caps the delay between the checks of WaitForObject, unless the poll interval
+// is already longer.
+var waitMaxDelay = 30 * time.Second
+
+// WaitForObject waits until the specified file exists in the S3 bucket, checking for it with
+// HeadObject every pollInterval at first, then doubling the delay between checks up to 30s.
+// It fails with ErrWaitTimeout if the file doesn't exist once timeout has elapsed, and with the
+// error of the context if it is done before that.
+func (c *DefaultClient) WaitForObject(ctx context.Context, bucket string, file string, pollInterval time.Duration, timeout time.Duration) (err error) {
+	defer c.observe("WaitForObject", time.Now(), &err)
+
+	if pollInterval <= 0 {
+		return fmt.Errorf("invalid poll interval: %s", pollInterval)
+	}
+	if timeout <= 0 {
+		return fmt.Errorf("invalid timeout: %s", timeout)
+	}
+
+	ctx, cancel := context.WithTimeoutCause(ctx, timeout, ErrWaitTimeout)
+	defer cancel()
+
+	delay := pollInterval
+	for {
+		_, err := c.headObject(ctx, bucket, file)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("error waiting for file %s on bucket %s: %w", file, bucket, context.Cause(ctx))
+		}
+		if err = mapError(err); !errors.Is(err, ErrNoSuchKey) {
+			return fmt.Errorf("error checking file on s3: %w", err)
+		}
+
+		c.logger().Debug("file: %s not found on bucket: %s yet, checking again in %s", file, bucket, delay)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("error waiting for file %s on bucket %s: %w", file, bucket, context.Cause(ctx))
+		}
+
+		delay = min(2*delay, max(pollInterval, waitMaxDelay))
+	}
+}
+
+// StreamNewObjects sends the objects under prefix in the specified bucket through the returned
+// channel in lexicographical key order, then keeps listing the prefix every pollInterval for the
+// objects whose keys sort after the last one sent, so that objects named in time order, such as
+// "2024/01/02/00.log", are streamed as they appear. Objects written with a key sorting before the
+// last one sent are never sent.
+// The stream only ends on errors. Once ctx is done, its error is sent through the error channel,
+// which must still be drained then, as with every stream.
+func (c *DefaultClient) StreamNewObjects(ctx context.Context, bucket, prefix string, pollInterval time.Duration) (<-chan ObjectInfo, <-chan error) {
+	out := make(chan ObjectInfo)
+	errChan := make(chan error)
+
+	go func() {
+		defer close(errChan)
+		defer close(out)
+
+		if pollInterval <= 0 {
+			errChan <- fmt.Errorf("invalid poll interval: %s", pollInterval)
+			return
+		}
+
+		var lastKey string
+		for {
+			params := &s3.ListObjectsV2Input{
+				Bucket:       aws.String(bucket),
+				MaxKeys:      c.opts.listMaxKeys(),
+				RequestPayer: c.requestPayer(),
+			}
+			if prefix != "" {
+				params.Prefix = aws.String(prefix)
+			}
+			if lastKey != "" {
+				params.StartAfter = aws.String(lastKey)
+			}
+
+			c.logger().Debug("listing objects on bucket: %q with prefix: %q after: %q", bucket, prefix, lastKey)
+			p := s3.NewListObjectsV2Paginator(c.Svc, params)
+			for p.HasMorePages() {
+				page, err := listPage(c, bucket, func(optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+					return p.NextPage(ctx, optFns...)
+				})
+				if err != nil {
+					errChan <- fmt.Errorf("error listing new objects from s3: %w", mapError(err))
+					return
+				}
+				for _, obj := range page.Contents {
+					select {
+					case out <- newObjectInfoFromObject(obj):
+						lastKey = aws.ToString(obj.Key)
+					case <-ctx.Done():
+						errChan <- fmt.Errorf("error streaming new objects from s3: %w", ctx.Err())
+						return
+					}
+				}
+			}
+
+			timer := time.NewTimer(pollInterval)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				errChan <- fmt.Errorf("error streaming new objects from s3: %w", ctx.Err())
+				return
+			}
+		}
+	}()
+
+	return observeStream(c, "StreamNewObjects", out, errChan)
+}
@@ -0,0 +1,146 @@
+package s3client
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+
+	"github.com/calyptia/go-s3-client/ifaces"
+)
+
+func TestDefaultClient_WaitForObject(t *testing.T) {
+	ctx := context.TODO()
+
+	// newClient returns a mock finding the object on the given HeadObject call, if ever.
+	newClient := func(foundOn int32) *ifaces.ClientMock {
+		var calls int32
+		return &ifaces.ClientMock{
+			HeadObjectFunc: func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+				if n := atomic.AddInt32(&calls, 1); foundOn > 0 && n >= foundOn {
+					return &s3.HeadObjectOutput{}, nil
+				}
+				return nil, &smithy.GenericAPIError{Code: "NotFound"}
+			},
+		}
+	}
+
+	t.Run("appears", func(t *testing.T) {
+		client := newClient(3)
+		c := DefaultClient{
+			Svc:    client,
+			Logger: NullLogger{},
+		}
+
+		err := c.WaitForObject(ctx, "bucket", "file.log", time.Millisecond, time.Second)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, len(client.HeadObjectCalls()))
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		c := DefaultClient{
+			Svc:    newClient(0),
+			Logger: NullLogger{},
+		}
+
+		err := c.WaitForObject(ctx, "bucket", "file.log", time.Millisecond, 20*time.Millisecond)
+		assert.IsError(t, err, ErrWaitTimeout)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		c := DefaultClient{
+			Svc:    newClient(0),
+			Logger: NullLogger{},
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		err := c.WaitForObject(ctx, "bucket", "file.log", time.Millisecond, time.Second)
+		assert.IsError(t, err, context.Canceled)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		client := &ifaces.ClientMock{
+			HeadObjectFunc: func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+				return nil, &smithy.GenericAPIError{Code: "Forbidden"}
+			},
+		}
+		c := DefaultClient{
+			Svc:    client,
+			Logger: NullLogger{},
+		}
+
+		err := c.WaitForObject(ctx, "bucket", "file.log", time.Millisecond, time.Second)
+		assert.IsError(t, err, ErrAccessDenied)
+		assert.Equal(t, 1, len(client.HeadObjectCalls()))
+	})
+}
+
+func TestDefaultClient_StreamNewObjects(t *testing.T) {
+	c, err := NewMemoryClient(NullLogger{}, map[string][]byte{
+		"logs/2024/01/02/01.log": []byte("b"),
+		"logs/2024/01/02/00.log": []byte("a"),
+		"other/00.log":           []byte("x"),
+	})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	outCh, errCh := c.StreamNewObjects(ctx, "bucket", "logs/", time.Millisecond)
+	next := func() string {
+		select {
+		case info := <-outCh:
+			return info.Key
+		case err := <-errCh:
+			t.Fatalf("unexpected error: %v", err)
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for an object")
+		}
+		return ""
+	}
+
+	assert.Equal(t, "logs/2024/01/02/00.log", next())
+	assert.Equal(t, "logs/2024/01/02/01.log", next())
+
+	// only the objects sorting after the last one sent are streamed.
+	assert.NoError(t, c.WriteFile(ctx, "bucket", "logs/2024/01/01/23.log", strings.NewReader("late")))
+	assert.NoError(t, c.WriteFile(ctx, "bucket", "logs/2024/01/02/02.log", strings.NewReader("c")))
+	assert.Equal(t, "logs/2024/01/02/02.log", next())
+	assert.NoError(t, c.WriteFile(ctx, "bucket", "logs/2024/01/03/00.log", strings.NewReader("d")))
+	assert.Equal(t, "logs/2024/01/03/00.log", next())
+
+	// drain returns the error the stream ends with.
+	drain := func(outCh <-chan ObjectInfo, errCh <-chan error) error {
+		var streamErr error
+		for outCh != nil || errCh != nil {
+			select {
+			case _, ok := <-outCh:
+				if !ok {
+					outCh = nil
+				}
+			case err, ok := <-errCh:
+				if !ok {
+					errCh = nil
+					continue
+				}
+				streamErr = err
+			}
+		}
+		return streamErr
+	}
+
+	cancel()
+	assert.IsError(t, drain(outCh, errCh), context.Canceled)
+
+	t.Run("invalid poll interval", func(t *testing.T) {
+		err := drain(c.StreamNewObjects(context.TODO(), "bucket", "logs/", 0))
+		assert.EqualError(t, err, "invalid poll interval: 0s")
+	})
+}